@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Векторы PBKDF2-HMAC-SHA256 из RFC 7914
+func TestPBKDF2(t *testing.T) {
+	cases := []struct {
+		password, salt string
+		iterations     int
+		key            string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	}
+
+	for _, c := range cases {
+		key := pbkdf2([]byte(c.password), []byte(c.salt), c.iterations, len(c.key)/2)
+		if got := hex.EncodeToString(key); got != c.key {
+			t.Errorf("%s/%s/%d: got %s", c.password, c.salt, c.iterations, got)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !CheckPassword(hash, "secret") {
+		t.Errorf("right password is rejected")
+	}
+	for _, wrong := range []string{"", "Secret", "secret ", "secre"} {
+		if CheckPassword(hash, wrong) {
+			t.Errorf("wrong password %q is accepted", wrong)
+		}
+	}
+
+	// Соль случайная: одинаковые пароли дают разные хэши
+	if other, _ := HashPassword("secret"); other == hash {
+		t.Errorf("two hashes of a password are equal")
+	}
+
+	if _, err := HashPassword(""); err != ErrEmptyPassword {
+		t.Errorf("empty password: got %v", err)
+	}
+
+	parts := strings.Split(hash, "$")
+	for _, bad := range []string{
+		"",
+		"secret",
+		"md5$100000$" + parts[2] + "$" + parts[3],
+		hashScheme + "$0$" + parts[2] + "$" + parts[3],
+		hashScheme + "$x$" + parts[2] + "$" + parts[3],
+		hashScheme + "$100000$!!$" + parts[3],
+		hashScheme + "$100000$" + parts[2] + "$",
+		hashScheme + "$100000$" + parts[2],
+	} {
+		if CheckPassword(bad, "secret") {
+			t.Errorf("malformed hash %q is accepted", bad)
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	signer := NewSigner([]byte("key"))
+
+	token, id, err := signer.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := signer.Verify(token); !ok || got != id {
+		t.Fatalf("own token: got %q %v, want %q", got, ok, id)
+	}
+	if strings.Contains(token, id) {
+		t.Errorf("token contains the session id")
+	}
+
+	other, _, _ := signer.Issue()
+	body := token[len(tokenPrefix):strings.LastIndex(token, ".")]
+	sig := token[strings.LastIndex(token, ".")+1:]
+
+	// Меняется первый символ: в последнем base64 есть неиспользуемые биты
+	flip := func(s string) string {
+		if s[0] == 'A' {
+			return "B" + s[1:]
+		}
+		return "A" + s[1:]
+	}
+
+	for name, forged := range map[string]string{
+		"empty":           "",
+		"no prefix":       token[len(tokenPrefix):],
+		"other version":   "v2." + token[len(tokenPrefix):],
+		"changed body":    tokenPrefix + flip(body) + "." + sig,
+		"changed sig":     tokenPrefix + body + "." + flip(sig),
+		"other signature": tokenPrefix + body + other[strings.LastIndex(other, "."):],
+		"no signature":    tokenPrefix + body,
+		"extra part":      token + ".x",
+		"bad base64":      tokenPrefix + body + "!." + sig,
+	} {
+		if _, ok := signer.Verify(forged); ok {
+			t.Errorf("%s token %q is accepted", name, forged)
+		}
+	}
+
+	if _, ok := NewSigner([]byte("other key")).Verify(token); ok {
+		t.Errorf("token of another key is accepted")
+	}
+}
+
+func TestUserContext(t *testing.T) {
+	ctx := context.Background()
+
+	if User(ctx) != "" {
+		t.Errorf("anonymous context has a user")
+	}
+	if got := User(WithUser(ctx, "nick")); got != "nick" {
+		t.Errorf("got %q", got)
+	}
+}
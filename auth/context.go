@@ -0,0 +1,18 @@
+package auth
+
+import "context"
+
+type contextKey int
+
+const userKey contextKey = 0
+
+// WithUser stores the nickname of the authenticated caller.
+func WithUser(ctx context.Context, nickname string) context.Context {
+	return context.WithValue(ctx, userKey, nickname)
+}
+
+// User returns the authenticated caller or "" for anonymous requests.
+func User(ctx context.Context) string {
+	nickname, _ := ctx.Value(userKey).(string)
+	return nickname
+}
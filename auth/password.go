@@ -0,0 +1,100 @@
+// Package auth hashes passwords, issues signed tokens and carries the caller identity in a context.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	hashScheme     = "pbkdf2-sha256"
+	hashIterations = 100000
+	saltSize       = 16
+	keySize        = 32
+)
+
+var ErrEmptyPassword = errors.New("auth: empty password")
+
+// HashPassword returns "pbkdf2-sha256$iterations$salt$key" with a random salt.
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", ErrEmptyPassword
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := pbkdf2([]byte(password), salt, hashIterations, keySize)
+
+	return fmt.Sprintf("%s$%d$%s$%s", hashScheme, hashIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether password matches a hash made by HashPassword.
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return false
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(pbkdf2([]byte(password), salt, iterations, len(key)), key) == 1
+}
+
+// pbkdf2 implements PBKDF2 (RFC 8018) with HMAC-SHA256.
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+
+	var key []byte
+	var block [4]byte
+
+	for i := uint32(1); len(key) < keyLen; i++ {
+		binary.BigEndian.PutUint32(block[:], i)
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(block[:])
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
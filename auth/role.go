@@ -0,0 +1,21 @@
+package auth
+
+// Role is the rights of a user in a forum, a higher role includes the lower ones.
+type Role int
+
+const (
+	RoleBanned Role = iota
+	RoleMember
+	RoleModerator
+	RoleOwner
+	RoleAdmin
+)
+
+var roleNames = []string{"banned", "member", "moderator", "owner", "admin"}
+
+func (r Role) String() string {
+	if r < RoleBanned || r > RoleAdmin {
+		return "unknown"
+	}
+	return roleNames[r]
+}
@@ -0,0 +1,597 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to every setting name when it is read from the environment:
+// db.max_open_conns -> FORUM_DB_MAX_OPEN_CONNS.
+const EnvPrefix = "FORUM_"
+
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+type Config struct {
+	Listen   string // Адрес, на котором слушает HTTP сервер
+	Storage  string // StoragePostgres или StorageMemory
+	Server   Server
+	Timeouts Timeouts
+	Log      Log
+	Tracing  Tracing
+	Auth     Auth
+	DB       DB
+	Features Features
+}
+
+type Server struct {
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // Сколько ждать завершения текущих запросов при остановке
+	DrainDelay      time.Duration // Сколько /readyz отвечает 503 перед закрытием сокета
+}
+
+// Endpoints are the names used for per-endpoint settings: timeouts.thread_posts = "2s".
+var Endpoints = []string{
+	"auth_login",
+	"auth_logout",
+	"auth_token",
+	"categories",
+	"category_create",
+	"category_details",
+	"forum_bans",
+	"forum_create",
+	"forum_details",
+	"forum_moderators",
+	"forum_threads",
+	"forum_users",
+	"forums",
+	"post_details",
+	"post_history",
+	"post_create",
+	"post_restore",
+	"search",
+	"service_clear",
+	"service_status",
+	"thread_create",
+	"thread_details",
+	"thread_history",
+	"thread_posts",
+	"thread_restore",
+	"thread_vote",
+	"user_create",
+	"user_posts",
+	"user_profile",
+	"user_threads",
+}
+
+type Timeouts struct {
+	Default   time.Duration            // Для эндпоинтов без своего значения, 0 - без ограничения
+	Endpoints map[string]time.Duration // Переопределения по имени эндпоинта из Endpoints
+}
+
+// For returns the deadline of handling a request to endpoint, 0 means no deadline.
+func (t Timeouts) For(endpoint string) time.Duration {
+	if d, ok := t.Endpoints[endpoint]; ok {
+		return d
+	}
+	return t.Default
+}
+
+type Log struct {
+	Level      string  // debug, info, warn или error
+	Format     string  // logfmt или json
+	Output     string  // stdout, stderr или путь к файлу
+	SampleRate float64 // Доля записей уровня info и ниже, которые попадают в лог (1 - все)
+}
+
+type Tracing struct {
+	Exporter   string  // none, stdout или http
+	Endpoint   string  // URL коллектора для exporter = http
+	SampleRate float64 // Доля запросов, для которых пишется трейс
+}
+
+type Auth struct {
+	Enforce      bool          // Изменять профиль, ветку или пост может только их автор
+	Secret       string        // Ключ подписи токенов, если пустой - случайный при каждом запуске
+	SessionTTL   time.Duration // Время жизни токена входа
+	TokenTTL     time.Duration // Время жизни API токена
+	CookieSecure bool          // Cookie сессии только для https
+	Admins       []string      // Ники администраторов форума
+}
+
+type DB struct {
+	DSN             string // Полная строка подключения, если задана - остальные параметры подключения игнорируются
+	Host            string
+	Port            int
+	User            string
+	Password        string
+	Name            string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnectTimeout  time.Duration
+}
+
+type Features struct {
+	AccessLog bool // Писать строку в лог на каждый запрос
+	Metrics   bool // Отдавать метрики prometheus на /metrics
+
+	MigrateOnStart bool // Применять недостающие миграции при запуске сервера
+
+	StatusMode string // Режим /api/service/status по умолчанию: counters, exact или estimate
+
+	CountersReconcileInterval time.Duration // Как часто пересчитывать service_counters, 0 - никогда
+}
+
+func Default() *Config {
+	return &Config{
+		Listen:  ":5000",
+		Storage: StoragePostgres,
+		Server: Server{
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Timeouts: Timeouts{
+			Default:   10 * time.Second,
+			Endpoints: make(map[string]time.Duration),
+		},
+		Log: Log{
+			Level:      "info",
+			Format:     "logfmt",
+			Output:     "stdout",
+			SampleRate: 1,
+		},
+		Tracing: Tracing{
+			Exporter:   "none",
+			SampleRate: 1,
+		},
+		Auth: Auth{
+			SessionTTL: 24 * time.Hour,
+			TokenTTL:   30 * 24 * time.Hour,
+		},
+		DB: DB{
+			Host:            "localhost",
+			Port:            5432,
+			User:            "docker",
+			Password:        "docker",
+			Name:            "docker",
+			SSLMode:         "disable",
+			MaxOpenConns:    0,
+			MaxIdleConns:    2,
+			ConnMaxLifetime: 0,
+			ConnectTimeout:  5 * time.Second,
+		},
+		Features: Features{
+			AccessLog:  true,
+			Metrics:    true,
+			StatusMode: "counters",
+
+			CountersReconcileInterval: 10 * time.Minute,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, an optional config file, the environment
+// and command line flags. Later sources override earlier ones: flags > env > file > defaults.
+// The config file is taken from the -config flag or the FORUM_CONFIG variable.
+func Load(name string, args []string) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "path to a TOML config file")
+
+	fromFlags := make(map[string]string)
+	for _, s := range settings {
+		_, isBool := s.set.(boolFunc)
+		fs.Var(&flagValue{key: s.key, values: fromFlags, isBool: isBool}, s.key, s.usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		data, err := ioutil.ReadFile(*configPath)
+		if err != nil {
+			return nil, fmt.Errorf("config: %v", err)
+		}
+
+		fromFile, err := parseFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("config: %s: %v", *configPath, err)
+		}
+
+		if err := apply(settings, fromFile, *configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	fromEnv := make(map[string]string)
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(envName(s.key)); ok {
+			fromEnv[s.key] = v
+		}
+	}
+
+	if err := apply(settings, fromEnv, "environment"); err != nil {
+		return nil, err
+	}
+
+	if err := apply(settings, fromFlags, "flags"); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Listen == "" {
+		problems = append(problems, "listen must not be empty")
+	}
+
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		problems = append(problems, "server timeouts must not be negative")
+	}
+	if c.Server.DrainDelay < 0 {
+		problems = append(problems, "server.drain_delay must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, "server.shutdown_timeout must be positive")
+	}
+
+	switch c.Features.StatusMode {
+	case "counters", "exact", "estimate":
+	default:
+		problems = append(problems, "features.status_mode must be one of counters, exact, estimate")
+	}
+	if c.Features.CountersReconcileInterval < 0 {
+		problems = append(problems, "features.counters_reconcile_interval must not be negative")
+	}
+
+	if c.Timeouts.Default < 0 {
+		problems = append(problems, "timeouts.default must not be negative")
+	}
+	for endpoint, d := range c.Timeouts.Endpoints {
+		if d < 0 {
+			problems = append(problems, "timeouts."+endpoint+" must not be negative")
+		}
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, "log.level must be one of debug, info, warn, error")
+	}
+	switch c.Log.Format {
+	case "logfmt", "json":
+	default:
+		problems = append(problems, "log.format must be either logfmt or json")
+	}
+	if c.Log.Output == "" {
+		problems = append(problems, "log.output must not be empty")
+	}
+	if c.Log.SampleRate < 0 || c.Log.SampleRate > 1 {
+		problems = append(problems, "log.sample_rate must be between 0 and 1")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "http":
+		if c.Tracing.Endpoint == "" {
+			problems = append(problems, "tracing.endpoint is required for the http exporter")
+		}
+	default:
+		problems = append(problems, "tracing.exporter must be one of none, stdout, http")
+	}
+	if c.Tracing.SampleRate < 0 || c.Tracing.SampleRate > 1 {
+		problems = append(problems, "tracing.sample_rate must be between 0 and 1")
+	}
+
+	if c.Auth.SessionTTL <= 0 || c.Auth.TokenTTL <= 0 {
+		problems = append(problems, "auth.session_ttl and auth.token_ttl must be positive")
+	}
+
+	switch c.Storage {
+	case StoragePostgres, StorageMemory:
+	default:
+		problems = append(problems, "storage must be either postgres or memory")
+	}
+
+	if c.Storage == StoragePostgres && c.DB.DSN == "" {
+		if c.DB.Host == "" {
+			problems = append(problems, "db.host must not be empty")
+		}
+		if c.DB.Port <= 0 || c.DB.Port > 65535 {
+			problems = append(problems, "db.port must be between 1 and 65535")
+		}
+		if c.DB.User == "" {
+			problems = append(problems, "db.user must not be empty")
+		}
+		if c.DB.Name == "" {
+			problems = append(problems, "db.name must not be empty")
+		}
+		switch c.DB.SSLMode {
+		case "disable", "require", "verify-ca", "verify-full":
+		default:
+			problems = append(problems, "db.sslmode must be one of disable, require, verify-ca, verify-full")
+		}
+	}
+
+	if c.DB.MaxOpenConns < 0 {
+		problems = append(problems, "db.max_open_conns must not be negative")
+	}
+	if c.DB.MaxIdleConns < 0 {
+		problems = append(problems, "db.max_idle_conns must not be negative")
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		problems = append(problems, "db.max_idle_conns must not exceed db.max_open_conns")
+	}
+	if c.DB.ConnMaxLifetime < 0 {
+		problems = append(problems, "db.conn_max_lifetime must not be negative")
+	}
+	if c.DB.ConnectTimeout < 0 {
+		problems = append(problems, "db.connect_timeout must not be negative")
+	}
+
+	if len(problems) != 0 {
+		return errors.New("config: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// ConnString returns the lib/pq connection string for the database settings.
+func (d DB) ConnString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+
+	params := []string{
+		"host=" + quoteParam(d.Host),
+		"port=" + strconv.Itoa(d.Port),
+		"user=" + quoteParam(d.User),
+		"password=" + quoteParam(d.Password),
+		"dbname=" + quoteParam(d.Name),
+		"sslmode=" + quoteParam(d.SSLMode),
+	}
+
+	if d.ConnectTimeout > 0 {
+		// connect_timeout задается в секундах, округляем вверх
+		seconds := int((d.ConnectTimeout + time.Second - 1) / time.Second)
+		params = append(params, "connect_timeout="+strconv.Itoa(seconds))
+	}
+
+	return strings.Join(params, " ")
+}
+
+func quoteParam(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+
+	return "'" + v + "'"
+}
+
+type setting struct {
+	key   string
+	usage string
+	set   setter
+}
+
+// setter parses a raw value of a setting into the config.
+type setter interface {
+	Set(v string) error
+}
+
+type setFunc func(string) error
+
+func (f setFunc) Set(v string) error { return f(v) }
+
+// boolFunc is the setter of a boolean setting, its flag can be given without a value.
+type boolFunc func(string) error
+
+func (f boolFunc) Set(v string) error { return f(v) }
+
+func (c *Config) settings() []setting {
+	settings := []setting{
+		{"listen", "HTTP listen address", stringVar(&c.Listen)},
+		{"storage", "storage backend: postgres or memory", stringVar(&c.Storage)},
+
+		{"server.read_timeout", "maximum duration for reading a request (0 - no timeout)", durationVar(&c.Server.ReadTimeout)},
+		{"server.write_timeout", "maximum duration for writing a response (0 - no timeout)", durationVar(&c.Server.WriteTimeout)},
+		{"server.idle_timeout", "how long keep-alive connections stay open (0 - no timeout)", durationVar(&c.Server.IdleTimeout)},
+		{"server.shutdown_timeout", "how long to wait for in-flight requests on SIGTERM/SIGINT", durationVar(&c.Server.ShutdownTimeout)},
+		{"server.drain_delay", "how long /readyz fails before the listener is closed on shutdown", durationVar(&c.Server.DrainDelay)},
+
+		{"log.level", "minimum log level: debug, info, warn or error", stringVar(&c.Log.Level)},
+		{"log.format", "log record format: logfmt or json", stringVar(&c.Log.Format)},
+		{"log.output", "where to write the log: stdout, stderr or a file path", stringVar(&c.Log.Output)},
+		{"log.sample_rate", "fraction of info and debug records to keep, warnings and errors are always kept", floatVar(&c.Log.SampleRate)},
+
+		{"tracing.exporter", "where to send traces: none, stdout or http", stringVar(&c.Tracing.Exporter)},
+		{"tracing.endpoint", "collector URL spans are POSTed to by the http exporter", stringVar(&c.Tracing.Endpoint)},
+		{"tracing.sample_rate", "fraction of requests to trace, a sampled traceparent header is always followed", floatVar(&c.Tracing.SampleRate)},
+
+		{"auth.enforce", "allow writing only as the caller and changing profiles, threads and posts only by their authors", boolVar(&c.Auth.Enforce)},
+		{"auth.secret", "key for signing tokens, a random one is generated on start if empty", stringVar(&c.Auth.Secret)},
+		{"auth.session_ttl", "lifetime of a login token", durationVar(&c.Auth.SessionTTL)},
+		{"auth.token_ttl", "lifetime of an API token", durationVar(&c.Auth.TokenTTL)},
+		{"auth.cookie_secure", "send the session cookie over https only", boolVar(&c.Auth.CookieSecure)},
+		{"auth.admins", "comma separated nicknames of admins, they have owner rights in every forum", listVar(&c.Auth.Admins)},
+
+		{"db.dsn", "full PostgreSQL connection string, overrides the other db.* connection settings", stringVar(&c.DB.DSN)},
+		{"db.host", "PostgreSQL host", stringVar(&c.DB.Host)},
+		{"db.port", "PostgreSQL port", intVar(&c.DB.Port)},
+		{"db.user", "PostgreSQL user", stringVar(&c.DB.User)},
+		{"db.password", "PostgreSQL password", stringVar(&c.DB.Password)},
+		{"db.name", "PostgreSQL database name", stringVar(&c.DB.Name)},
+		{"db.sslmode", "PostgreSQL sslmode", stringVar(&c.DB.SSLMode)},
+		{"db.max_open_conns", "maximum number of open connections (0 - unlimited)", intVar(&c.DB.MaxOpenConns)},
+		{"db.max_idle_conns", "maximum number of idle connections", intVar(&c.DB.MaxIdleConns)},
+		{"db.conn_max_lifetime", "maximum connection lifetime (0 - unlimited)", durationVar(&c.DB.ConnMaxLifetime)},
+		{"db.connect_timeout", "timeout for establishing a connection", durationVar(&c.DB.ConnectTimeout)},
+
+		{"features.access_log", "log every request", boolVar(&c.Features.AccessLog)},
+		{"features.metrics", "expose prometheus metrics on /metrics", boolVar(&c.Features.Metrics)},
+		{"features.migrate_on_start", "apply pending migrations before serving requests", boolVar(&c.Features.MigrateOnStart)},
+		{"features.status_mode", "default mode of /api/service/status: counters (maintained by triggers), exact (COUNT) or estimate (planner statistics)", stringVar(&c.Features.StatusMode)},
+		{"features.counters_reconcile_interval", "how often to recount rows and fix the status counters (0 - never)", durationVar(&c.Features.CountersReconcileInterval)},
+
+		{"timeouts.default", "deadline for handling a request (0 - none), including its database queries", durationVar(&c.Timeouts.Default)},
+	}
+
+	for _, endpoint := range Endpoints {
+		settings = append(settings, setting{
+			key:   "timeouts." + endpoint,
+			usage: "deadline for the " + endpoint + " endpoint, overrides timeouts.default",
+			set:   endpointDurationVar(c.Timeouts.Endpoints, endpoint),
+		})
+	}
+
+	return settings
+}
+
+func apply(settings []setting, values map[string]string, source string) error {
+	known := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		known[s.key] = s
+	}
+
+	for key, value := range values {
+		s, ok := known[key]
+		if !ok {
+			return fmt.Errorf("config: %s: unknown setting %q", source, key)
+		}
+
+		if err := s.set.Set(value); err != nil {
+			return fmt.Errorf("config: %s: %s: %v", source, key, err)
+		}
+	}
+
+	return nil
+}
+
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+func stringVar(p *string) setFunc {
+	return func(v string) error {
+		*p = v
+		return nil
+	}
+}
+
+func listVar(p *[]string) setFunc {
+	return func(v string) error {
+		*p = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+		return nil
+	}
+}
+
+func intVar(p *int) setFunc {
+	return func(v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func floatVar(p *float64) setFunc {
+	return func(v string) error {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*p = f
+		return nil
+	}
+}
+
+func boolVar(p *bool) boolFunc {
+	return func(v string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", v)
+		}
+		*p = b
+		return nil
+	}
+}
+
+func durationVar(p *time.Duration) setFunc {
+	return func(v string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration (e.g. 500ms, 30s, 5m)", v)
+		}
+		*p = d
+		return nil
+	}
+}
+
+func endpointDurationVar(m map[string]time.Duration, endpoint string) setFunc {
+	return func(v string) error {
+		var d time.Duration
+		if err := durationVar(&d)(v); err != nil {
+			return err
+		}
+		m[endpoint] = d
+		return nil
+	}
+}
+
+// flagValue only remembers the raw value, settings are applied after the file and env.
+type flagValue struct {
+	key    string
+	values map[string]string
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return f.values[f.key]
+}
+
+func (f *flagValue) Set(v string) error {
+	f.values[f.key] = v
+	return nil
+}
+
+// IsBoolFlag lets a boolean setting be given as a bare -flag, the flag package sets it to "true".
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
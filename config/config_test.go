@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestBoolFlags(t *testing.T) {
+	cfg, err := Load("forum", []string{"-auth.enforce", "-features.access_log=false", "-listen", ":6000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cfg.Auth.Enforce || cfg.Features.AccessLog || cfg.Listen != ":6000" {
+		t.Errorf("got enforce %v, access log %v, listen %q", cfg.Auth.Enforce, cfg.Features.AccessLog, cfg.Listen)
+	}
+
+	// Значение остальных настроек обязательно
+	if _, err := Load("forum", []string{"-listen"}); err == nil {
+		t.Errorf("bare -listen is accepted")
+	}
+	if _, err := Load("forum", []string{"-auth.enforce=maybe"}); err == nil {
+		t.Errorf("-auth.enforce=maybe is accepted")
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseFile reads the flat subset of TOML used by the config file:
+//
+//	listen = ":5000"
+//
+//	[db]
+//	host = "localhost"
+//	max_open_conns = 16
+//
+// Keys inside a [section] are returned as "section.key".
+func parseFile(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	section := ""
+
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNum := i + 1
+		line = strings.TrimSpace(stripComment(line))
+
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNum)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == "" {
+				return nil, fmt.Errorf("line %d: empty section name", lineNum)
+			}
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNum)
+		}
+
+		key := strings.TrimSpace(line[:eq])
+		raw := strings.TrimSpace(line[eq+1:])
+
+		if key == "" {
+			return nil, fmt.Errorf("line %d: empty key", lineNum)
+		}
+
+		value, err := unquote(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+
+		if section != "" {
+			key = section + "." + key
+		}
+
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNum, key)
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// stripComment cuts a trailing # comment that is not inside a quoted string.
+func stripComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func unquote(raw string) (string, error) {
+	if raw == "" {
+		return "", fmt.Errorf("missing value")
+	}
+
+	if strings.HasPrefix(raw, `"`) {
+		v, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", fmt.Errorf("bad string %s", raw)
+		}
+		return v, nil
+	}
+
+	if strings.HasPrefix(raw, "'") {
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", fmt.Errorf("bad string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	}
+
+	return raw, nil
+}
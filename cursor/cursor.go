@@ -0,0 +1,46 @@
+// Package cursor encodes positions in keyset paginated lists as opaque tokens.
+//
+// A token is the base64 encoded JSON of Cursor. It is not signed: a client changing it
+// can only move to another position of a list it may read anyway.
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalid = errors.New("cursor: invalid token")
+
+// Cursor points between two items of a list.
+type Cursor struct {
+	List string   `json:"l"`           // Для какого списка выдан: threads, users, posts...
+	Sort string   `json:"s,omitempty"` // Режим сортировки списка, если их несколько
+	Key  []string `json:"k"`           // Ключ сортировки крайнего элемента страницы, сам он не выдается
+	Desc bool     `json:"d,omitempty"` // Порядок списка
+	Back bool     `json:"b,omitempty"` // Курсор предыдущей страницы: читать от Key к началу списка
+	// Фильтры списка, с которыми выдан курсор, в виде query string. Позиция из другой
+	// выборки не имеет смысла, такой курсор отвергается.
+	Filter string `json:"f,omitempty"`
+}
+
+func (c *Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Decode parses a token made by Encode, it returns ErrInvalid for anything else.
+func Decode(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	c := new(Cursor)
+
+	if err := json.Unmarshal(raw, c); err != nil || c.List == "" || len(c.Key) == 0 {
+		return nil, ErrInvalid
+	}
+
+	return c, nil
+}
@@ -0,0 +1,184 @@
+// Package diff builds line based unified diffs of short texts such as post messages.
+package diff
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Context is the number of unchanged lines shown around each change.
+const Context = 3
+
+type op struct {
+	kind byte // ' ' - строка без изменений, '-' - удалена, '+' - добавлена
+	line string
+	a, b int // Сколько строк a и b пройдено до этой операции
+}
+
+// Unified returns the difference between a and b in the unified format with the
+// fromName and toName headers, "" if the texts are equal.
+func Unified(fromName, toName, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	ops := edits(split(a), split(b))
+
+	var out strings.Builder
+	out.WriteString("--- " + fromName + "\n")
+	out.WriteString("+++ " + toName + "\n")
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Изменения, между которыми не больше 2*Context общих строк, идут в один блок
+		last := i
+		for j := i; j < len(ops) && j-last <= 2*Context; j++ {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+		}
+
+		start := i - Context
+		if start < 0 {
+			start = 0
+		}
+		stop := last + Context + 1
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		writeHunk(&out, ops[start:stop])
+		i = stop
+	}
+
+	return out.String()
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// maxCells limits the LCS matrix of edits, 16 MB of int32.
+const maxCells = 1 << 22
+
+// edits returns the shortest edit script turning a into b, found through the longest
+// common subsequence of the lines between the common prefix and suffix. When that part
+// is too long for the LCS matrix it is replaced as a whole.
+func edits(a, b []string) []op {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+
+	// Концы a[ea:] и b[eb:] совпадают
+	ea, eb := len(a), len(b)
+	for ea > pre && eb > pre && a[ea-1] == b[eb-1] {
+		ea--
+		eb--
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+
+	for i := 0; i < pre; i++ {
+		ops = append(ops, op{kind: ' ', line: a[i], a: i, b: i})
+	}
+
+	if (ea-pre+1)*(eb-pre+1) > maxCells {
+		for i := pre; i < ea; i++ {
+			ops = append(ops, op{kind: '-', line: a[i], a: i, b: pre})
+		}
+		for j := pre; j < eb; j++ {
+			ops = append(ops, op{kind: '+', line: b[j], a: ea, b: j})
+		}
+	} else {
+		ops = lcsEdits(ops, a[:ea], b[:eb], pre)
+	}
+
+	for i, j := ea, eb; i < len(a); i, j = i+1, j+1 {
+		ops = append(ops, op{kind: ' ', line: a[i], a: i, b: j})
+	}
+
+	return ops
+}
+
+// lcsEdits appends the edit script of a[from:] and b[from:] to ops.
+func lcsEdits(ops []op, a, b []string, from int) []op {
+	// lcs[i][j] - длина общей подпоследовательности a[from+i:] и b[from+j:]
+	n, m := len(a)-from, len(b)-from
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[from+i] == b[from+j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[from+i] == b[from+j]:
+			ops = append(ops, op{kind: ' ', line: a[from+i], a: from + i, b: from + j})
+			i++
+			j++
+		case j == m || i < n && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{kind: '-', line: a[from+i], a: from + i, b: from + j})
+			i++
+		default:
+			ops = append(ops, op{kind: '+', line: b[from+j], a: from + i, b: from + j})
+			j++
+		}
+	}
+
+	return ops
+}
+
+func writeHunk(out *strings.Builder, ops []op) {
+	aCount, bCount := 0, 0
+	for _, o := range ops {
+		if o.kind != '+' {
+			aCount++
+		}
+		if o.kind != '-' {
+			bCount++
+		}
+	}
+
+	out.WriteString("@@ -" + hunkRange(ops[0].a, aCount) + " +" + hunkRange(ops[0].b, bCount) + " @@\n")
+
+	for _, o := range ops {
+		out.WriteByte(o.kind)
+		out.WriteString(o.line)
+		out.WriteByte('\n')
+	}
+}
+
+// hunkRange formats "start,count", for an empty range start is the line before it.
+func hunkRange(before, count int) string {
+	start := before + 1
+	if count == 0 {
+		start = before
+	}
+
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return strconv.Itoa(start) + "," + strconv.Itoa(count)
+}
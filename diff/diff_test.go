@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want string
+	}{
+		{"same", "same", ""},
+		{"", "new", "@@ -0,0 +1 @@\n+new\n"},
+		{"old", "", "@@ -1 +0,0 @@\n-old\n"},
+		{"1\n2\n3\n4\n5", "1\n2\nx\n4\n5", "@@ -1,5 +1,5 @@\n 1\n 2\n-3\n+x\n 4\n 5\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10", "1\n2\nx\n4\n5\n6\n7\n8\n9\n10\n11",
+			"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+x\n 4\n 5\n 6\n@@ -8,3 +8,4 @@\n 8\n 9\n 10\n+11\n",
+		},
+	}
+
+	for _, c := range cases {
+		want := c.want
+		if want != "" {
+			want = "--- a\n+++ b\n" + want
+		}
+
+		if got := Unified("a", "b", c.a, c.b); got != want {
+			t.Errorf("%q -> %q:\n got %q\nwant %q", c.a, c.b, got, want)
+		}
+	}
+}
+
+func lines(prefix string, n int) []string {
+	res := make([]string, n)
+	for i := range res {
+		res[i] = prefix + strconv.Itoa(i)
+	}
+	return res
+}
+
+// Длинный общий текст вокруг изменения не попадает в матрицу LCS
+func TestUnifiedLongCommonText(t *testing.T) {
+	text := lines("l", 100000)
+	changed := append([]string(nil), text...)
+	changed[50000] = "x"
+
+	got := Unified("a", "b", strings.Join(text, "\n"), strings.Join(changed, "\n"))
+	want := "--- a\n+++ b\n@@ -49998,7 +49998,7 @@\n l49997\n l49998\n l49999\n-l50000\n+x\n l50001\n l50002\n l50003\n"
+
+	if got != want {
+		t.Errorf("got %q", got)
+	}
+}
+
+// Слишком длинная измененная часть заменяется целиком
+func TestUnifiedTooLong(t *testing.T) {
+	a := append(lines("a", 5000), "end")
+	b := append(lines("b", 5000), "end")
+
+	got := Unified("a", "b", "start\n"+strings.Join(a, "\n"), "start\n"+strings.Join(b, "\n"))
+
+	header := "--- a\n+++ b\n@@ -1,5002 +1,5002 @@\n start\n-a0\n"
+	if !strings.HasPrefix(got, header) {
+		t.Fatalf("got %.200q", got)
+	}
+	if !strings.Contains(got, "\n-a4999\n+b0\n") {
+		t.Errorf("a isn't replaced by b as a whole")
+	}
+	if !strings.HasSuffix(got, "\n+b4999\n end\n") {
+		t.Errorf("a doesn't end with the common line")
+	}
+
+	changed := 0
+	for _, line := range strings.Split(got, "\n")[3:] {
+		if strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+") {
+			changed++
+		}
+	}
+	if changed != 2*5000 {
+		t.Errorf("%d changed lines", changed)
+	}
+}
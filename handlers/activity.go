@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/cursor"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+// UserPosts lists posts of the user with the limit, since, until and desc parameters of ForumThreads.
+func (h *Handler) UserPosts(w http.ResponseWriter, r *http.Request) {
+	nickname := mux.Vars(r)["nickname"]
+
+	filter, ok := threadFilter(w, r)
+	if !ok {
+		return
+	}
+
+	pg, ok := threadPager(w, r, "user_posts", &filter)
+	if !ok {
+		return
+	}
+
+	posts, err := h.store.Users.Posts(r.Context(), nickname, filter)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find user with nickname " + nickname + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	pg.send(w, posts, everyItem(len(posts)), func(item interface{}) []string {
+		post := item.(models.Post)
+		return encodeThreadKey(store.ThreadsByCreated, store.ThreadKey{Time: post.Created, Id: post.Id})
+	})
+}
+
+// UserThreads lists threads created by the user, see UserPosts.
+func (h *Handler) UserThreads(w http.ResponseWriter, r *http.Request) {
+	nickname := mux.Vars(r)["nickname"]
+
+	filter, ok := threadFilter(w, r)
+	if !ok {
+		return
+	}
+
+	pg, ok := threadPager(w, r, "user_threads", &filter)
+	if !ok {
+		return
+	}
+
+	thrs, err := h.store.Users.Threads(r.Context(), nickname, filter)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find user with nickname " + nickname + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	pg.send(w, thrs, everyItem(len(thrs)), threadKey(store.ThreadsByCreated))
+}
+
+// threadFilter reads limit, since and until (RFC 3339 times) and desc, answering 400 for bad values.
+// since is the early bound of created and until the late one, desc swaps them, see threadPager.
+func threadFilter(w http.ResponseWriter, r *http.Request) (store.ThreadFilter, bool) {
+	limitVal := r.URL.Query().Get("limit")
+	descVal := r.URL.Query().Get("desc")
+
+	filter := store.ThreadFilter{
+		Desc: descVal == "true",
+	}
+
+	if limitVal != "" {
+		var err error
+
+		filter.Limit, err = strconv.Atoi(limitVal)
+		if err != nil {
+			sendError("Bad limit " + limitVal + "\n", 400, &w)
+			return filter, false
+		}
+	}
+
+	for name, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		val := r.URL.Query().Get(name)
+		if val == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, val)
+		if err != nil {
+			sendError("Bad " + name + " " + val + "\n", 400, &w)
+			return filter, false
+		}
+
+		*dest = t
+	}
+
+	return filter, true
+}
+
+// threadPager starts paging a list ordered by filter.Sort and id and points filter at the cursor.
+// filters are the parameters of the list besides since and until, see newPager.
+func threadPager(w http.ResponseWriter, r *http.Request, list string, filter *store.ThreadFilter, filters ...string) (*pager, bool) {
+	pg, ok := newPager(w, r, list, filter.Sort, filter.Desc, filter.Limit, append(filters, "since", "until")...)
+	if !ok {
+		return nil, false
+	}
+
+	filter.Sort, filter.Desc, filter.Limit = pg.sort, pg.readDesc(), pg.fetchLimit()
+
+	// since и until идут в порядке списка при любой сортировке, а не в порядке чтения:
+	// в убывающем списке since - поздняя граница, в том числе для предыдущей страницы
+	if pg.desc {
+		filter.Since, filter.Until = filter.Until, filter.Since
+	}
+
+	if key := pg.key(); key != nil {
+		after, err := parseThreadKey(filter.Sort, key)
+		if err != nil {
+			sendError("Bad cursor " + r.URL.Query().Get("cursor") + "\n", 400, &w)
+			return nil, false
+		}
+
+		filter.After = after
+	}
+
+	return pg, true
+}
+
+// numericSort tells whether the sort value of the key is Number rather than Time.
+func numericSort(sort string) bool {
+	return sort == store.ThreadsByVotes || sort == store.ThreadsByPosts
+}
+
+func encodeThreadKey(sort string, k store.ThreadKey) []string {
+	value := k.Time.Format(time.RFC3339Nano)
+	if numericSort(sort) {
+		value = strconv.FormatInt(k.Number, 10)
+	}
+	return []string{value, strconv.FormatInt(k.Id, 10)}
+}
+
+func parseThreadKey(sort string, key []string) (*store.ThreadKey, error) {
+	if len(key) != 2 {
+		return nil, cursor.ErrInvalid
+	}
+
+	k := new(store.ThreadKey)
+	var err error
+
+	if numericSort(sort) {
+		k.Number, err = strconv.ParseInt(key[0], 10, 64)
+	} else {
+		k.Time, err = time.Parse(time.RFC3339Nano, key[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k.Id, err = strconv.ParseInt(key[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// threadKey returns the cursor key function of a thread list sorted by sort.
+func threadKey(sort string) func(item interface{}) []string {
+	return func(item interface{}) []string {
+		thr := item.(models.Thread)
+		k := store.ThreadKey{Time: thr.Created, Id: int64(thr.Id)}
+
+		switch sort {
+		case store.ThreadsByVotes:
+			k.Number = int64(thr.Votes)
+		case store.ThreadsByPosts:
+			k.Number = thr.Posts
+		case store.ThreadsByActivity:
+			if thr.LastPostAt != nil {
+				k.Time = *thr.LastPostAt
+			}
+		}
+
+		return encodeThreadKey(sort, k)
+	}
+}
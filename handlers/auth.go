@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/auth"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+)
+
+// SessionCookie holds the login token for browsers, API clients use Authorization: Bearer.
+const SessionCookie = "forum_session"
+
+type AuthOptions struct {
+	Enforce      bool // Проверять, что изменять профиль, ветку или пост может только их автор
+	Signer       *auth.Signer
+	SessionTTL   time.Duration // Время жизни токена, выданного при входе
+	TokenTTL     time.Duration // Время жизни API токена
+	CookieSecure bool          // Выставлять cookie только для https
+	Admins       []string      // Ники администраторов, у них права владельца во всех форумах
+}
+
+// Authenticate resolves the caller from the token and puts the nickname into the request context.
+// Requests with a missing, forged or expired token go on as anonymous.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, ok := h.opts.Auth.Signer.Verify(token)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := h.store.Sessions.Get(r.Context(), id)
+		if err == store.ErrNotFound {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), session.Nickname)))
+	})
+}
+
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(header[len("Bearer "):])
+	}
+
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+// authorize checks that the caller may change an object of owner. With enforcement off
+// everyone may, as before authentication existed. It answers 401/403 itself.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, owner string) bool {
+	if !h.opts.Auth.Enforce {
+		return true
+	}
+
+	return h.requireUser(w, r, owner)
+}
+
+// requireUser checks the caller regardless of enforcement, e.g. for changing a password.
+func (h *Handler) requireUser(w http.ResponseWriter, r *http.Request, owner string) bool {
+	caller := auth.User(r.Context())
+
+	if caller == "" {
+		sendError("Authentication required\n", http.StatusUnauthorized, &w)
+		return false
+	}
+
+	// Ники сравниваются без учета регистра, как citext в базе
+	if !strings.EqualFold(caller, owner) {
+		sendError("Only " + owner + " can do this\n", http.StatusForbidden, &w)
+		return false
+	}
+
+	return true
+}
+
+// authorizePassword checks the caller of a password change regardless of enforcement: only
+// the user himself changes it. A user without a password can't log in, so his first
+// password is set by an admin, the only way to bring such an account under authentication.
+func (h *Handler) authorizePassword(w http.ResponseWriter, r *http.Request, nickname string, hasPassword bool) bool {
+	caller := auth.User(r.Context())
+
+	if !hasPassword && caller != "" && h.isAdmin(caller) {
+		return true
+	}
+
+	return h.requireUser(w, r, nickname)
+}
+
+func (h *Handler) AuthLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	creds := models.Credentials{}
+
+	if err := json.Unmarshal(body, &creds); err != nil {
+		sendError("Bad credentials\n", http.StatusBadRequest, &w)
+		return
+	}
+
+	hash, err := h.store.Users.PasswordHash(r.Context(), creds.Nickname)
+	if err != nil && err != store.ErrNotFound {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	// Одинаковый ответ для неизвестного ника и неверного пароля
+	if hash == "" || !auth.CheckPassword(hash, creds.Password) {
+		sendError("Wrong nickname or password\n", http.StatusUnauthorized, &w)
+		return
+	}
+
+	token, ok := h.issueToken(w, r, creds.Nickname, store.SessionLogin, h.opts.Auth.SessionTTL)
+	if !ok {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token.Token,
+		Path:     "/",
+		Expires:  token.Expires,
+		HttpOnly: true,
+		Secure:   h.opts.Auth.CookieSecure,
+	})
+
+	sendJSON(token, http.StatusOK, &w)
+}
+
+func (h *Handler) AuthLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		return
+	}
+
+	if id, ok := h.opts.Auth.Signer.Verify(requestToken(r)); ok {
+		if err := h.store.Sessions.Delete(r.Context(), id); err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   h.opts.Auth.CookieSecure,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// AuthToken issues a long-lived API token to the authenticated caller.
+func (h *Handler) AuthToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		return
+	}
+
+	caller := auth.User(r.Context())
+	if caller == "" {
+		sendError("Authentication required\n", http.StatusUnauthorized, &w)
+		return
+	}
+
+	token, ok := h.issueToken(w, r, caller, store.SessionToken, h.opts.Auth.TokenTTL)
+	if !ok {
+		return
+	}
+
+	sendJSON(token, http.StatusCreated, &w)
+}
+
+func (h *Handler) issueToken(w http.ResponseWriter, r *http.Request, nickname, kind string, ttl time.Duration) (*models.Token, bool) {
+	token, id, err := h.opts.Auth.Signer.Issue()
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return nil, false
+	}
+
+	session := &store.Session{
+		Id:       id,
+		Nickname: nickname,
+		Kind:     kind,
+		Expires:  time.Now().Add(ttl),
+	}
+
+	err = h.store.Sessions.Create(r.Context(), session)
+	if err == store.ErrNotFound {
+		sendError("Wrong nickname or password\n", http.StatusUnauthorized, &w)
+		return nil, false
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return nil, false
+	}
+
+	return &models.Token{Token: token, Nickname: session.Nickname, Expires: session.Expires}, true
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/auth"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+type authServer struct {
+	t      *testing.T
+	store  *store.Store
+	signer *auth.Signer
+	router *mux.Router
+}
+
+// newAuthServer has user u with password "secret" and the auth routes behind Authenticate.
+func newAuthServer(t *testing.T) *authServer {
+	s := store.NewMemory()
+
+	hash, err := auth.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Users.Create(context.Background(), &models.User{NickName: "u", Email: "u@mail.ru", FullName: "u", PasswordHash: hash}); err != nil {
+		t.Fatal(err)
+	}
+
+	signer := auth.NewSigner([]byte("key"))
+	h := New(s, Options{Auth: AuthOptions{Signer: signer, SessionTTL: time.Hour, TokenTTL: time.Hour}})
+
+	r := mux.NewRouter()
+	r.Use(h.Authenticate)
+	r.HandleFunc("/api/auth/login", h.AuthLogin)
+	r.HandleFunc("/api/auth/logout", h.AuthLogout)
+	r.HandleFunc("/api/auth/token", h.AuthToken)
+	r.HandleFunc("/api/user/{nickname}/profile", h.UserProfile)
+
+	return &authServer{t: t, store: s, signer: signer, router: r}
+}
+
+func (s *authServer) post(path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func (s *authServer) login(password string) (string, int) {
+	rec := s.post("/api/auth/login", "", `{"nickname": "u", "password": "`+password+`"}`)
+	if rec.Code != http.StatusOK {
+		return "", rec.Code
+	}
+
+	var token models.Token
+	if err := json.Unmarshal(rec.Body.Bytes(), &token); err != nil {
+		s.t.Fatal(err)
+	}
+
+	return token.Token, rec.Code
+}
+
+// caller tells whether token authenticates: AuthToken answers 401 to anonymous requests.
+func (s *authServer) caller(token string) bool {
+	return s.post("/api/auth/token", token, "").Code == http.StatusCreated
+}
+
+func TestLogin(t *testing.T) {
+	s := newAuthServer(t)
+
+	if _, code := s.login("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong password: got %d", code)
+	}
+	if rec := s.post("/api/auth/login", "", `{"nickname": "nobody", "password": "secret"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown user: got %d", rec.Code)
+	}
+
+	token, code := s.login("secret")
+	if code != http.StatusOK {
+		t.Fatalf("login: got %d", code)
+	}
+	if !s.caller(token) {
+		t.Errorf("token of the login is rejected")
+	}
+}
+
+func TestForgedAndExpiredTokens(t *testing.T) {
+	s := newAuthServer(t)
+
+	token, _ := s.login("secret")
+	dot := strings.LastIndex(token, ".")
+
+	forged := token[:dot+1] + "A" + token[dot+2:]
+	if forged == token {
+		forged = token[:dot+1] + "B" + token[dot+2:]
+	}
+	if s.caller(forged) {
+		t.Errorf("forged token is accepted")
+	}
+
+	other, _, _ := auth.NewSigner([]byte("other key")).Issue()
+	if s.caller(other) {
+		t.Errorf("token of another key is accepted")
+	}
+
+	// Подпись верна, но сессия истекла
+	expired, id, err := s.signer.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &store.Session{Id: id, Nickname: "u", Kind: store.SessionLogin, Expires: time.Now().Add(-time.Second)}
+	if err := s.store.Sessions.Create(context.Background(), session); err != nil {
+		t.Fatal(err)
+	}
+	if s.caller(expired) {
+		t.Errorf("expired token is accepted")
+	}
+
+	// Подпись верна, но сессии нет
+	unknown, _, _ := s.signer.Issue()
+	if s.caller(unknown) {
+		t.Errorf("token without a session is accepted")
+	}
+}
+
+func TestLogout(t *testing.T) {
+	s := newAuthServer(t)
+
+	token, _ := s.login("secret")
+	other, _ := s.login("secret")
+
+	if rec := s.post("/api/auth/logout", token, ""); rec.Code != http.StatusOK {
+		t.Fatalf("logout: got %d", rec.Code)
+	}
+
+	if s.caller(token) {
+		t.Errorf("logged out token is accepted")
+	}
+	if !s.caller(other) {
+		t.Errorf("logout ended another session")
+	}
+}
+
+func TestPasswordChangeEndsSessions(t *testing.T) {
+	s := newAuthServer(t)
+
+	token, _ := s.login("secret")
+	other, _ := s.login("secret")
+
+	rec := s.post("/api/auth/token", other, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("api token: got %d", rec.Code)
+	}
+	var api models.Token
+	if err := json.Unmarshal(rec.Body.Bytes(), &api); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := s.post("/api/user/u/profile", token, `{"password": "new secret"}`); rec.Code != http.StatusOK {
+		t.Fatalf("password change: got %d %s", rec.Code, rec.Body)
+	}
+
+	for name, old := range map[string]string{"current": token, "other": other, "api": api.Token} {
+		if s.caller(old) {
+			t.Errorf("%s token works after the password change", name)
+		}
+	}
+
+	if _, code := s.login("secret"); code != http.StatusUnauthorized {
+		t.Errorf("old password: got %d", code)
+	}
+	if _, code := s.login("new secret"); code != http.StatusOK {
+		t.Errorf("new password: got %d", code)
+	}
+
+	// Без пароля профиль меняется без отзыва сессий
+	token, _ = s.login("new secret")
+	if rec := s.post("/api/user/u/profile", token, `{"about": "a"}`); rec.Code != http.StatusOK {
+		t.Fatalf("profile change: got %d", rec.Code)
+	}
+	if !s.caller(token) {
+		t.Errorf("profile change without a password ended the session")
+	}
+}
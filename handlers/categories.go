@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Grisha23/ForumsApi/auth"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+// CategoryCreate adds a category, admins only when auth is enforced.
+func (h *Handler) CategoryCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	category := new(models.Category)
+
+	if err := json.Unmarshal(body, category); err != nil || category.Slug == "" || category.Title == "" {
+		sendError("Bad category, expected {\"slug\": ..., \"title\": ...}\n", http.StatusBadRequest, &w)
+		return
+	}
+
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	err = h.store.Categories.Create(r.Context(), category)
+
+	if err == store.ErrConflict {
+		existing, err := h.store.Categories.Get(r.Context(), category.Slug)
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
+
+		sendJSON(existing, http.StatusConflict, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	sendJSON(category, http.StatusCreated, &w)
+}
+
+// CategoryDetails shows the category on GET, updates it on POST and deletes it on DELETE.
+// A category can be deleted only after all its forums.
+func (h *Handler) CategoryDetails(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	switch r.Method {
+	case http.MethodGet:
+		category, err := h.store.Categories.Get(r.Context(), slug)
+
+		if err == store.ErrNotFound {
+			sendError("Can't find category with slug " + slug + "\n", 404, &w)
+			return
+		}
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
+
+		sendJSON(category, http.StatusOK, &w)
+
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		update := new(models.CategoryUpdate)
+
+		if err := json.Unmarshal(body, update); err != nil {
+			sendError("Bad category update\n", http.StatusBadRequest, &w)
+			return
+		}
+
+		if !h.authorizeAdmin(w, r) {
+			return
+		}
+
+		category, err := h.store.Categories.Update(r.Context(), slug, update)
+
+		if err == store.ErrNotFound {
+			sendError("Can't find category with slug " + slug + "\n", 404, &w)
+			return
+		}
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
+
+		sendJSON(category, http.StatusOK, &w)
+
+	case http.MethodDelete:
+		if !h.authorizeAdmin(w, r) {
+			return
+		}
+
+		err := h.store.Categories.Delete(r.Context(), slug)
+
+		if err == store.ErrNotFound {
+			sendError("Can't find category with slug " + slug + "\n", 404, &w)
+			return
+		}
+		if err == store.ErrConflict {
+			sendError("Category " + slug + " still has forums\n", http.StatusConflict, &w)
+			return
+		}
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Categories lists categories by position without their forums, see Forums for the tree.
+func (h *Handler) Categories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.store.Categories.List(r.Context())
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	sendJSON(categories, http.StatusOK, &w)
+}
+
+// placeForum checks the category and parent of a new forum and makes their slugs canonical.
+// A sub-forum always goes to the category of its parent.
+func (h *Handler) placeForum(w http.ResponseWriter, r *http.Request, forum *models.Forum) bool {
+	if forum.Parent != "" {
+		parent, err := h.store.Forums.Get(r.Context(), forum.Parent)
+
+		if err == store.ErrNotFound {
+			sendError("Can't find parent forum with slug " + forum.Parent + "\n", 404, &w)
+			return false
+		}
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return false
+		}
+
+		if forum.Category != "" && !strings.EqualFold(forum.Category, parent.Category) {
+			sendError("Sub-forum must be in the category of forum " + parent.Slug + "\n", http.StatusBadRequest, &w)
+			return false
+		}
+
+		forum.Parent, forum.Category = parent.Slug, parent.Category
+		return true
+	}
+
+	if forum.Category != "" {
+		category, err := h.store.Categories.Get(r.Context(), forum.Category)
+
+		if err == store.ErrNotFound {
+			sendError("Can't find category with slug " + forum.Category + "\n", 404, &w)
+			return false
+		}
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return false
+		}
+
+		forum.Category = category.Slug
+	}
+
+	return true
+}
+
+// forumFamily fills the breadcrumbs, direct sub-forums and totals of the forum details.
+func (h *Handler) forumFamily(w http.ResponseWriter, r *http.Request, forum *models.Forum) bool {
+	ancestors, descendants, err := h.store.Forums.Family(r.Context(), forum.Slug)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return false
+	}
+
+	if forum.Category != "" {
+		category, err := h.store.Categories.Get(r.Context(), forum.Category)
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return false
+		}
+
+		forum.Breadcrumbs = append(forum.Breadcrumbs, models.Crumb{Kind: "category", Slug: category.Slug, Title: category.Title})
+	}
+	for _, a := range ancestors {
+		forum.Breadcrumbs = append(forum.Breadcrumbs, models.Crumb{Kind: "forum", Slug: a.Slug, Title: a.Title})
+	}
+
+	forum.Children = newForumTree(descendants).build(forum.Slug)
+	forum.Totals = &models.ForumTotals{Posts: forum.Posts, Threads: int64(forum.Threads)}
+
+	// В деталях только прямые подфорумы, их итоги уже учитывают всю глубину
+	for i := range forum.Children {
+		forum.Totals.Posts += forum.Children[i].Totals.Posts
+		forum.Totals.Threads += forum.Children[i].Totals.Threads
+		forum.Children[i].Children = nil
+	}
+
+	return true
+}
+
+// forumTree maps the slug of a parent in lower case, "" for roots, to its sub-forums.
+type forumTree map[string][]models.Forum
+
+func newForumTree(forums []models.Forum) forumTree {
+	tree := make(forumTree)
+	for _, f := range forums {
+		parent := strings.ToLower(f.Parent)
+		tree[parent] = append(tree[parent], f)
+	}
+	return tree
+}
+
+// build returns the sub-forums of parent with their own sub-forums and totals.
+func (t forumTree) build(parent string) []models.Forum {
+	children := t[strings.ToLower(parent)]
+	res := make([]models.Forum, 0, len(children))
+
+	for _, f := range children {
+		f.Children = t.build(f.Slug)
+		f.Totals = &models.ForumTotals{Posts: f.Posts, Threads: int64(f.Threads)}
+
+		for _, c := range f.Children {
+			f.Totals.Posts += c.Totals.Posts
+			f.Totals.Threads += c.Totals.Threads
+		}
+
+		res = append(res, f)
+	}
+
+	return res
+}
+
+// authorizeAdmin checks that the caller is one of the configured admins.
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !h.opts.Auth.Enforce {
+		return true
+	}
+
+	caller := auth.User(r.Context())
+	if caller == "" {
+		sendError("Authentication required\n", http.StatusUnauthorized, &w)
+		return false
+	}
+
+	if !h.isAdmin(caller) {
+		sendError("Only " + auth.RoleAdmin.String() + " can do this\n", http.StatusForbidden, &w)
+		return false
+	}
+
+	return true
+}
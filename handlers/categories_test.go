@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+// categoryServer has admin admin and user u, auth is enforced.
+func categoryServer(t *testing.T) (*mux.Router, *store.Store) {
+	s := store.NewMemory()
+
+	for _, nickname := range []string{"admin", "u"} {
+		if err := s.Users.Create(context.Background(), &models.User{NickName: nickname, Email: nickname + "@mail.ru", FullName: nickname}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := New(s, Options{Auth: AuthOptions{Enforce: true, Admins: []string{"admin"}}})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/categories", h.Categories)
+	r.HandleFunc("/api/category/create", h.CategoryCreate)
+	r.HandleFunc("/api/category/{slug}/details", h.CategoryDetails)
+	r.HandleFunc("/api/forum/create", h.ForumCreate)
+	r.HandleFunc("/api/forum/{slug}/details", h.ForumDetails)
+	r.HandleFunc("/api/forums", h.Forums)
+
+	return r, s
+}
+
+func decode(t *testing.T, body []byte, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	r, _ := categoryServer(t)
+
+	steps := []struct {
+		method string
+		path   string
+		caller string
+		body   string
+		code   int
+	}{
+		{"POST", "/api/category/create", "", `{"slug": "news", "title": "News"}`, http.StatusUnauthorized},
+		{"POST", "/api/category/create", "u", `{"slug": "news", "title": "News"}`, http.StatusForbidden},
+		{"POST", "/api/category/create", "admin", `{"slug": "news"}`, http.StatusBadRequest},
+		{"POST", "/api/category/create", "admin", `{"slug": "news", "title": "News", "position": 2}`, http.StatusCreated},
+		{"POST", "/api/category/create", "admin", `{"slug": "talk", "title": "Talk", "position": 1}`, http.StatusCreated},
+		{"POST", "/api/category/create", "admin", `{"slug": "NEWS", "title": "Other"}`, http.StatusConflict},
+		{"GET", "/api/category/NEWS/details", "", ``, http.StatusOK},
+		{"GET", "/api/category/none/details", "", ``, http.StatusNotFound},
+		{"POST", "/api/category/news/details", "u", `{"title": "Fresh"}`, http.StatusForbidden},
+		{"POST", "/api/category/news/details", "admin", `{"title": "Fresh", "position": 0}`, http.StatusOK},
+		{"POST", "/api/category/none/details", "admin", `{"title": "Fresh"}`, http.StatusNotFound},
+		{"POST", "/api/forum/create", "", `{"slug": "f", "title": "F", "user": "u", "category": "TALK"}`, http.StatusCreated},
+		{"DELETE", "/api/category/talk/details", "u", ``, http.StatusForbidden},
+		{"DELETE", "/api/category/talk/details", "admin", ``, http.StatusConflict},
+		{"DELETE", "/api/category/news/details", "admin", ``, http.StatusOK},
+		{"DELETE", "/api/category/news/details", "admin", ``, http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		if rec := request(r, step.method, step.path, step.caller, step.body); rec.Code != step.code {
+			t.Errorf("%s %s %s as %q: got %d, want %d: %s", step.method, step.path, step.body, step.caller, rec.Code, step.code, rec.Body)
+		}
+	}
+
+	rec := request(r, "POST", "/api/category/create", "admin", `{"slug": "News", "title": "News"}`)
+	var created models.Category
+	decode(t, rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusCreated || created.Slug != "News" {
+		t.Errorf("create after delete: got %d %s", rec.Code, rec.Body)
+	}
+
+	// Обновление позиции меняет порядок, при равной позиции - по slug
+	request(r, "POST", "/api/category/news/details", "admin", `{"position": 1, "description": "d"}`)
+
+	var categories []models.Category
+	decode(t, request(r, "GET", "/api/categories", "", "").Body.Bytes(), &categories)
+
+	if len(categories) != 2 || categories[0].Slug != "News" || categories[1].Slug != "talk" || categories[0].Description != "d" || categories[0].Forums != nil {
+		t.Errorf("list: got %+v", categories)
+	}
+
+	var forum models.Forum
+	decode(t, request(r, "GET", "/api/forum/f/details", "", "").Body.Bytes(), &forum)
+	if forum.Category != "talk" {
+		t.Errorf("category of the forum isn't canonical: %q", forum.Category)
+	}
+}
+
+// familyServer has category c with forum root, its sub-forums mid and side and mid's
+// sub-forum leaf, and forum loose without a category. Every forum has threads and posts.
+func familyServer(t *testing.T) *mux.Router {
+	r, s := categoryServer(t)
+
+	if rec := request(r, "POST", "/api/category/create", "admin", `{"slug": "c", "title": "C"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("category: got %d %s", rec.Code, rec.Body)
+	}
+
+	for _, f := range []struct {
+		body string
+		code int
+	}{
+		{`{"slug": "root", "title": "Root", "user": "u", "category": "C"}`, http.StatusCreated},
+		{`{"slug": "mid", "title": "Mid", "user": "u", "parent": "ROOT"}`, http.StatusCreated},
+		{`{"slug": "side", "title": "Side", "user": "u", "parent": "root", "category": "c"}`, http.StatusCreated},
+		{`{"slug": "leaf", "title": "Leaf", "user": "u", "parent": "mid"}`, http.StatusCreated},
+		{`{"slug": "loose", "title": "Loose", "user": "u"}`, http.StatusCreated},
+		{`{"slug": "x", "title": "X", "user": "u", "parent": "none"}`, http.StatusNotFound},
+		{`{"slug": "x", "title": "X", "user": "u", "category": "none"}`, http.StatusNotFound},
+		{`{"slug": "x", "title": "X", "user": "u", "parent": "loose", "category": "c"}`, http.StatusBadRequest},
+	} {
+		if rec := request(r, "POST", "/api/forum/create", "", f.body); rec.Code != f.code {
+			t.Fatalf("%s: got %d, want %d: %s", f.body, rec.Code, f.code, rec.Body)
+		}
+	}
+
+	// Ветки и сообщения: root 1/1, mid 1/2, side 1/0, leaf 2/3, loose 1/1
+	ctx := context.Background()
+	for _, f := range []struct {
+		slug    string
+		threads int
+		posts   int
+	}{
+		{"root", 1, 1}, {"mid", 1, 2}, {"side", 1, 0}, {"leaf", 2, 3}, {"loose", 1, 1},
+	} {
+		var thr *models.Thread
+		for i := 0; i < f.threads; i++ {
+			thr = &models.Thread{Author: "u", Title: "t", Message: "m"}
+			if err := s.Threads.Create(ctx, f.slug, thr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < f.posts; i++ {
+			if _, err := s.Posts.Create(ctx, thr, []models.Post{{Author: "u", Message: "m"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return r
+}
+
+func slugs(forums []models.Forum) []string {
+	res := make([]string, len(forums))
+	for i, f := range forums {
+		res[i] = f.Slug
+	}
+	return res
+}
+
+func TestForumFamily(t *testing.T) {
+	r := familyServer(t)
+
+	var root models.Forum
+	decode(t, request(r, "GET", "/api/forum/root/details", "", "").Body.Bytes(), &root)
+
+	if len(root.Breadcrumbs) != 1 || root.Breadcrumbs[0] != (models.Crumb{Kind: "category", Slug: "c", Title: "C"}) {
+		t.Errorf("root breadcrumbs: %+v", root.Breadcrumbs)
+	}
+	// Только прямые подфорумы, но с итогами всей глубины
+	if got := slugs(root.Children); len(got) != 2 || got[0] != "mid" || got[1] != "side" {
+		t.Fatalf("root children: %q", got)
+	}
+	mid := root.Children[0]
+	if mid.Children != nil || mid.Totals == nil || *mid.Totals != (models.ForumTotals{Posts: 5, Threads: 3}) {
+		t.Errorf("mid in root details: %+v", mid)
+	}
+	if root.Posts != 1 || root.Threads != 1 || *root.Totals != (models.ForumTotals{Posts: 6, Threads: 5}) {
+		t.Errorf("root counters: %d/%d, totals %+v", root.Posts, root.Threads, root.Totals)
+	}
+
+	var leaf models.Forum
+	decode(t, request(r, "GET", "/api/forum/LEAF/details", "", "").Body.Bytes(), &leaf)
+
+	want := []models.Crumb{
+		{Kind: "category", Slug: "c", Title: "C"},
+		{Kind: "forum", Slug: "root", Title: "Root"},
+		{Kind: "forum", Slug: "mid", Title: "Mid"},
+	}
+	if len(leaf.Breadcrumbs) != len(want) {
+		t.Fatalf("leaf breadcrumbs: %+v", leaf.Breadcrumbs)
+	}
+	for i := range want {
+		if leaf.Breadcrumbs[i] != want[i] {
+			t.Errorf("leaf crumb %d: got %+v, want %+v", i, leaf.Breadcrumbs[i], want[i])
+		}
+	}
+	if leaf.Category != "c" || leaf.Parent != "mid" || len(leaf.Children) != 0 || *leaf.Totals != (models.ForumTotals{Posts: 3, Threads: 2}) {
+		t.Errorf("leaf: %+v", leaf)
+	}
+
+	var loose models.Forum
+	decode(t, request(r, "GET", "/api/forum/loose/details", "", "").Body.Bytes(), &loose)
+	if loose.Breadcrumbs != nil || *loose.Totals != (models.ForumTotals{Posts: 1, Threads: 1}) {
+		t.Errorf("loose: %+v", loose)
+	}
+}
+
+func TestForumIndex(t *testing.T) {
+	r := familyServer(t)
+	request(r, "POST", "/api/category/create", "admin", `{"slug": "empty", "title": "Empty", "position": 1}`)
+
+	for _, path := range []string{"/api/forums", "/api/forums?view=tree"} {
+		rec := request(r, "GET", path, "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %s", path, rec.Code, rec.Body)
+		}
+
+		var index models.ForumIndex
+		decode(t, rec.Body.Bytes(), &index)
+
+		if len(index.Categories) != 2 || index.Categories[0].Slug != "c" || index.Categories[1].Slug != "empty" {
+			t.Fatalf("%s: categories %+v", path, index.Categories)
+		}
+
+		c := index.Categories[0]
+		if got := slugs(c.Forums); len(got) != 1 || got[0] != "root" || *c.Totals != (models.ForumTotals{Posts: 6, Threads: 5}) {
+			t.Errorf("%s: category c %q, totals %+v", path, got, c.Totals)
+		}
+		// В дереве подфорумы всей глубины
+		if mid := c.Forums[0].Children[0]; mid.Slug != "mid" || len(mid.Children) != 1 || mid.Children[0].Slug != "leaf" {
+			t.Errorf("%s: mid %+v", path, mid)
+		}
+
+		if empty := index.Categories[1]; empty.Forums != nil || *empty.Totals != (models.ForumTotals{}) {
+			t.Errorf("%s: empty category %+v", path, empty)
+		}
+		if got := slugs(index.Forums); len(got) != 1 || got[0] != "loose" {
+			t.Errorf("%s: forums without a category %q", path, got)
+		}
+	}
+
+	if rec := request(r, "GET", "/api/forums?view=flat", "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad view: got %d", rec.Code)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Grisha23/ForumsApi/auth"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+// deleteThread handles DELETE on the thread details, allowed to the author and moderators.
+func (h *Handler) deleteThread(w http.ResponseWriter, r *http.Request, slugOrId string) {
+	thr, err := h.store.Threads.Get(r.Context(), slugOrId)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	if !h.authorizeEdit(w, r, thr.Forum, thr.Author) {
+		return
+	}
+
+	deleted, err := h.store.Threads.Delete(r.Context(), slugOrId)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	sendJSON(deleted, http.StatusOK, &w)
+}
+
+// deletePost handles DELETE on the post details. Replies stay in the tree under a tombstone.
+func (h *Handler) deletePost(w http.ResponseWriter, r *http.Request, postId int64) {
+	id := strconv.FormatInt(postId, 10)
+
+	post, err := h.store.Posts.Get(r.Context(), postId)
+
+	if err == store.ErrNotFound || err == nil && post.IsDeleted {
+		sendError("Can't find post with id " + id + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	if !h.authorizeEdit(w, r, post.Forum, post.Author) {
+		return
+	}
+
+	deleted, err := h.store.Posts.Delete(r.Context(), postId)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find post with id " + id + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	sendJSON(deleted, http.StatusOK, &w)
+}
+
+// ThreadRestore brings back a deleted thread, moderators and above only.
+func (h *Handler) ThreadRestore(w http.ResponseWriter, r *http.Request) {
+	slugOrId := mux.Vars(r)["slug_or_id"]
+
+	thr, err := h.store.Threads.Deleted(r.Context(), slugOrId)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find deleted thread with id " + slugOrId + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	if !h.authorizeModerator(w, r, thr.Forum) {
+		return
+	}
+
+	restored, err := h.store.Threads.Restore(r.Context(), slugOrId)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find deleted thread with id " + slugOrId + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	sendJSON(restored, http.StatusOK, &w)
+}
+
+// PostRestore brings back a deleted post, moderators and above only.
+func (h *Handler) PostRestore(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	postId, err := strconv.ParseInt(id, 10, 64)
+
+	if err != nil {
+		sendError("Can't find deleted post with id " + id + "\n", 404, &w)
+		return
+	}
+
+	post, err := h.store.Posts.Get(r.Context(), postId)
+
+	if err == store.ErrNotFound || err == nil && !post.IsDeleted {
+		sendError("Can't find deleted post with id " + id + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	if !h.authorizeModerator(w, r, post.Forum) {
+		return
+	}
+
+	restored, err := h.store.Posts.Restore(r.Context(), postId)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find deleted post with id " + id + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	sendJSON(restored, http.StatusOK, &w)
+}
+
+// authorizeModerator checks that the caller is a moderator of the forum or above.
+func (h *Handler) authorizeModerator(w http.ResponseWriter, r *http.Request, forumSlug string) bool {
+	if !h.opts.Auth.Enforce {
+		return true
+	}
+
+	caller := auth.User(r.Context())
+	if caller == "" {
+		sendError("Authentication required\n", http.StatusUnauthorized, &w)
+		return false
+	}
+
+	forum, err := h.store.Forums.Get(r.Context(), forumSlug)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return false
+	}
+
+	role, err := h.forumRole(r.Context(), forum, caller)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return false
+	}
+
+	if role < auth.RoleModerator {
+		sendError("Only " + auth.RoleModerator.String() + " and above can do this\n", http.StatusForbidden, &w)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+func TestPostDeleteAndRestore(t *testing.T) {
+	r := editServer(t)
+
+	if rec := request(r, http.MethodPost, "/api/thread/1/create", "a", `[{"author": "a", "message": "reply", "parent": 1}]`); rec.Code != http.StatusCreated {
+		t.Fatalf("reply: got %d %s", rec.Code, rec.Body)
+	}
+
+	for caller, code := range map[string]int{"": http.StatusUnauthorized, "other": http.StatusForbidden} {
+		if rec := request(r, http.MethodDelete, "/api/post/1/details", caller, ""); rec.Code != code {
+			t.Errorf("delete as %q: got %d, want %d", caller, rec.Code, code)
+		}
+	}
+
+	rec := request(r, http.MethodDelete, "/api/post/1/details", "a", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"isDeleted":true`) {
+		t.Fatalf("delete: got %d %s", rec.Code, rec.Body)
+	}
+	if rec := request(r, http.MethodDelete, "/api/post/1/details", "a", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete: got %d", rec.Code)
+	}
+	if rec := request(r, http.MethodPost, "/api/post/1/details", "a", `{"message": "x"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("edit of a deleted post: got %d", rec.Code)
+	}
+
+	// В дереве ответ остается под надгробием, в плоском списке удаленного поста нет
+	for _, sort := range []string{"flat", "tree", "parent_tree"} {
+		rec := request(r, http.MethodGet, "/api/thread/1/posts?sort="+sort, "", "")
+
+		var posts []models.Post
+		if err := json.Unmarshal(rec.Body.Bytes(), &posts); err != nil {
+			t.Fatalf("%s: %v %s", sort, err, rec.Body)
+		}
+
+		if sort == "flat" {
+			if len(posts) != 1 || posts[0].Message != "reply" {
+				t.Errorf("%s: got %+v", sort, posts)
+			}
+			continue
+		}
+		if len(posts) != 2 || !posts[0].IsDeleted || posts[0].Message != "" || posts[0].Author != "" || posts[1].Message != "reply" {
+			t.Errorf("%s: got %+v", sort, posts)
+		}
+	}
+
+	checkCounters(t, r, 1, 1)
+
+	// Восстанавливают только модераторы
+	for caller, code := range map[string]int{"": http.StatusUnauthorized, "a": http.StatusForbidden, "other": http.StatusForbidden} {
+		if rec := request(r, http.MethodPost, "/api/post/1/restore", caller, ""); rec.Code != code {
+			t.Errorf("restore as %q: got %d, want %d", caller, rec.Code, code)
+		}
+	}
+
+	rec = request(r, http.MethodPost, "/api/post/1/restore", "m", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"message":"one\ntwo"`) {
+		t.Fatalf("restore: got %d %s", rec.Code, rec.Body)
+	}
+	for _, path := range []string{"/api/post/1/restore", "/api/post/2/restore", "/api/post/9/restore", "/api/post/x/restore"} {
+		if rec := request(r, http.MethodPost, path, "m", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d", path, rec.Code)
+		}
+	}
+
+	checkCounters(t, r, 1, 2)
+}
+
+func TestThreadDeleteAndRestore(t *testing.T) {
+	r := editServer(t)
+
+	if rec := request(r, http.MethodDelete, "/api/thread/1/details", "other", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("delete as other: got %d", rec.Code)
+	}
+
+	rec := request(r, http.MethodDelete, "/api/thread/1/details", "m", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"isDeleted":true`) {
+		t.Fatalf("delete: got %d %s", rec.Code, rec.Body)
+	}
+
+	for _, step := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodDelete, "/api/thread/1/details", ""},
+		{http.MethodGet, "/api/thread/1/details", ""},
+		{http.MethodGet, "/api/thread/1/posts", ""},
+		{http.MethodPost, "/api/thread/1/create", `[{"author": "a", "message": "m"}]`},
+	} {
+		if rec := request(r, step.method, step.path, "a", step.body); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s of a deleted thread: got %d", step.method, step.path, rec.Code)
+		}
+	}
+
+	checkCounters(t, r, 0, 0)
+
+	if rec := request(r, http.MethodPost, "/api/thread/1/restore", "a", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("restore by the author: got %d", rec.Code)
+	}
+	if rec := request(r, http.MethodPost, "/api/thread/1/restore", "owner", ""); rec.Code != http.StatusOK {
+		t.Fatalf("restore: got %d %s", rec.Code, rec.Body)
+	}
+	if rec := request(r, http.MethodPost, "/api/thread/1/restore", "owner", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second restore: got %d", rec.Code)
+	}
+
+	// Посты ветки возвращаются вместе с ней
+	rec = request(r, http.MethodGet, "/api/thread/1/posts", "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"message":"one\ntwo"`) {
+		t.Errorf("posts: got %d %s", rec.Code, rec.Body)
+	}
+
+	checkCounters(t, r, 1, 1)
+}
+
+// checkCounters compares the counters of forum f.
+func checkCounters(t *testing.T, r http.Handler, threads int32, posts int64) {
+	t.Helper()
+
+	rec := request(r, http.MethodGet, "/api/forum/f/details", "", "")
+
+	var forum models.Forum
+	if err := json.Unmarshal(rec.Body.Bytes(), &forum); err != nil {
+		t.Fatal(err)
+	}
+
+	if forum.Threads != threads || forum.Posts != posts {
+		t.Errorf("forum has %d threads and %d posts, want %d and %d", forum.Threads, forum.Posts, threads, posts)
+	}
+}
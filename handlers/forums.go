@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/cursor"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+)
+
+// Forums answers the forum tree by default. With view=list, or a cursor, it lists forums
+// as a page with limit, sort (title, created, posts or threads), desc, owner and q, the
+// case-insensitive substring of the title.
+func (h *Handler) Forums(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch view := query.Get("view"); {
+	case view == "list" || query.Get("cursor") != "":
+		h.forumList(w, r)
+	case view == "" || view == "tree":
+		h.forumIndex(w, r)
+	default:
+		sendError("Bad view " + view + "\n", 400, &w)
+	}
+}
+
+// forumIndex answers the whole forum tree: categories with their root forums and sub-forums,
+// then the root forums without a category. Every forum carries totals of its subtree.
+func (h *Handler) forumIndex(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.store.Categories.List(r.Context())
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	forums, err := h.store.Forums.All(r.Context())
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	index := models.ForumIndex{Categories: categories, Forums: make([]models.Forum, 0)}
+	byCategory := make(map[string]int, len(categories))
+
+	for i := range index.Categories {
+		index.Categories[i].Totals = new(models.ForumTotals)
+		byCategory[strings.ToLower(index.Categories[i].Slug)] = i
+	}
+
+	for _, root := range newForumTree(forums).build("") {
+		i, ok := byCategory[strings.ToLower(root.Category)]
+		if !ok {
+			index.Forums = append(index.Forums, root)
+			continue
+		}
+
+		category := &index.Categories[i]
+		category.Forums = append(category.Forums, root)
+		category.Totals.Posts += root.Totals.Posts
+		category.Totals.Threads += root.Totals.Threads
+	}
+
+	sendJSON(index, http.StatusOK, &w)
+}
+
+func (h *Handler) forumList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := store.ForumFilter{
+		Sort:  query.Get("sort"),
+		Owner: query.Get("owner"),
+		Query: query.Get("q"),
+		Desc:  query.Get("desc") == "true",
+	}
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = store.ForumsByTitle
+	case store.ForumsByTitle, store.ForumsByCreated, store.ForumsByPosts, store.ForumsByThreads:
+	default:
+		sendError("Bad sort " + filter.Sort + "\n", 400, &w)
+		return
+	}
+
+	if limitVal := query.Get("limit"); limitVal != "" {
+		var err error
+
+		filter.Limit, err = strconv.Atoi(limitVal)
+		if err != nil {
+			sendError("Bad limit " + limitVal + "\n", 400, &w)
+			return
+		}
+	}
+
+	pg, ok := newPager(w, r, "forums", filter.Sort, filter.Desc, filter.Limit, "owner", "q")
+	if !ok {
+		return
+	}
+
+	filter.Sort, filter.Desc, filter.Limit = pg.sort, pg.readDesc(), pg.fetchLimit()
+
+	if key := pg.key(); key != nil {
+		after, err := parseForumKey(filter.Sort, key)
+		if err != nil {
+			sendError("Bad cursor " + query.Get("cursor") + "\n", 400, &w)
+			return
+		}
+
+		filter.After = after
+	}
+
+	forums, err := h.store.Forums.List(r.Context(), filter)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	pg.send(w, forums, everyItem(len(forums)), func(item interface{}) []string {
+		return forumKey(filter.Sort, item.(models.Forum))
+	})
+}
+
+func forumKey(sort string, forum models.Forum) []string {
+	var value string
+
+	switch sort {
+	case store.ForumsByCreated:
+		value = forum.Created.Format(time.RFC3339Nano)
+	case store.ForumsByPosts:
+		value = strconv.FormatInt(forum.Posts, 10)
+	case store.ForumsByThreads:
+		value = strconv.FormatInt(int64(forum.Threads), 10)
+	default:
+		value = forum.Title
+	}
+
+	return []string{value, forum.Slug}
+}
+
+func parseForumKey(sort string, key []string) (*store.ForumKey, error) {
+	if len(key) != 2 {
+		return nil, cursor.ErrInvalid
+	}
+
+	k := &store.ForumKey{Slug: key[1]}
+	var err error
+
+	switch sort {
+	case store.ForumsByCreated:
+		k.Created, err = time.Parse(time.RFC3339Nano, key[0])
+	case store.ForumsByPosts, store.ForumsByThreads:
+		k.Number, err = strconv.ParseInt(key[0], 10, 64)
+	default:
+		k.Title = key[0]
+	}
+
+	return k, err
+}
@@ -1,117 +1,144 @@
 package handlers
 
 import (
+	"github.com/Grisha23/ForumsApi/auth"
+	"github.com/Grisha23/ForumsApi/logging"
 	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
 	// "ForumsApi/models"
-	"database/sql"
+	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/gorilla/mux"
-	"github.com/lib/pq"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
-)
-var globalCount = 0
-
-const (
-	DbUser     = "front"
-	DbPassword = "123"
-	DbName     = "forumapi"
-	host 	   = "10.154.0.2"
-
-	// DbUser     = "docker"
-	// DbPassword = "docker"
-	// DbName     = "docker"
-	// DbUser     = "tpforumsapi"
-	// DbPassword = "222"
-	// DbName = "forums_func"
-	// DbName = "forums"
+	"time"
 )
 
-var db *sql.DB
+type Handler struct {
+	store    *store.Store
+	opts     Options
+	draining int32 // 1 после начала остановки сервера, readyz отвечает 503
+}
 
-func InitDb() (*sql.DB, error) {
-	var err error
-	dbinfo := fmt.Sprintf("user=%s password=%s dbname=%s host=%s sslmode=disable", // Need host in docker
-		DbUser, DbPassword, DbName, host)
-		
-	db, err = sql.Open("postgres", dbinfo)
-	if err != nil {
-		panic(err)
-	}
+type Options struct {
+	StatusMode string // Режим /api/service/status без параметра mode: store.StatusCounters, StatusExact или StatusEstimate
+	Auth       AuthOptions
+	Log        *logging.Logger // Для ошибок вне access log, nil - logging.Discard
+}
 
-	if err = db.Ping(); err != nil {
-		panic(err)
+func New(s *store.Store, opts Options) *Handler {
+	if opts.StatusMode == "" {
+		opts.StatusMode = store.StatusCounters
+	}
+	if opts.Log == nil {
+		opts.Log = logging.Discard
 	}
+	return &Handler{store: s, opts: opts}
+}
 
-	// init, err := ioutil.ReadFile("./forum.sql")
-	// _, err = db.Exec(string(init))
+func sendError(errText string, statusCode int, w *http.ResponseWriter) ([]byte, error){
+	e := new(models.Error)
+	e.Message = errText
+	resp, _ := json.Marshal(e)
 
-	// if err != nil {
-	// 	panic(err)
-	// }
+	// Проверка err json
 
-	fmt.Println("You connected to your database.")
+	(*w).Header().Set("content-type", "application/json")
+	(*w).WriteHeader(statusCode)
+	(*w).Write(resp)
 
-	return db, nil
+	return resp, nil
 }
 
-func getUser(nickname string, t *sql.Tx) (*models.User, error) {
-	if nickname == "" {
-		return nil, nil
+func sendJSON(data interface{}, statusCode int, w *http.ResponseWriter) {
+	resp, err := json.Marshal(data)
+
+	if err != nil {
+		recordError(err, w)
+		(*w).WriteHeader(http.StatusInternalServerError)
+		return
 	}
-	var row *sql.Row
-	//if t == nil {
-		row = db.QueryRow("SELECT about,email,fullname,nickname FROM users WHERE nickname=$1", nickname)
-	//} else {
-	//	row = t.QueryRow("SELECT about,email,fullname,nickname FROM users WHERE nickname=$1", nickname)
-	//}
 
+	(*w).Header().Set("content-type", "application/json")
+	(*w).WriteHeader(statusCode)
+	(*w).Write(resp)
+}
+
+// ErrorRecorder is implemented by the ResponseWriter of the access log middleware,
+// so the cause of a 500 ends up in the request's log record.
+type ErrorRecorder interface {
+	RecordError(err error)
+}
 
-	user := models.User{}
+func recordError(err error, w *http.ResponseWriter) {
+	if rec, ok := (*w).(ErrorRecorder); ok {
+		rec.RecordError(err)
+	}
+}
 
-	err := row.Scan(&user.About, &user.Email, &user.FullName, &user.NickName)
+// sendInternalError answers 504 if the request ran out of time and 503 if the client
+// went away (the statement was cancelled with the request context), 500 otherwise.
+func sendInternalError(err error, w *http.ResponseWriter, r *http.Request) {
+	recordError(err, w)
 
-	if err != nil {
-		return nil, err
+	switch r.Context().Err() {
+	case context.DeadlineExceeded:
+		sendError("Request timed out\n", http.StatusGatewayTimeout, w)
+		return
+	case context.Canceled:
+		sendError("Request canceled\n", http.StatusServiceUnavailable, w)
+		return
 	}
 
-	return &user, nil
+	(*w).WriteHeader(http.StatusInternalServerError)
 }
 
-func sendError(errText string, statusCode int, w *http.ResponseWriter) ([]byte, error){
-	e := new(models.Error)
-	e.Message = errText
-	resp, _ := json.Marshal(e)
-
-	// Проверка err json
+// WithTimeout sets the deadline of the request context, the store cancels
+// running statements when it expires. Zero d means no deadline.
+func WithTimeout(d time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	if d <= 0 {
+		return next
+	}
 
-	(*w).Header().Set("content-type", "application/json")
-	(*w).WriteHeader(statusCode)
-	(*w).Write(resp)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
 
-	return resp, nil
+		next(w, r.WithContext(ctx))
+	}
 }
 
-func UserProfile(w http.ResponseWriter, r *http.Request)  {
+func (h *Handler) UserProfile(w http.ResponseWriter, r *http.Request)  {
 	vars := mux.Vars(r)
 	nickname := vars["nickname"]
 
 	if r.Method == http.MethodGet{
-		user, err := getUser(nickname, nil)
+		user, err := h.store.Users.Get(r.Context(), nickname)
 
-		if err != nil {
+		if err == store.ErrNotFound {
 			sendError("Can't find user with nickname " + nickname + "\n", 404, &w)
 			return
 		}
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
 
-		resp, _ := json.Marshal(user)
-		w.Header().Set("content-type", "application/json")
+		for _, item := range strings.Split(r.URL.Query().Get("related"), ",") {
+			if item != "stats" {
+				continue
+			}
 
-		w.Write(resp)
+			user.Stats, err = h.store.Users.Stats(r.Context(), nickname)
+			if err != nil {
+				sendInternalError(err, &w, r)
+				return
+			}
+		}
 
+		sendJSON(user, http.StatusOK, &w)
 		return
 	}
 
@@ -132,85 +159,59 @@ func UserProfile(w http.ResponseWriter, r *http.Request)  {
 		return
 	}
 
+	if !h.authorize(w, r, nickname) {
+		return
+	}
 
-	about := false
-	fullname := false
-	email := false
+	if userUpdate.Password != "" {
+		current, err := h.store.Users.PasswordHash(r.Context(), nickname)
 
-	if userUpdate.About != ""{
-		about = true
-	}
-	if userUpdate.FullName != ""{
-		fullname = true
-	}
-	if userUpdate.Email != ""{
-		email = true
-	}
+		if err == store.ErrNotFound {
+			sendError("Can't find prifile with id " + nickname + "\n", 404, &w)
+			return
+		}
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
 
-	if !email && !fullname && !about {
-		user, err := getUser(nickname, nil)
+		if !h.authorizePassword(w, r, nickname, current != "") {
+			return
+		}
 
+		userUpdate.PasswordHash, err = auth.HashPassword(userUpdate.Password)
 		if err != nil {
-			sendError("Can't find prifile with id " + nickname + "\n", 404, &w)
+			sendInternalError(err, &w, r)
+			return
 		}
+	}
 
-		resp, _ := json.Marshal(user)
-		w.Header().Set("content-type", "application/json")
+	userUpdate.Password = ""
 
-		w.Write(resp)
+	user, err := h.store.Users.Update(r.Context(), nickname, &userUpdate)
 
+	if err == store.ErrNotFound {
+		sendError("Can't find prifile with id " + nickname + "\n", 404, &w)
 		return
 	}
-	var query string
-	var row *sql.Row
-
-	if about && fullname && email {
-		query = "UPDATE users SET about=$1, fullname=$2, email=$3 WHERE nickname=$4 RETURNING about,email,fullname,nickname"
-		row = db.QueryRow(query, userUpdate.About, userUpdate.FullName, userUpdate.Email, nickname)
-	} else if about && fullname && !email {
-		query = "UPDATE users SET about=$1, fullname=$2 WHERE nickname=$3 RETURNING about,email,fullname,nickname"
-		row = db.QueryRow(query, userUpdate.About, userUpdate.FullName, nickname)
-	} else if about && !fullname && email {
-		query = "UPDATE users SET about=$1, email=$2 WHERE nickname=$3 RETURNING about,email,fullname,nickname"
-		row = db.QueryRow(query, userUpdate.About, userUpdate.Email, nickname)
-	} else if about && !fullname && !email {
-		query = "UPDATE users SET about=$1 WHERE nickname=$2 RETURNING about,email,fullname,nickname"
-		row = db.QueryRow(query, userUpdate.About, nickname)
-	} else if !about && fullname && email {
-		query = "UPDATE users SET fullname=$1, email=$2 WHERE nickname=$3 RETURNING about,email,fullname,nickname"
-		row = db.QueryRow(query, userUpdate.FullName, userUpdate.Email, nickname)
-	} else if !about && fullname && !email {
-		query = "UPDATE users SET fullname=$1 WHERE nickname=$2 RETURNING about,email,fullname,nickname"
-		row = db.QueryRow(query, userUpdate.FullName, nickname)
-	} else if !about && !fullname && email {
-		query = "UPDATE users SET email=$1 WHERE nickname=$2 RETURNING about,email,fullname,nickname"
-		row = db.QueryRow(query, userUpdate.Email, nickname)
+	if err == store.ErrConflict {
+		sendError("Can't change prifile with id " + nickname + "\n", 409, &w)
+		return
 	}
-
-	err = row.Scan(&userUpdate.About, &userUpdate.Email, &userUpdate.FullName, &userUpdate.NickName)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			sendError("Can't find prifile with id " + nickname + "\n", 404, &w)
-			return
-		}
-
-		errorName := err.(*pq.Error).Code.Name()
+		sendInternalError(err, &w, r)
+		return
+	}
 
-		if errorName == "unique_violation"{
-			sendError("Can't change prifile with id " + nickname + "\n", 409, &w)
+	// После смены пароля украденные сессии и токены не должны работать, в том числе текущая
+	if userUpdate.PasswordHash != "" {
+		if err := h.store.Sessions.DeleteUser(r.Context(), user.NickName); err != nil {
+			sendInternalError(err, &w, r)
 			return
 		}
-
-		w.WriteHeader(http.StatusInternalServerError)
-		return
 	}
 
-	resp, _ := json.Marshal(userUpdate)
-	w.Header().Set("content-type", "application/json")
-
-	w.Write(resp)
-
+	sendJSON(user, http.StatusOK, &w)
 	return
 }
 
@@ -220,118 +221,60 @@ curl -i --header "Content-Type: application/json" --request POST --data '{"about
 
 */
 
-func UserCreate(w http.ResponseWriter, r *http.Request)  {
+func (h *Handler) UserCreate(w http.ResponseWriter, r *http.Request)  {
 	vars := mux.Vars(r)
 	nickname := vars["nickname"]
 
 	body, _ := ioutil.ReadAll(r.Body)
 	defer r.Body.Close()
 
-	//if err != nil {
-	//	w.WriteHeader(http.StatusInternalServerError)
-	//	return
-	//}
-
 	user := models.User{}
-	err := json.Unmarshal(body, &user)
+	json.Unmarshal(body, &user)
 	user.NickName = nickname
 
-	//if err != nil {
-	//	w.WriteHeader(http.StatusInternalServerError)
-	//	return
-	//}
-
 	if user.NickName == "" || user.About == "" || user.Email == "" || user.FullName == "" {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	t,err := db.Begin()
-
-	if err != nil {
-		fmt.Println("db.begin ", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	defer t.Rollback()
-
-	_, err = t.Exec("SET LOCAL synchronous_commit TO OFF")
+	if user.Password != "" {
+		hash, err := auth.HashPassword(user.Password)
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
 
-	if err != nil {
-		fmt.Println("set local begin ", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		user.PasswordHash = hash
+		user.Password = ""
 	}
 
-	query := "INSERT INTO users(about, email, fullname, nickname) VALUES ($1,$2,$3,$4) RETURNING *"
-
-	err = t.QueryRow(query, user.About, user.Email, user.FullName, user.NickName).Scan(&user.About,
-		&user.Email, &user.FullName, &user.NickName)
-
-	if err != nil {
-		errorName := err.(*pq.Error).Code.Name()
-
-		if errorName == "unique_violation"{
-			users := make([]models.User, 0)
-
-			rows, err := db.Query("SELECT * FROM users WHERE nickname=$1 OR email=$2", user.NickName, user.Email)
-
-			if err != nil{
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-
-			for rows.Next() {
-				usr := models.User{}
-
-				err := rows.Scan(&usr.About, &usr.Email, &usr.FullName, &usr.NickName)
-
-				if err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-					return
-				}
-
-				users = append(users, usr)
-			}
-			rows.Close()
-
-			resp, _ := json.Marshal(users)
-			w.Header().Set("content-type", "application/json")
+	err := h.store.Users.Create(r.Context(), &user)
 
-			w.WriteHeader(http.StatusConflict)
-			w.Write(resp)
+	if err == store.ErrConflict {
+		users, err := h.store.Users.Conflicting(r.Context(), user.NickName, user.Email)
 
+		if err != nil {
+			sendInternalError(err, &w, r)
 			return
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
+		sendJSON(users, http.StatusConflict, &w)
 		return
-
 	}
-
-
-	resp, err := json.Marshal(user)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		sendInternalError(err, &w, r)
 		return
 	}
 
-	t.Commit()
-
-	w.Header().Set("content-type", "application/json")
-
-	w.WriteHeader(http.StatusCreated)
-	w.Write(resp)
+	sendJSON(user, http.StatusCreated, &w)
 	return
-
 }
 
 /*
 curl -i --header "Content-Type: application/json" --request POST --data '{"about":"text about user" , "email": "myemail@ddf.ru", "fullname": "Grigory"}' http://127.0.0.1:8080/user/grisha23/create
 
 */
-func ThreadVote(w http.ResponseWriter, r *http.Request)  {
+func (h *Handler) ThreadVote(w http.ResponseWriter, r *http.Request)  {
 	if r.Method != http.MethodPost{
 		return
 	}
@@ -349,224 +292,117 @@ func ThreadVote(w http.ResponseWriter, r *http.Request)  {
 		return
 	}
 
-	t, err := db.Begin()
-
-	if err != nil {
-		fmt.Println("db.begin ", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	defer t.Rollback()
+	json.Unmarshal(body, &vote)
 
-	_, err = t.Exec("SET LOCAL synchronous_commit TO OFF")
+	thr, err := h.store.Votes.Vote(r.Context(), slugOrId, &vote)
 
-	if err != nil {
-		fmt.Println("set local ", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	if err == store.ErrNotFound {
+		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
 		return
 	}
-
-	err = json.Unmarshal(body, &vote)
-
-	thrId, err := strconv.Atoi(slugOrId)
-
-	if err != nil {
-		_,err = t.Exec("INSERT INTO votes(nickname, voice, thread) VALUES ($1,$2, (SELECT id FROM threads WHERE slug=$3)) " +
-			"ON CONFLICT (nickname, thread) DO " +
-			"UPDATE SET voice=$2",
-			vote.Nickname, vote.Voice, slugOrId)
-	} else {
-		_,err = t.Exec("INSERT INTO votes(nickname, voice, thread) VALUES ($1,$2,$3) " +
-			"ON CONFLICT (nickname, thread) DO " +
-			"UPDATE SET voice=$2",
-			vote.Nickname, vote.Voice, thrId)
-	}
-
-	if err != nil {
-		if err.(*pq.Error).Code.Name() == "foreign_key_violation" {
-			sendError("Can't find user with id " + slugOrId + "\n", 404, &w)
-			return
-		}
-	}
-
-	t.Commit()
-
-	thr, err := getThread(slugOrId, nil)
-
 	if err != nil {
-		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
+		sendInternalError(err, &w, r)
 		return
 	}
 
-
-
-	resp, _ := json.Marshal(thr)
-	w.Header().Set("content-type", "application/json")
-
-	w.Write(resp)
+	sendJSON(thr, http.StatusOK, &w)
 	return
-
 }
 
 /*
 curl -i --header "Content-Type: application/json" --request POST --data '{"nickname": "Grisha23", "voice": -1}' http://127.0.0.1:8080/thread/19/vote
 
 */
-func ThreadPosts(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ThreadPosts(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	slugOrId := vars["slug_or_id"]
 
-	thr, err := getThread(slugOrId, nil)
+	thr, err := h.store.Threads.Get(r.Context(), slugOrId)
 
-	if err != nil {
+	if err == store.ErrNotFound {
 		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
 		return
 	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
 
 	limitVal := r.URL.Query().Get("limit")
 	sinceVal := r.URL.Query().Get("since")
 	descVal := r.URL.Query().Get("desc")
 	sortVal := r.URL.Query().Get("sort")
 
-	var since= false
-	var desc= false
-	var limit= false
-
-	if limitVal == "" {
-		limitVal = " ALL"
-	} else {
-		limit = true
-	}
-	if sinceVal != "" {
-		since = true
+	filter := store.PostFilter{
+		Desc: descVal == "true",
+		Sort: sortVal,
 	}
-	if descVal == "true" {
-		desc = true
-	}
-	if sortVal != "flat" && sortVal != "tree" && sortVal != "parent_tree" {
-		sortVal = "flat"
-	}
-
-	var rows *sql.Rows
-
-	if sortVal == "flat" {
-		if desc {
 
-			if since {
-
-				rows, err = db.Query("SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE thread = $1 AND id < $3 ORDER BY created DESC, id DESC LIMIT $2", thr.Id, limitVal, sinceVal)
-
-			} else {
-
-				rows, err = db.Query("SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE thread = $1 ORDER BY id DESC LIMIT $2", thr.Id, limitVal)
-
-			}
-
-		} else {
-
-			if since {
-
-				rows, err = db.Query("SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE thread = $1 AND id > $3 ORDER BY id ASC LIMIT $2", thr.Id, limitVal, sinceVal)
-
-			} else {
-				query := "SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE thread = $1 ORDER BY id ASC LIMIT " + limitVal
-				rows, err = db.Query(query, thr.Id)
-
-			}
-
-		}
-	} else if sortVal == "tree" {
-		sinceAddition := ""
-		sortAddition := ""
-		limitAddition := ""
-		if desc == true {
-			sortAddition = " ORDER BY id_array[0], id_array DESC "
-			if since != false {
-				sinceAddition = " AND id_array < (SELECT id_array FROM posts WHERE id = " + sinceVal + " ) "
-			}
-		} else {
-			sortAddition = " ORDER BY id_array[0],id_array "
-			if since != false {
-				sinceAddition = " AND id_array > (SELECT id_array FROM posts WHERE id = " + sinceVal + " ) "
-			}
-		}
-
-		if limit != false {
-			limitAddition = "LIMIT " + limitVal
-		}
-		query := "SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE thread=$1 " + sinceAddition + " " + sortAddition + " " + limitAddition
-		rows, err = db.Query(query, thr.Id)
-	} else if sortVal == "parent_tree" {
-		descflag := ""
-		sinceAddition := ""
-		sortAddition := ""
-		limitAddition := ""
-		if desc == true {
-			descflag = " desc "
-			sortAddition = "ORDER BY id_array[1] DESC, id_array "
-			if since != false {
-				sinceAddition = " AND id_array[1] < (SELECT id_array[1] FROM posts WHERE id = " + sinceVal + " ) "
-			}
-		} else {
-			descflag = " ASC "
-			sortAddition = " ORDER BY id_array[1], id_array ASC"
-			if since != false {
-				sinceAddition = " AND id_array[1] > (SELECT id_array[1] FROM posts WHERE id = " + sinceVal + " ) "
-			}
+	if limitVal != "" {
+		filter.Limit, err = strconv.Atoi(limitVal)
+		if err != nil {
+			sendError("Bad limit " + limitVal + "\n", 400, &w)
+			return
 		}
-
-		if limit != false {
-			limitAddition = " WHERE rank <= " + limitVal
+	}
+	if sinceVal != "" {
+		filter.Since, err = strconv.ParseInt(sinceVal, 10, 64)
+		if err != nil {
+			sendError("Bad since " + sinceVal + "\n", 400, &w)
+			return
 		}
-
-		query :="SELECT author,created,forum,id,isedited,message,parent,thread FROM (" +
-			" SELECT author,id_array,created,forum,id,isedited,message,parent,thread, " +
-			" dense_rank() over (ORDER BY id_array[1] " + descflag + " ) AS rank " +
-			" FROM posts WHERE thread=$1 " + sinceAddition + " ) AS tree " + limitAddition + " " + sortAddition
-
-		rows, err = db.Query(query, thr.Id)
+	}
+	if sortVal != store.SortFlat && sortVal != store.SortTree && sortVal != store.SortParentTree {
+		filter.Sort = store.SortFlat
 	}
 
-	if err != nil {
-		fmt.Println(err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	pg, ok := newPager(w, r, "posts", filter.Sort, filter.Desc, filter.Limit)
+	if !ok {
 		return
 	}
 
-	defer rows.Close()
-	posts := make([]models.Post, 0)
-	var i = 0
-	for rows.Next(){
-		i++
-		post := models.Post{}
-
-		err = rows.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread)
+	filter.Sort, filter.Desc, filter.Limit = pg.sort, pg.readDesc(), pg.fetchLimit()
+	if key := pg.key(); key != nil {
+		filter.Since, err = strconv.ParseInt(key[0], 10, 64)
 		if err != nil {
-			fmt.Println(err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
+			sendError("Bad cursor " + r.URL.Query().Get("cursor") + "\n", 400, &w)
 			return
 		}
-
-		posts = append(posts, post)
-
 	}
 
-	defer rows.Close()
+	posts, err := h.store.Threads.Posts(r.Context(), thr, filter)
 
-	w.Header().Set("content-type", "application/json")
-
-	resp, _ := json.Marshal(posts)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
 
-	w.Write(resp)
+	// В parent_tree limit считает корневые посты, страница не разрывает их ветки
+	units := everyItem(len(posts))
+	if filter.Sort == store.SortParentTree {
+		units = units[:0]
+		for i, post := range posts {
+			if post.Parent == 0 {
+				units = append(units, i)
+			}
+		}
+	}
 
+	pg.send(w, posts, units, func(item interface{}) []string {
+		return []string{strconv.FormatInt(item.(models.Post).Id, 10)}
+	})
 	return
 }
 
-func ThreadDetails(w http.ResponseWriter, r *http.Request){
+func (h *Handler) ThreadDetails(w http.ResponseWriter, r *http.Request){
 	vars := mux.Vars(r)
 	slugOrId := vars["slug_or_id"]
 
+	if r.Method == http.MethodDelete {
+		h.deleteThread(w, r, slugOrId)
+		return
+	}
+
 	if r.Method == http.MethodPost{
 
 		body, err := ioutil.ReadAll(r.Body)
@@ -579,84 +415,52 @@ func ThreadDetails(w http.ResponseWriter, r *http.Request){
 
 		thr := models.Thread{}
 
-		err = json.Unmarshal(body, &thr)
+		json.Unmarshal(body, &thr)
 
-		if thr.Title == "" && thr.Message == ""{
-			existThr, err := getThread(slugOrId, nil)
+		if h.opts.Auth.Enforce {
+			existing, err := h.store.Threads.Get(r.Context(), slugOrId)
 
-			if err != nil {
+			if err == store.ErrNotFound {
 				sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
 				return
 			}
+			if err != nil {
+				sendInternalError(err, &w, r)
+				return
+			}
 
-			resp, err := json.Marshal(existThr)
-			w.Header().Set("content-type", "application/json")
-
-			w.Write(resp)
-			return
-		}
-
-		var many = " "
-
-		var messageAddition = ""
-
-		var titleAddition = ""
-
-		if thr.Message != "" {
-			messageAddition = " message='" + thr.Message + "' "
-		}
-
-		if thr.Title != "" {
-			titleAddition = " title='" + thr.Title + "' "
-		}
-
-		if thr.Title != "" && thr.Message != "" {
-			many = ","
+			if !h.authorizeEdit(w, r, existing.Forum, existing.Author) {
+				return
+			}
 		}
 
-		var row *sql.Row
-
-		thrId, err := strconv.Atoi(slugOrId)
-
-		var idenAdditional string
-
-		if err != nil {
-			idenAdditional = "slug='" + slugOrId + "' "
+		updated, err := h.store.Threads.Update(r.Context(), slugOrId, &thr, auth.User(r.Context()))
 
-		} else {
-			idenAdditional = "id=" + strconv.Itoa(thrId)
+		if err == store.ErrNotFound {
+			sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
+			return
 		}
-
-		query := "UPDATE threads SET " + messageAddition + many + titleAddition + " WHERE " + idenAdditional + " RETURNING *"
-		row = db.QueryRow(query)
-
-		err = row.Scan(&thr.Id, &thr.Author, &thr.Created, &thr.Forum,  &thr.Message, &thr.Slug, &thr.Title, &thr.Votes)
-
 		if err != nil {
-			sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
+			sendInternalError(err, &w, r)
 			return
 		}
 
-		resp, err := json.Marshal(thr)
-		w.Header().Set("content-type", "application/json")
-
-		w.Write(resp)
-
+		sendJSON(updated, http.StatusOK, &w)
 		return
 	}
 
-	thr, err := getThread(slugOrId, nil)
+	thr, err := h.store.Threads.Get(r.Context(), slugOrId)
 
-	if err != nil {
+	if err == store.ErrNotFound {
 		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
 		return
 	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
 
-	resp, _ := json.Marshal(thr)
-	w.Header().Set("content-type", "application/json")
-
-	w.Write(resp)
-
+	sendJSON(thr, http.StatusOK, &w)
 	return
 }
 
@@ -666,61 +470,17 @@ curl -i --header "Content-Type: application/json" --request POST --data '{"messa
 
 */
 
-func getThread(slug string, t *sql.Tx) (*models.Thread, error) {
-	thrId, err := strconv.Atoi(slug)
-	var row *sql.Row
-
-	//if t == nil {
-		if err != nil {
-			row = db.QueryRow("SELECT * FROM threads WHERE slug=$1;", slug)
-		} else {
-			row = db.QueryRow("SELECT * FROM threads WHERE id=$1;", thrId)
-		}
-	//} else {
-	//	if err != nil {
-	//		row = t.QueryRow("SELECT * FROM threads WHERE slug=$1;", slug)
-	//	} else {
-	//		row = t.QueryRow("SELECT * FROM threads WHERE id=$1;", thrId)
-	//	}
-	//}
-
-
+func (h *Handler) PostCreate(w http.ResponseWriter, r *http.Request)  {
+	if r.Method != http.MethodPost{
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
 
-	var sqlSlug sql.NullString
+	vars := mux.Vars(r)
+	slugOrId := vars["slug_or_id"]
 
-	thr := new(models.Thread)
-	err = row.Scan(&thr.Id, &thr.Author, &thr.Created, &thr.Forum, &thr.Message, &sqlSlug, &thr.Title, &thr.Votes)
-
-	if !sqlSlug.Valid {
-		thr.Slug = ""
-	} else {
-		thr.Slug = sqlSlug.String
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	return thr, nil
-}
-
-func PostCreate(w http.ResponseWriter, r *http.Request)  {
-	if r.Method != http.MethodPost{
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
-	globalCount++
-	//fmt.Println(globalCount)
-
-	if globalCount == 15500 {
-		db.Exec("VACUUM ANALYZE;")
-	}
-
-	vars := mux.Vars(r)
-	slugOrId := vars["slug_or_id"]
-
-	body, err := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -731,179 +491,52 @@ func PostCreate(w http.ResponseWriter, r *http.Request)  {
 
 	err = json.Unmarshal(body, &posts)
 
-
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	t, err := db.Begin()
+	thr, err := h.store.Threads.Get(r.Context(), slugOrId)
 
-	if err != nil {
-		fmt.Println("db.begin ", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	if err == store.ErrNotFound {
+		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
 		return
 	}
-
-	_, err = t.Exec("SET LOCAL synchronous_commit TO OFF")
-
 	if err != nil {
-		fmt.Println("set local ", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	thr, err := getThread(slugOrId, nil)
-
-	if err != nil{
-		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
+		sendInternalError(err, &w, r)
 		return
 	}
 
-	defer t.Rollback()
 	if len(posts) == 0 {
-		data := make([]models.Post,0)
-
-		resp, err := json.Marshal(data)
-
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("content-type", "application/json")
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write(resp)
+		sendJSON(posts, http.StatusCreated, &w)
 		return
 	}
 
-
-
-	//var firstCreated time.Time
-	//var err error
-	//stmt, err := t.Prepare("INSERT INTO posts(author, forum, message, parent, thread, created) VALUES ($1,$2,$3,$4,$5,$6) RETURNING author,created,forum,id,isedited,message,parent,thread")
-
-
-	resQuery :=  "INSERT INTO posts(author, forum, message, parent, thread) VALUES "
-
-	forumUsersInsert := "INSERT INTO forum_users(forum,author) VALUES "
-
-	var subQuery []string
-	var forumUsersSubQuery []string
-
-	for _, p := range posts{
-
-
-		values := fmt.Sprintf("('%s', '%s', '%s', %d, %d) ", p.Author, thr.Forum, p.Message, p.Parent, thr.Id)
-
-		//query += subQuery
-
-		subQuery = append(subQuery, values)
-
-		//newPost := models.Post{}
-		//if count == 0 { // Для того, чтобы все последующие добавления постов происхдили с той же датой и временем.
-		//	row := t.QueryRow("INSERT INTO posts(author, forum, message, parent, thread) VALUES ($1,$2,$3,$4,$5) RETURNING author,created,forum,id,isedited,message,parent,thread",
-		//		p.Author, thr.Forum,p.Message, p.Parent, thr.Id)
-		//	err = row.Scan(&newPost.Author, &newPost.Created, &newPost.Forum, &newPost.Id, &newPost.IsEdited, &newPost.Message,
-		//		&newPost.Parent, &newPost.Thread)
-		//
-		//	firstCreated = newPost.Created
-		//} else {
-		//	row := stmt.QueryRow(p.Author, thr.Forum,p.Message, p.Parent, thr.Id, firstCreated)
-		//	err = row.Scan(&newPost.Author,  &newPost.Created, &newPost.Forum, &newPost.Id, &newPost.IsEdited, &newPost.Message,
-		//		&newPost.Parent, &newPost.Thread)
-		//}
-
-		//_,err := t.Exec("INSERT INTO forum_users(forum,author) VALUES ($1,$2) ON CONFLICT DO NOTHING", thr.Forum, p.Author)
-
-
-		forumUsersValues := fmt.Sprintf("('%s', '%s') ", thr.Forum, p.Author)
-
-		forumUsersSubQuery = append(forumUsersSubQuery, forumUsersValues)
-
-		//if err != nil {
-		//	fmt.Println("postCreate insert forum_users ", err.Error())
-		//}
-
-		//data = append(data, newPost)
-
-
+	authors := make([]string, 0, len(posts))
+	for _, p := range posts {
+		authors = append(authors, p.Author)
 	}
 
-	resQuery += strings.Join(subQuery, ",") + " RETURNING author,created,forum,id,isedited,message,parent,thread;"
-
-	forumUsersInsert += strings.Join(forumUsersSubQuery, ",") + " ON CONFLICT DO NOTHING;"
-
-	resQuery += forumUsersInsert
-
-	//fmt.Println(resQuery)
-
-	rows, err := t.Query(resQuery)
-
-	if err != nil {
-		//fmt.Println(err.Error())
-		errorName := err.(*pq.Error).Code.Name()
-
-		fmt.Println(errorName)
-		if err.Error() == "pq: Parent post exc" {
-			sendError("Parent post was created in another thread \n", 409, &w)
-			return
-		}
-
-		if errorName == "foreign_key_violation" {
-			sendError("Can't find parent post \n", 404, &w)
-			return
-		}
-
-		if errorName != "syntax_error" {
-			sendError("Can't find parent post \n", 404, &w)
-			return
-		}
-
-		w.WriteHeader(http.StatusInternalServerError)
+	if !h.checkBanned(w, r, thr.Forum, authors) {
 		return
 	}
 
-	//_, err = db.Exec(forumUsersInsert)
-	//
-	//if err != nil {
-	//	fmt.Println("forumUsersInsert ---- ", err.Error())
-	//	return
-	//}
-
-
-
-	data := make([]models.Post,0)
+	data, err := h.store.Posts.Create(r.Context(), thr, posts)
 
-	for rows.Next() {
-		newPost := models.Post{}
-
-		err := rows.Scan(&newPost.Author,  &newPost.Created, &newPost.Forum, &newPost.Id, &newPost.IsEdited, &newPost.Message,
-				&newPost.Parent, &newPost.Thread)
-
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		data = append(data, newPost)
+	if err == store.ErrParentConflict {
+		sendError("Parent post was created in another thread \n", 409, &w)
+		return
+	}
+	if err == store.ErrNotFound {
+		sendError("Can't find parent post \n", 404, &w)
+		return
 	}
-
-	resp, err := json.Marshal(data)
-
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		sendInternalError(err, &w, r)
 		return
 	}
 
-	t.Commit()
-
-	w.Header().Set("content-type", "application/json")
-
-	w.WriteHeader(http.StatusCreated)
-	w.Write(resp)
-
+	sendJSON(data, http.StatusCreated, &w)
 	return
 }
 
@@ -913,52 +546,69 @@ curl -i --header "Content-Type: application/json" --request POST --data '[{"auth
 */
 
 
-func ServiceStatus(w http.ResponseWriter, r *http.Request)  {
+func (h *Handler) ServiceStatus(w http.ResponseWriter, r *http.Request)  {
 	if r.Method != http.MethodGet{
 		return
 	}
 
-	row := db.QueryRow("SELECT t1.cnt c1, t2.cnt c2, t3.cnt c3, t4.cnt c4 FROM (SELECT count(*) cnt FROM users) t1, (SELECT COUNT(*) cnt FROM forums) t2, (SELECT COUNT(*) cnt FROM posts) t3, (SELECT COUNT(*) cnt FROM threads) t4")
-
-	status := models.Status{}
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = h.opts.StatusMode
+	case store.StatusCounters, store.StatusExact, store.StatusEstimate:
+	default:
+		sendError("Bad mode " + mode + "\n", http.StatusBadRequest, &w)
+		return
+	}
 
-	err := row.Scan(&status.User, &status.Forum, &status.Post, &status.Thread)
+	status, err := h.store.Service.Status(r.Context(), mode)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		sendInternalError(err, &w, r)
 		return
 	}
 
-	resp, _ := json.Marshal(status)
-	w.Header().Set("content-type", "application/json")
-
-	w.WriteHeader(http.StatusOK)
-
-	w.Write(resp)
-
+	sendJSON(status, http.StatusOK, &w)
 	return
 }
 
-func ServiceClear(w http.ResponseWriter, r *http.Request)  {
+func (h *Handler) ServiceClear(w http.ResponseWriter, r *http.Request)  {
 	if r.Method != http.MethodPost{
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	db.Exec("TRUNCATE TABLE votes, users, posts, threads, forums, forum_users")
+	err := h.store.Service.Clear(r.Context())
+
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 
 	return
 }
 
-func PostDetails(w http.ResponseWriter, r *http.Request){
+func (h *Handler) PostDetails(w http.ResponseWriter, r *http.Request){
 
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	postId, err := strconv.ParseInt(id, 10, 64)
+
+	if err != nil {
+		sendError("Can't find post with id " + id + "\n", 404, &w)
+		return
+	}
+
 	related := r.URL.Query().Get("related")
 
+	if r.Method == http.MethodDelete {
+		h.deletePost(w, r, postId)
+		return
+	}
+
 	if r.Method == http.MethodPost {
 		body, err := ioutil.ReadAll(r.Body)
 		defer r.Body.Close()
@@ -977,357 +627,63 @@ func PostDetails(w http.ResponseWriter, r *http.Request){
 			return
 		}
 
-		if err != nil{
-			sendError( "Can't find post with id " + id + "\n", 404, &w)
-			return
-		}
-
-		if post.Message == "" {
-			row := db.QueryRow("SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE id=$1", id)
+		if h.opts.Auth.Enforce {
+			existing, err := h.store.Posts.Get(r.Context(), postId)
 
-			err = row.Scan(&post.Author,&post.Created,&post.Forum,&post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread)
-
-			if err != nil{
-				w.WriteHeader(http.StatusInternalServerError)
+			if err == store.ErrNotFound || err == nil && existing.IsDeleted {
+				sendError("Can't find post with id " + id + "\n", 404, &w)
+				return
+			}
+			if err != nil {
+				sendInternalError(err, &w, r)
 				return
 			}
 
-			resp, _ := json.Marshal(post)
-			w.Header().Set("content-type", "application/json")
+			if !h.authorizeEdit(w, r, existing.Forum, existing.Author) {
+				return
+			}
+		}
 
-			w.Write(resp)
+		updated, err := h.store.Posts.Update(r.Context(), postId, post.Message, auth.User(r.Context()))
 
+		if err == store.ErrNotFound || err == nil && updated.IsDeleted {
+			sendError("Can't find post with id " + id + "\n", 404, &w)
 			return
 		}
-		row := db.QueryRow("UPDATE posts SET message=$1, isedited=true WHERE id=$2 RETURNING author,created,forum,id,isedited,message,parent,thread", post.Message, id)
-		err = row.Scan(&post.Author,&post.Created,&post.Forum,&post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread)
-
 		if err != nil {
-			sendError("Can't find post with id "+id+"\n", 404, &w)
+			sendInternalError(err, &w, r)
 			return
 		}
 
-		resp, _ := json.Marshal(post)
-		w.Header().Set("content-type", "application/json")
-
-		w.Write(resp)
-
+		sendJSON(updated, http.StatusOK, &w)
 		return
 	}
-	postDetail := models.PostDetail{}
-
-	//query := "SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE id=$1; "
-
-
-	//post := new(models.Post)
-	//row := db.QueryRow("SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE id=$1;", id)
-	//
-	//err := row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread)
-	//
-	//if err != nil {
-	//	sendError("Can't find post with id "+id+"\n", 404, &w)
-	//	return
-	//}
-	//
-	//postDetail.Post = post
-
-	//var query string
-
-	var oblectsArr []string
-	objects := strings.Split(related, ",")
-	for index := range objects  {
-		item := objects[index]
-		oblectsArr = append(oblectsArr, item)
-	}
 
-	var relUser = false
-	var relThread = false
-	var relForum = false
+	rel := store.Related{}
 
-	for index := range oblectsArr {
-		if oblectsArr[index] == "user" {
-			relUser = true
-			//query += "SELECT about,email,fullname,nickname FROM users WHERE nickname='" + post.Author + "'; "
-			//author, _ := getUser(post.Author, nil)
-			//postDetail.Author = author
-		}
-		if oblectsArr[index] == "thread" {
-			relThread = true
-			//_, err := strconv.Atoi(strconv.Itoa(int(post.Thread)))
-			//
-			//if err != nil {
-			//	query += "SELECT * FROM threads WHERE slug='" + strconv.Itoa(int(post.Thread)) + "'; "
-			//} else {
-			//	query += "SELECT * FROM threads WHERE id=" + strconv.Itoa(int(post.Thread)) + "; "
-			//}
-
-			//if err != nil {
-			//	w.WriteHeader(http.StatusInternalServerError)
-			//	return
-			//}
-
-			//postDetail.Thread = thread
-		}
-		if oblectsArr[index] == "forum" {
-			relForum = true
-			//query += "SELECT * FROM forums WHERE slug='" + post.Forum + "'; "
-			//forum, _ := getForum(post.Forum, nil)
-			//postDetail.Forum = forum
+	for _, item := range strings.Split(related, ",") {
+		switch item {
+		case "user":
+			rel.User = true
+		case "thread":
+			rel.Thread = true
+		case "forum":
+			rel.Forum = true
 		}
 	}
 
-	var err error
-	var sqlSlug sql.NullString
-
-	if !relUser && !relThread && !relForum {
-		post := new(models.Post)
-		row := db.QueryRow("SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE id=$1;", id)
-
-		err = row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread)
-
-		postDetail.Post = post
-
-	} else if !relUser && !relThread && relForum {
-
-		query := "SELECT p.author,p.created,p.forum,p.id,p.isedited,p.message,p.parent,p.thread, f.posts, f.slug, f.threads, f.title, f.author FROM posts p " +
-			"JOIN forums f ON p.id=$1 AND p.forum=f.slug"
-		row := db.QueryRow(query, id)
-
-		forum := new(models.Forum)
-		post := new(models.Post)
-
-		err = row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread,
-			&forum.Posts, &forum.Slug, &forum.Threads, &forum.Title, &forum.User)
-
-		postDetail.Forum = forum
-		postDetail.Post = post
-
-		} else if !relUser && relThread && !relForum {
-
-		query := "SELECT p.author,p.created,p.forum,p.id,p.isedited,p.message,p.parent,p.thread, t.id, t.author, t.created, t.forum, t.message, t.slug, t.title, t.votes FROM posts p " +
-			"JOIN threads t ON p.id=$1 AND p.thread=t.id"
-		row := db.QueryRow(query, id)
-
-		thr := new(models.Thread)
-		post := new(models.Post)
-
-		err = row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread,
-			&thr.Id, &thr.Author, &thr.Created, &thr.Forum,  &thr.Message, &sqlSlug, &thr.Title, &thr.Votes)
-
-		if !sqlSlug.Valid {
-			thr.Slug = ""
-		} else {
-			thr.Slug = sqlSlug.String
-		}
-
-
-		postDetail.Thread = thr
-		postDetail.Post = post
-
-		} else if !relUser && relThread && relForum {
-
-		query := "SELECT p.author,p.created,p.forum,p.id,p.isedited,p.message,p.parent,p.thread, t.id, t.author, t.created, t.forum, t.message, t.slug, t.title, t.votes,  f.posts, f.slug, f.threads, f.title, f.author  FROM posts p " +
-			"JOIN threads t ON p.id=$1 AND p.thread=t.id JOIN forums f ON p.forum=f.slug"
-		row := db.QueryRow(query, id)
-
-		thr := new(models.Thread)
-		forum := new(models.Forum)
-		post := new(models.Post)
-
-		err = row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread,
-			&thr.Id, &thr.Author, &thr.Created, &thr.Forum,  &thr.Message, &sqlSlug, &thr.Title, &thr.Votes,
-			&forum.Posts, &forum.Slug, &forum.Threads, &forum.Title, &forum.User)
-
-		if !sqlSlug.Valid {
-			thr.Slug = ""
-		} else {
-			thr.Slug = sqlSlug.String
-		}
-
-
-		postDetail.Thread = thr
-		postDetail.Post = post
-		postDetail.Forum = forum
-
-		} else if relUser && !relThread && !relForum {
-
-		query := "SELECT p.author,p.created,p.forum,p.id,p.isedited,p.message,p.parent,p.thread, u.about, u.email, u.fullname, u.nickname FROM posts p " +
-			"JOIN users u ON p.id=$1 AND u.nickname=p.author"
-		row := db.QueryRow(query, id)
-
-		user := new(models.User)
-		post := new(models.Post)
-
-		err = row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread,
-			&user.About, &user.Email, &user.FullName, &user.NickName)
-
-		postDetail.Author = user
-		postDetail.Post = post
-
-		} else if relUser && !relThread && relForum {
-
-		query := "SELECT p.author,p.created,p.forum,p.id,p.isedited,p.message,p.parent,p.thread, u.about, u.email, u.fullname, u.nickname, f.posts, f.slug, f.threads, f.title, f.author   FROM posts p " +
-			"JOIN users u ON p.id=$1 AND u.nickname=p.author " +
-			"JOIN forums f ON p.forum=f.slug"
-		row := db.QueryRow(query, id)
-
-		user := new(models.User)
-		post := new(models.Post)
-		forum := new(models.Forum)
-
-		err = row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread,
-			&user.About, &user.Email, &user.FullName, &user.NickName,
-			&forum.Posts, &forum.Slug, &forum.Threads, &forum.Title, &forum.User)
-
-		postDetail.Author = user
-		postDetail.Post = post
-		postDetail.Forum = forum
-
-		} else if relUser && relThread && !relForum {
-
-		query := "SELECT p.author,p.created,p.forum,p.id,p.isedited,p.message,p.parent,p.thread, u.about, u.email, u.fullname, u.nickname,  t.id, t.author, t.created, t.forum, t.message, t.slug, t.title, t.votes FROM posts p " +
-			"JOIN users u ON p.id=$1 AND u.nickname=p.author " +
-			"JOIN threads t ON p.thread=t.id"
-		row := db.QueryRow(query, id)
-
-		user := new(models.User)
-		post := new(models.Post)
-		thr := new(models.Thread)
-
-		err = row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread,
-			&user.About, &user.Email, &user.FullName, &user.NickName,
-			&thr.Id, &thr.Author, &thr.Created, &thr.Forum,  &thr.Message, &sqlSlug, &thr.Title, &thr.Votes)
-
-		if !sqlSlug.Valid {
-			thr.Slug = ""
-		} else {
-			thr.Slug = sqlSlug.String
-		}
-
-
-		postDetail.Author = user
-		postDetail.Post = post
-		postDetail.Thread = thr
-
-		} else if relUser && relThread && relForum {
-
-		query := "SELECT p.author,p.created,p.forum,p.id,p.isedited,p.message,p.parent,p.thread, u.about, u.email, u.fullname, u.nickname,  t.id, t.author, t.created, t.forum, t.message, t.slug, t.title, t.votes , f.posts, f.slug, f.threads, f.title, f.author FROM posts p " +
-			"JOIN users u ON p.id=$1 AND u.nickname=p.author " +
-			"JOIN threads t ON p.thread=t.id " +
-			"JOIN forums f ON p.forum=f.slug"
-		row := db.QueryRow(query, id)
-
-		user := new(models.User)
-		post := new(models.Post)
-		thr := new(models.Thread)
-		forum := new(models.Forum)
-
-		err = row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread,
-			&user.About, &user.Email, &user.FullName, &user.NickName,
-			&thr.Id, &thr.Author, &thr.Created, &thr.Forum,  &thr.Message, &sqlSlug, &thr.Title, &thr.Votes,
-			&forum.Posts, &forum.Slug, &forum.Threads, &forum.Title, &forum.User)
-
-		if !sqlSlug.Valid {
-			thr.Slug = ""
-		} else {
-			thr.Slug = sqlSlug.String
-		}
-
-
-		postDetail.Author = user
-		postDetail.Post = post
-		postDetail.Thread = thr
-		postDetail.Forum = forum
-
-		}
-
+	postDetail, err := h.store.Posts.Details(r.Context(), postId, rel)
 
+	if err == store.ErrNotFound || err == nil && postDetail.Post.IsDeleted {
+		sendError("Can't find post with id " + id + "\n", 404, &w)
+		return
+	}
 	if err != nil {
-		fmt.Println(err.Error())
-		sendError("Can't find post with id "+id+"\n", 404, &w)
-		return
-	}
-
-	//rows, err := db.Query(query)
-
-	//fmt.Println(query)
-
-	//if err != nil {
-	//	sendError("Can't find post with id "+id+"\n", 404, &w)
-	//	return
-	//}
-
-
-	//fmt.Println("before")
-	//for rows.Next(){
-	//	fmt.Println("after")
-	//
-	//	if relUser {
-	//		fmt.Println("get user")
-	//
-	//		user := new(models.User)
-	//		err := rows.Scan(&user.About, &user.Email, &user.FullName, &user.NickName)
-	//		if err != nil {
-	//			fmt.Println(err.Error())
-	//			sendError("user", 500, &w)
-	//			return
-	//		}
-	//
-	//		postDetail.Author = user
-	//
-	//		relUser = false
-	//	} else if relThread {
-	//		fmt.Println("get thread")
-	//
-	//		thr := new(models.Thread)
-	//		err = rows.Scan(&thr.Id, &thr.Author, &thr.Created, &thr.Forum,  &thr.Message, &thr.Slug, &thr.Title, &thr.Votes)
-	//
-	//		if err != nil {
-	//			sendError("thread", 500, &w)
-	//			return
-	//		}
-	//
-	//		postDetail.Thread = thr
-	//
-	//		relThread = false
-	//	} else if relForum {
-	//		fmt.Println("get forum")
-	//
-	//		forum := new(models.Forum)
-	//		err = rows.Scan(&forum.Posts, &forum.Slug, &forum.Threads, &forum.Title, &forum.User)
-	//
-	//		if err != nil {
-	//			sendError("forum", 500, &w)
-	//			return
-	//		}
-	//
-	//		postDetail.Forum = forum
-	//
-	//		relForum = false
-	//	}
-	//
-	//}
-
-
-
-
-	//row := db.QueryRow("SELECT author,created,forum,id,isedited,message,parent,thread FROM posts WHERE id=$1", id)
-
-	//post := models.Post{}
-
-	//err := row.Scan(&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message, &post.Parent, &post.Thread)
-
-	//if err != nil {
-	//	sendError( "Can't find post with id " + id + "\n", 404, &w)
-	//	return
-	//}
-
-
-
-	resp, _ := json.Marshal(postDetail)
-	w.Header().Set("content-type", "application/json")
-
-	w.Write(resp)
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	sendJSON(postDetail, http.StatusOK, &w)
 	return
 }
 
@@ -1338,7 +694,7 @@ curl -i --header "Content-Type: application/json" --request POST --data '{"messa
 
 */
 
-func ForumUsers(w http.ResponseWriter, r *http.Request){
+func (h *Handler) ForumUsers(w http.ResponseWriter, r *http.Request){
 	if r.Method != http.MethodGet{
 		return
 	}
@@ -1347,100 +703,47 @@ func ForumUsers(w http.ResponseWriter, r *http.Request){
 	sinceVal := r.URL.Query().Get("since")
 	descVal := r.URL.Query().Get("desc")
 
-	var limit = false
-	var since = false
-	var desc = false
-
-	if limitVal != "" {
-		limit = true
-	}
-	if sinceVal != "" {
-		since = true
-	}
-	if descVal == "true" {
-		desc = true
-	}
-
-	var rows *sql.Rows
-
-	var err error
-
 	vars := mux.Vars(r)
 	slug := vars["slug"]
 
-	frm, _ := getForum(slug, nil)
-
-	if frm == nil {
-		sendError("Can't find forum with slug " + slug + "\n", 404, &w)
-		return
+	filter := store.UserFilter{
+		Since: sinceVal,
+		Desc:  descVal == "true",
 	}
 
-
-	if !limit && !since && !desc {
-		query := "SELECT about,email,fullname,nickname FROM forum_users f_u JOIN users u ON f_u.author=u.nickname AND f_u.forum=$1 ORDER BY nickname ASC"
-
-		rows, err = db.Query(query, slug)
-	} else if !limit && !since && desc {
-		query := "SELECT about,email,fullname,nickname FROM forum_users f_u JOIN users u ON f_u.author=u.nickname AND f_u.forum=$1 ORDER BY nickname DESC "
-
-		rows, err = db.Query(query, slug)
-	} else if !limit && since && !desc {
-		query := "SELECT about,email,fullname,nickname FROM forum_users f_u JOIN users u ON f_u.author=u.nickname AND f_u.forum=$1 AND u.nickname>$2 ORDER BY nickname ASC"
-
-		rows, err = db.Query(query, slug, sinceVal)
-
-	} else if !limit && since && desc {
-		query := "SELECT about,email,fullname,nickname FROM forum_users f_u JOIN users u ON f_u.author=u.nickname AND f_u.forum=$1 AND u.nickname<$2 ORDER BY nickname DESC "
-		rows, err = db.Query(query, slug, sinceVal)
-
-	} else if limit && !since && !desc {
-		query := "SELECT about,email,fullname,nickname FROM forum_users f_u JOIN users u ON f_u.author=u.nickname AND f_u.forum=$1 ORDER BY nickname ASC LIMIT $2"
-		rows, err = db.Query(query, slug, limitVal)
-
-	} else if limit && !since && desc {
-		query := "SELECT about,email,fullname,nickname FROM forum_users f_u JOIN users u ON f_u.author=u.nickname AND f_u.forum=$1 ORDER BY nickname DESC LIMIT $2"
-		rows, err = db.Query(query, slug, limitVal)
-
-	} else if limit && since && !desc {//here
-		query := "SELECT about,email,fullname,nickname FROM forum_users f_u JOIN users u ON f_u.author=u.nickname AND f_u.forum=$1 AND u.nickname>$2 ORDER BY nickname ASC LIMIT $3"
-
-		rows, err = db.Query(query, slug, sinceVal, limitVal)
-
-	} else if limit && since && desc {
-		query := "SELECT about,email,fullname,nickname FROM forum_users f_u JOIN users u ON f_u.author=u.nickname AND f_u.forum=$1 AND u.nickname<$2 ORDER BY nickname DESC LIMIT $3"
-
-		rows, err = db.Query(query, slug, sinceVal, limitVal)
-
-	}
-
-	if err != nil {
-		sendError( "Can't find forum with slug " + slug + "\n", 404, &w)
-		return
-	}
-
-	users := make([]models.User, 0)
-
-	for rows.Next() {
-		usr := models.User{}
-
-		err := rows.Scan(&usr.About, &usr.Email, &usr.FullName, &usr.NickName)
-
+	if limitVal != "" {
+		var err error
+		filter.Limit, err = strconv.Atoi(limitVal)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			sendError("Bad limit " + limitVal + "\n", 400, &w)
 			return
 		}
-
-		users = append(users, usr)
 	}
 
-	defer rows.Close()
+	pg, ok := newPager(w, r, "users", "", filter.Desc, filter.Limit)
+	if !ok {
+		return
+	}
 
+	filter.Desc, filter.Limit = pg.readDesc(), pg.fetchLimit()
+	if key := pg.key(); key != nil {
+		filter.Since = key[0]
+	}
 
-	resp, _ := json.Marshal(users)
-	w.Header().Set("content-type", "application/json")
+	users, err := h.store.Forums.Users(r.Context(), slug, filter)
 
-	w.Write(resp)
+	if err == store.ErrNotFound {
+		sendError("Can't find forum with slug " + slug + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
 
+	pg.send(w, users, everyItem(len(users)), func(item interface{}) []string {
+		return []string{item.(models.User).NickName}
+	})
 	return
 }
 
@@ -1449,125 +752,49 @@ curl -i --header "Content-Type: application/json" --request GET http://127.0.0.1
 
 */
 
-func ForumThreads(w http.ResponseWriter, r *http.Request){
+func (h *Handler) ForumThreads(w http.ResponseWriter, r *http.Request){
 	if r.Method != http.MethodGet {
 		return
 	}
 
-	limitVal := r.URL.Query().Get("limit")
-	sinceVal := r.URL.Query().Get("since")
-	descVal := r.URL.Query().Get("desc")
-
-	var limit = false
-	var since = false
-	var desc = false
-
-	if limitVal != "" {
-		limit = true
-	}
-	if sinceVal != "" {
-		since = true
-	}
-	if descVal == "true" {
-		desc = true
-	}
-
 	vars := mux.Vars(r)
 	slug := vars["slug"]
-	//frm, _ := getForum(slug, nil)  // Исправить
-	//if frm == nil {
-	//	sendError("Can't find forum with slug " + slug + "\n", 404, &w)
-	//	return
-	//}
-
-	var rows *sql.Rows
-
-	var err error
-
-	if limit && !since && !desc {
-		rows, err = db.Query("SELECT * FROM threads WHERE forum = $1 ORDER BY created LIMIT $2;", slug, limitVal)
-	} else if since && !limit && !desc {
-		rows, err = db.Query("SELECT * FROM threads WHERE forum = $1 AND created <= $2 ORDER BY created;", slug, sinceVal)
-	} else if limit && since && !desc {
-		rows, err = db.Query("SELECT * FROM threads WHERE forum = $1 AND created >= $2 ORDER BY created LIMIT $3;", slug, sinceVal, limitVal)
-	} else if limit && !since && desc {
-		rows, err = db.Query("SELECT * FROM threads WHERE forum = $1 ORDER BY created DESC LIMIT $2;", slug, limitVal)
-	} else if since && !limit && desc {
-		rows, err = db.Query("SELECT * FROM threads WHERE forum = $1 AND created <= $2 ORDER BY created DESC;", slug, sinceVal)
-	} else if limit && since && desc {
-		rows, err = db.Query("SELECT * FROM threads WHERE forum = $1 AND created <= $2 ORDER BY created DESC LIMIT $3;", slug, sinceVal, limitVal)
-	} else if limit && since && !desc{
-		rows, err = db.Query("SELECT * FROM threads WHERE forum = $1 AND created >= $2 ORDER BY created LIMIT $3;", slug, sinceVal, limitVal)
-	} else if !limit && !since && !desc {
-		rows, err = db.Query("SELECT * FROM threads WHERE forum = $1 ORDER BY created;", slug)
-	} else {
-		rows, err = db.Query("SELECT * FROM threads WHERE forum = $1 ORDER BY created;", slug)
-	}
 
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	filter, ok := threadFilter(w, r)
+	if !ok {
 		return
 	}
 
-	thrs := make([]models.Thread, 0)
-
-	var nullSlug sql.NullString
-
-	var flag = false
+	filter.Author = r.URL.Query().Get("author")
+	filter.Sort = r.URL.Query().Get("sort")
 
-	for rows.Next() {
-		flag = true
-		thr := models.Thread{}
-		err := rows.Scan(&thr.Id, &thr.Author, &thr.Created, &thr.Forum,  &thr.Message, &nullSlug, &thr.Title, &thr.Votes)
-
-		if nullSlug.Valid {
-			thr.Slug = nullSlug.String
-		} else {
-			thr.Slug = ""
-		}
-
-		if err != nil {
-
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		thrs = append(thrs, thr)
+	switch filter.Sort {
+	case "":
+		filter.Sort = store.ThreadsByCreated
+	case store.ThreadsByCreated, store.ThreadsByVotes, store.ThreadsByActivity, store.ThreadsByPosts:
+	default:
+		sendError("Bad sort " + filter.Sort + "\n", 400, &w)
+		return
 	}
 
-	if flag == false {
-		frm, _ := getForum(slug, nil)
-		if frm == nil {
-			sendError("Can't find forum with slug " + slug + "\n", 404, &w)
-			return
-		}
+	pg, ok := threadPager(w, r, "threads", &filter, "author")
+	if !ok {
+		return
 	}
 
-	defer rows.Close()
-
-
-	resp, _ := json.Marshal(thrs)
-	w.Header().Set("content-type", "application/json")
-
-	w.Write(resp)
-
-	return
-}
-
-func getForum(slugOrId string, t *sql.Tx) (*models.Forum,error) {
-	forum := models.Forum{}
-	var err error
-	//if t == nil {
-		err = db.QueryRow("SELECT * FROM forums WHERE slug=$1", slugOrId).Scan(&forum.Posts, &forum.Slug, &forum.Threads, &forum.Title, &forum.User)
-	//} else {
-	//	err = t.QueryRow("SELECT * FROM forums WHERE slug=$1", slugOrId).Scan(&forum.Posts, &forum.Slug, &forum.Threads, &forum.Title, &forum.User)
-	//}
-
+	thrs, err := h.store.Forums.Threads(r.Context(), slug, filter)
 
+	if err == store.ErrNotFound {
+		sendError("Can't find forum with slug " + slug + "\n", 404, &w)
+		return
+	}
 	if err != nil {
-		return nil, err
+		sendInternalError(err, &w, r)
+		return
 	}
 
-	return &forum, nil
+	pg.send(w, thrs, everyItem(len(thrs)), threadKey(filter.Sort))
+	return
 }
 
 /*
@@ -1577,24 +804,26 @@ curl -i --header "Content-Type: application/json" --request GET http://127.0.0.1
 
 */
 
-func ForumDetails(w http.ResponseWriter, r *http.Request){
+func (h *Handler) ForumDetails(w http.ResponseWriter, r *http.Request){
 	vars := mux.Vars(r)
 	slug := vars["slug"]
-	frm, err := getForum(slug, nil)
 
-	if err != nil {
+	frm, err := h.store.Forums.Get(r.Context(), slug)
+
+	if err == store.ErrNotFound {
 		sendError( "Can't find forum with slug " + slug + "\n", 404, &w)
 		return
 	}
-
-	resp, err := json.Marshal(frm)
-
 	if err != nil {
+		sendInternalError(err, &w, r)
 		return
 	}
-	w.Header().Set("content-type", "application/json")
 
-	w.Write(resp)
+	if !h.forumFamily(w, r, frm) {
+		return
+	}
+
+	sendJSON(frm, http.StatusOK, &w)
 	return
 }
 
@@ -1603,7 +832,7 @@ FORUM DETAILS
 curl -i --header "Content-Type: application/json" --request GET http://127.0.0.1:8080/forum/stories-about/details
 */
 
-func ThreadCreate(w http.ResponseWriter, r *http.Request){
+func (h *Handler) ThreadCreate(w http.ResponseWriter, r *http.Request){
 	body, readErr := ioutil.ReadAll(r.Body)
 	defer r.Body.Close()
 
@@ -1612,27 +841,6 @@ func ThreadCreate(w http.ResponseWriter, r *http.Request){
 		return
 	}
 
-	var err error
-
-
-	t, err := db.Begin()
-
-	if err != nil {
-		fmt.Println("db.begin ", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	defer t.Rollback()
-
-	_, err = t.Exec("SET LOCAL synchronous_commit = OFF")
-
-	if err != nil {
-		fmt.Println("db.begin ", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
 	thr := models.Thread{}
 
 	json.Unmarshal(body, &thr)
@@ -1640,70 +848,38 @@ func ThreadCreate(w http.ResponseWriter, r *http.Request){
 	params := mux.Vars(r)
 	slug := params["slug"]
 
-	var row *sql.Row
-	if thr.Slug == "" {
-		row = t.QueryRow("INSERT INTO threads(author, created, forum, message, title) VALUES ($1, $2, " +
-			"(SELECT slug FROM forums WHERE slug=$3), $4, $5) RETURNING *", thr.Author, thr.Created, slug,
-			thr.Message, thr.Title)
-	} else {
-		row = t.QueryRow("INSERT INTO threads(author, created, forum, message, title, slug) VALUES ($1, $2, " +
-			"(SELECT slug FROM forums WHERE slug=$3), $4, $5, $6) RETURNING *", thr.Author, thr.Created, slug,
-			thr.Message, thr.Title, thr.Slug)
+	if !h.checkBanned(w, r, slug, []string{thr.Author}) {
+		return
 	}
 
-	newThr := models.Thread{}
-	var sqlSlug sql.NullString
-	err = row.Scan(&newThr.Id, &newThr.Author, &newThr.Created, &newThr.Forum, &newThr.Message, &sqlSlug, &newThr.Title, &newThr.Votes)
+	err := h.store.Threads.Create(r.Context(), slug, &thr)
 
-	if err != nil {
-		fmt.Println(err.Error())
-		errorName := err.(*pq.Error).Code.Name()
+	if err == store.ErrNotFound {
+		sendError( "Can't find user or forum \n", 404, &w)
+		return
+	}
+	if err == store.ErrConflict {
+		existThr, err := h.store.Threads.Get(r.Context(), thr.Slug)
 
-		if errorName == "foreign_key_violation" || errorName == "not_null_violation"{
-			sendError( "Can't find user or forum \n", 404, &w)
+		// slug занят удаленной веткой
+		if err == store.ErrNotFound {
+			sendError("Thread with slug " + thr.Slug + " was deleted\n", http.StatusConflict, &w)
 			return
 		}
-
-		if errorName == "unique_violation"{
-			existThr, _ := getThread(thr.Slug, nil)
-
-			w.Header().Set("content-type", "application/json")
-
-			w.WriteHeader(http.StatusConflict)
-			resp, _ := json.Marshal(existThr)
-
-			w.Write(resp)
+		if err != nil {
+			sendInternalError(err, &w, r)
 			return
 		}
-		return
-	}
 
-	_, err = t.Exec("INSERT INTO forum_users(forum,author) VALUES ($1,$2) ON CONFLICT DO NOTHING", slug, thr.Author)
-
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		sendJSON(existThr, http.StatusConflict, &w)
 		return
 	}
-
-	if !sqlSlug.Valid {
-		newThr.Slug = ""
-	} else {
-		newThr.Slug = sqlSlug.String
-	}
-
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		sendInternalError(err, &w, r)
 		return
 	}
-	t.Commit()
-
-
-	resp, _:= json.Marshal(newThr)
-	w.Header().Set("content-type", "application/json")
-
-	w.WriteHeader(http.StatusCreated)
-	w.Write(resp)
 
+	sendJSON(thr, http.StatusCreated, &w)
 	return
 }
 
@@ -1712,7 +888,7 @@ CREATE THREAD
 curl -i --header "Content-Type: application/json" --request POST --data '{"author":"Grisha23","message":"DWjn waonda owadndn wa awn n3342", "title": "Thread1"}'   http://127.0.0.1:8080/forum/stories-about/create
 */
 
-func ForumCreate(w http.ResponseWriter, r *http.Request){
+func (h *Handler) ForumCreate(w http.ResponseWriter, r *http.Request){
 	if r.Method == http.MethodGet {
 		return
 	}
@@ -1720,75 +896,41 @@ func ForumCreate(w http.ResponseWriter, r *http.Request){
 	body, err := ioutil.ReadAll(r.Body)
 	defer r.Body.Close()
 
-	t, err := db.Begin()
-
 	if err != nil {
-		fmt.Println("db.begin ", err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	defer t.Rollback()
-
-	_,err = t.Exec("SET LOCAL synchronous_commit TO OFF")
+	forum := new(models.Forum)
+	json.Unmarshal(body, forum)
 
-	if err != nil {
-		fmt.Println("set local ", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	if !h.placeForum(w, r, forum) {
 		return
 	}
 
-	forum := new(models.Forum)
-	err = json.Unmarshal(body, forum)
-
-	existUser, _ := getUser(forum.User, nil)
+	err = h.store.Forums.Create(r.Context(), forum)
 
-	if existUser == nil {
+	if err == store.ErrNotFound {
 		sendError( "Can't find user with name " + forum.User + "\n", 404, &w)
 		return
 	}
+	if err == store.ErrConflict {
+		fr, err := h.store.Forums.Get(r.Context(), forum.Slug)
 
-	row := t.QueryRow("INSERT INTO forums(slug, title, author) VALUES ($1, $2, $3) RETURNING *", forum.Slug, forum.Title, existUser.NickName)
-
-	err = row.Scan(&forum.Posts, &forum.Slug, &forum.Threads, &forum.Title, &forum.User)
-
-	if err != nil {
-		errorName := err.(*pq.Error).Code.Name()
-		if errorName == "foreign_key_violation" {
-			sendError( "Can't find user with name " + forum.User + "\n", 404, &w)
+		if err != nil {
+			sendInternalError(err, &w, r)
 			return
 		}
-		if errorName == "unique_violation" {
-			row := db.QueryRow("SELECT * FROM forums WHERE slug=$1", forum.Slug)
-			fr := models.Forum{}
-			err := row.Scan(&fr.Posts, &fr.Slug, &fr.Threads, &fr.Title, &fr.User)
 
-			if err != nil {
-				fmt.Println(err.Error())
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-
-			w.Header().Set("content-type", "application/json")
-
-			w.WriteHeader(http.StatusConflict)
-			resp, _ := json.Marshal(fr)
-
-			w.Write(resp)
-			return
-		}
+		sendJSON(fr, http.StatusConflict, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
 	}
 
-	t.Commit()
-
-	resp, _ := json.Marshal(forum)
-
-	w.Header().Set("content-type", "application/json")
-
-	w.WriteHeader(http.StatusCreated)
-
-	w.Write(resp)
-
+	sendJSON(forum, http.StatusCreated, &w)
 	return
 }
 
@@ -1798,4 +940,3 @@ curl -i --header "Content-Type: application/json"   --request POST
 --data '{"slug":"stori123es-eabout","title":"Stoewries about som12ewe3ething",
 "user": "Gris21ha23"}'   http://127.0.0.1:8080/forum/create
 */
-
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+// apiRouter serves the routes of the original API with the handlers on s.
+func apiRouter(s *store.Store) *mux.Router {
+	h := New(s, Options{})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/forum/create", h.ForumCreate)
+	r.HandleFunc("/api/forum/{slug}/create", h.ThreadCreate)
+	r.HandleFunc("/api/forum/{slug}/details", h.ForumDetails)
+	r.HandleFunc("/api/post/{id}/details", h.PostDetails)
+	r.HandleFunc("/api/service/clear", h.ServiceClear)
+	r.HandleFunc("/api/service/status", h.ServiceStatus)
+	r.HandleFunc("/api/thread/{slug_or_id}/create", h.PostCreate)
+	r.HandleFunc("/api/thread/{slug_or_id}/details", h.ThreadDetails)
+	r.HandleFunc("/api/thread/{slug_or_id}/vote", h.ThreadVote)
+	r.HandleFunc("/api/user/{nickname}/create", h.UserCreate)
+	r.HandleFunc("/api/user/{nickname}/profile", h.UserProfile)
+
+	return r
+}
+
+// Сценарий оригинального API на хранилище в памяти: коды ответов и ключевые поля
+func TestAPI(t *testing.T) {
+	r := apiRouter(store.NewMemory())
+
+	steps := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+		has    []string
+	}{
+		{"POST", "/api/user/a/create", `{"about": "x", "email": "a@mail.ru", "fullname": "A"}`, 201, []string{`"nickname":"a"`}},
+		{"POST", "/api/user/b/create", `{"about": "x", "email": "b@mail.ru", "fullname": "B"}`, 201, nil},
+		{"POST", "/api/user/A/create", `{"about": "x", "email": "new@mail.ru", "fullname": "A"}`, 409, []string{`"nickname":"a"`}},
+		{"POST", "/api/user/c/create", `{"about": "x", "email": "B@mail.ru", "fullname": "C"}`, 409, []string{`"nickname":"b"`}},
+		{"GET", "/api/user/A/profile", ``, 200, []string{`"email":"a@mail.ru"`}},
+		{"GET", "/api/user/nobody/profile", ``, 404, nil},
+		{"POST", "/api/user/a/profile", `{"email": "b@mail.ru"}`, 409, nil},
+		{"POST", "/api/user/a/profile", `{"about": "y"}`, 200, []string{`"about":"y"`, `"fullname":"A"`}},
+
+		{"POST", "/api/forum/create", `{"slug": "f", "title": "F", "user": "A"}`, 201, []string{`"user":"a"`}},
+		{"POST", "/api/forum/create", `{"slug": "F", "title": "other", "user": "b"}`, 409, []string{`"title":"F"`}},
+		{"POST", "/api/forum/create", `{"slug": "g", "title": "G", "user": "nobody"}`, 404, nil},
+		{"GET", "/api/forum/F/details", ``, 200, []string{`"slug":"f"`}},
+		{"GET", "/api/forum/g/details", ``, 404, nil},
+
+		{"POST", "/api/forum/F/create", `{"author": "b", "title": "t", "message": "m", "slug": "t1"}`, 201, []string{`"forum":"f"`, `"id":1`}},
+		{"POST", "/api/forum/f/create", `{"author": "a", "title": "other", "message": "m", "slug": "T1"}`, 409, []string{`"title":"t"`}},
+		{"POST", "/api/forum/g/create", `{"author": "a", "title": "t", "message": "m"}`, 404, nil},
+		{"POST", "/api/forum/f/create", `{"author": "nobody", "title": "t", "message": "m"}`, 404, nil},
+		{"POST", "/api/forum/f/create", `{"author": "a", "title": "t2", "message": "m"}`, 201, []string{`"id":2`}},
+		{"GET", "/api/thread/t1/details", ``, 200, []string{`"id":1`}},
+		{"GET", "/api/thread/3/details", ``, 404, nil},
+
+		{"POST", "/api/thread/t1/create", `[{"author": "a", "message": "p1"}, {"author": "b", "message": "p2", "parent": 0}]`, 201, []string{`"id":1`, `"id":2`, `"thread":1`}},
+		{"POST", "/api/thread/1/create", `[{"author": "a", "message": "p3", "parent": 2}]`, 201, []string{`"parent":2`}},
+		{"POST", "/api/thread/2/create", `[{"author": "a", "message": "p4", "parent": 1}]`, 409, nil},
+		{"POST", "/api/thread/t1/create", `[{"author": "nobody", "message": "p"}]`, 404, nil},
+		{"POST", "/api/thread/9/create", `[{"author": "a", "message": "p"}]`, 404, nil},
+		{"POST", "/api/thread/t1/create", `[]`, 201, []string{`[]`}},
+		{"GET", "/api/post/3/details?related=user,thread,forum", ``, 200, []string{`"parent":2`, `"nickname":"a"`, `"slug":"t1"`, `"posts":3`}},
+		{"POST", "/api/post/3/details", `{"message": "p3 edited"}`, 200, []string{`"isEdited":true`}},
+		{"GET", "/api/post/9/details", ``, 404, nil},
+		{"GET", "/api/thread/t1/details", ``, 200, []string{`"posts":3`, `"lastPostAuthor":"a"`, `"lastPostAt":`}},
+		{"GET", "/api/thread/2/details", ``, 200, []string{`"posts":0`}},
+
+		{"POST", "/api/thread/t1/vote", `{"nickname": "a", "voice": 1}`, 200, []string{`"votes":1`}},
+		{"POST", "/api/thread/1/vote", `{"nickname": "b", "voice": 1}`, 200, []string{`"votes":2`}},
+		{"POST", "/api/thread/1/vote", `{"nickname": "a", "voice": -1}`, 200, []string{`"votes":0`}},
+		{"POST", "/api/thread/1/vote", `{"nickname": "nobody", "voice": 1}`, 404, nil},
+		{"POST", "/api/thread/9/vote", `{"nickname": "a", "voice": 1}`, 404, nil},
+
+		{"GET", "/api/service/status", ``, 200, []string{`"forum":1`, `"post":3`, `"thread":2`, `"user":2`}},
+		{"POST", "/api/service/clear", ``, 200, nil},
+		{"GET", "/api/service/status", ``, 200, []string{`"forum":0`, `"post":0`, `"thread":0`, `"user":0`}},
+		{"GET", "/api/user/a/profile", ``, 404, nil},
+	}
+
+	for _, step := range steps {
+		rec := request(r, step.method, step.path, "", step.body)
+
+		if rec.Code != step.code {
+			t.Errorf("%s %s %s: got %d, want %d: %s", step.method, step.path, step.body, rec.Code, step.code, rec.Body)
+			continue
+		}
+		for _, s := range step.has {
+			if !strings.Contains(rec.Body.String(), s) {
+				t.Errorf("%s %s %s: no %s in %s", step.method, step.path, step.body, s, rec.Body)
+			}
+		}
+	}
+}
+
+type failingUsers struct {
+	store.UserStore
+}
+
+func (failingUsers) Get(ctx context.Context, nickname string) (*models.User, error) {
+	return nil, errors.New("connection reset")
+}
+
+type errorRecorder struct {
+	*httptest.ResponseRecorder
+	err error
+}
+
+func (rec *errorRecorder) RecordError(err error) {
+	rec.err = err
+}
+
+// Ошибка хранилища - 500 без подробностей, сама ошибка уходит в access log
+func TestStoreError(t *testing.T) {
+	s := store.NewMemory()
+	s.Users = failingUsers{s.Users}
+
+	rec := &errorRecorder{ResponseRecorder: httptest.NewRecorder()}
+	apiRouter(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/a/profile", nil))
+
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "connection reset") {
+		t.Errorf("got %d %s", rec.Code, rec.Body)
+	}
+	if rec.err == nil || rec.err.Error() != "connection reset" {
+		t.Errorf("recorded %v", rec.err)
+	}
+}
+
+// statusService remembers the mode Status was asked for.
+type statusService struct {
+	store.ServiceStore
+	mode *string
+}
+
+func (s statusService) Status(ctx context.Context, mode string) (*models.Status, error) {
+	*s.mode = mode
+	return &models.Status{}, nil
+}
+
+func TestServiceStatusMode(t *testing.T) {
+	var asked string
+
+	s := store.NewMemory()
+	s.Service = statusService{s.Service, &asked}
+
+	for _, c := range []struct {
+		defaultMode string
+		query       string
+		code        int
+		mode        string
+	}{
+		{"", "", http.StatusOK, store.StatusCounters},
+		{store.StatusEstimate, "", http.StatusOK, store.StatusEstimate},
+		{store.StatusEstimate, "?mode=" + store.StatusExact, http.StatusOK, store.StatusExact},
+		{store.StatusExact, "?mode=" + store.StatusCounters, http.StatusOK, store.StatusCounters},
+		{"", "?mode=count", http.StatusBadRequest, ""},
+	} {
+		asked = ""
+		h := New(s, Options{StatusMode: c.defaultMode})
+
+		rec := httptest.NewRecorder()
+		h.ServiceStatus(rec, httptest.NewRequest(http.MethodGet, "/api/service/status"+c.query, nil))
+
+		if rec.Code != c.code || asked != c.mode {
+			t.Errorf("default %q, %s: got %d, mode %q, want %d, %q", c.defaultMode, c.query, rec.Code, asked, c.code, c.mode)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+// readyTimeout bounds the database checks of a readiness probe.
+const readyTimeout = 2 * time.Second
+
+// Drain makes Readyz fail, so a balancer stops sending traffic before the server shuts down.
+func (h *Handler) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+// Healthz only tells that the process is alive and serving HTTP.
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	sendJSON(models.Health{Status: "ok"}, http.StatusOK, &w)
+}
+
+// Readyz checks the database connection, the schema version and the connection pool.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	health := models.Health{Status: "ok", Checks: make(map[string]string)}
+
+	fail := func(check, reason string) {
+		health.Status = "unavailable"
+		health.Checks[check] = reason
+	}
+
+	if atomic.LoadInt32(&h.draining) == 1 {
+		fail("server", "shutting down")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	storage, err := h.store.Service.Health(ctx)
+	if err != nil {
+		// Текст ошибки может раскрыть адрес и пользователя базы, он уходит только в лог.
+		// Пробы не проходят через access log, поэтому пишем сами
+		h.opts.Log.Warn("readiness check failed", "check", "database", "error", err)
+		fail("database", "unreachable")
+	} else {
+		health.Checks["database"] = "ok"
+
+		if storage.SchemaVersion < storage.LatestVersion {
+			fail("migrations", fmt.Sprintf("schema version %d, expected %d", storage.SchemaVersion, storage.LatestVersion))
+		} else {
+			health.Checks["migrations"] = fmt.Sprintf("ok, version %d", storage.SchemaVersion)
+		}
+
+		if storage.MaxOpen > 0 && storage.InUse >= storage.MaxOpen {
+			fail("pool", fmt.Sprintf("all %d connections are in use", storage.MaxOpen))
+		} else {
+			health.Checks["pool"] = fmt.Sprintf("ok, %d in use", storage.InUse)
+		}
+	}
+
+	if health.Status != "ok" {
+		sendJSON(health, http.StatusServiceUnavailable, &w)
+		return
+	}
+
+	sendJSON(health, http.StatusOK, &w)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Grisha23/ForumsApi/logging"
+	"github.com/Grisha23/ForumsApi/store"
+)
+
+type brokenService struct {
+	store.ServiceStore
+	err error
+}
+
+func (s brokenService) Health(ctx context.Context) (*store.Health, error) {
+	return nil, s.err
+}
+
+func TestReadyzHidesDatabaseError(t *testing.T) {
+	s := store.NewMemory()
+	s.Service = brokenService{s.Service, errors.New(`dial tcp db.internal:5432: password authentication failed for user "forum"`)}
+
+	var logged bytes.Buffer
+	log, err := logging.New(&logged, logging.LevelInfo, logging.FormatLogfmt, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	New(s, Options{Log: log}).Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d", rec.Code)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "db.internal") || !strings.Contains(body, `"database":"unreachable"`) {
+		t.Errorf("body: %s", body)
+	}
+	if !strings.Contains(logged.String(), "db.internal") {
+		t.Errorf("error isn't logged: %s", logged.String())
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	h := New(store.NewMemory(), Options{})
+
+	rec := httptest.NewRecorder()
+	h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("got %d: %s", rec.Code, rec.Body)
+	}
+
+	h.Drain()
+
+	rec = httptest.NewRecorder()
+	h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("draining: got %d", rec.Code)
+	}
+}
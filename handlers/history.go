@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Grisha23/ForumsApi/diff"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+// PostHistory lists the revisions of a post to its author and moderators. With ?from=N&to=M
+// it answers the diff between versions instead, version 0 is the original text.
+func (h *Handler) PostHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	postId, err := strconv.ParseInt(id, 10, 64)
+
+	if err != nil {
+		sendError("Can't find post with id " + id + "\n", 404, &w)
+		return
+	}
+
+	post, err := h.store.Posts.Get(r.Context(), postId)
+
+	if err == store.ErrNotFound || err == nil && post.IsDeleted {
+		sendError("Can't find post with id " + id + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	if !h.authorizeEdit(w, r, post.Forum, post.Author) {
+		return
+	}
+
+	revisions, err := h.store.Posts.History(r.Context(), postId)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find post with id " + id + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	if query.Get("from") == "" && query.Get("to") == "" {
+		sendJSON(revisions, http.StatusOK, &w)
+		return
+	}
+
+	versions := []string{post.Message}
+	if len(revisions) > 0 {
+		versions[0] = revisions[0].Previous
+	}
+	for _, rev := range revisions {
+		versions = append(versions, rev.Message)
+	}
+
+	h.sendRevisionDiff(w, r, versions)
+}
+
+// ThreadHistory is PostHistory for threads. A version is compared as the title, an empty
+// line and the message.
+func (h *Handler) ThreadHistory(w http.ResponseWriter, r *http.Request) {
+	slugOrId := mux.Vars(r)["slug_or_id"]
+
+	thr, err := h.store.Threads.Get(r.Context(), slugOrId)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	if !h.authorizeEdit(w, r, thr.Forum, thr.Author) {
+		return
+	}
+
+	revisions, err := h.store.Threads.History(r.Context(), slugOrId)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find thread with id " + slugOrId + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	if query.Get("from") == "" && query.Get("to") == "" {
+		sendJSON(revisions, http.StatusOK, &w)
+		return
+	}
+
+	versions := []string{thr.Title + "\n\n" + thr.Message}
+	if len(revisions) > 0 {
+		versions[0] = revisions[0].PreviousTitle + "\n\n" + revisions[0].PreviousMessage
+	}
+	for _, rev := range revisions {
+		versions = append(versions, rev.Title + "\n\n" + rev.Message)
+	}
+
+	h.sendRevisionDiff(w, r, versions)
+}
+
+// sendRevisionDiff answers the diff between versions[from] and versions[to], from defaults
+// to the original and to to the current version.
+func (h *Handler) sendRevisionDiff(w http.ResponseWriter, r *http.Request, versions []string) {
+	query := r.URL.Query()
+
+	from, ok := versionParam(query.Get("from"), 0, len(versions))
+	if !ok {
+		sendError("Bad version " + query.Get("from") + "\n", http.StatusBadRequest, &w)
+		return
+	}
+
+	to, ok := versionParam(query.Get("to"), len(versions)-1, len(versions))
+	if !ok {
+		sendError("Bad version " + query.Get("to") + "\n", http.StatusBadRequest, &w)
+		return
+	}
+
+	fromName, toName := "version " + strconv.Itoa(from), "version " + strconv.Itoa(to)
+
+	sendJSON(&models.RevisionDiff{
+		From: int32(from),
+		To:   int32(to),
+		Diff: diff.Unified(fromName, toName, versions[from], versions[to]),
+	}, http.StatusOK, &w)
+}
+
+func versionParam(value string, def, count int) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 || version >= count {
+		return 0, false
+	}
+
+	return version, true
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Grisha23/ForumsApi/auth"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+// request serves a request of caller, "" - anonymous.
+func request(r http.Handler, method, path, caller, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if caller != "" {
+		req = req.WithContext(auth.WithUser(req.Context(), caller))
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// editServer has forum f of owner with moderator m, thread 1 and post 1 of author a.
+// other is a user without a role.
+func editServer(t *testing.T) *mux.Router {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	for _, nickname := range []string{"owner", "a", "m", "other"} {
+		if err := s.Users.Create(ctx, &models.User{NickName: nickname, Email: nickname + "@mail.ru", FullName: nickname}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Forums.Create(ctx, &models.Forum{Slug: "f", Title: "f", User: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Forums.SetRole(ctx, "f", &models.ForumRole{Nickname: "m", Role: store.RoleModerator, GrantedBy: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	thr := &models.Thread{Author: "a", Title: "title", Message: "one\ntwo"}
+	if err := s.Threads.Create(ctx, "f", thr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Posts.Create(ctx, thr, []models.Post{{Author: "a", Message: "one\ntwo"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(s, Options{Auth: AuthOptions{Enforce: true}})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/post/{id}/details", h.PostDetails)
+	r.HandleFunc("/api/post/{id}/history", h.PostHistory)
+	r.HandleFunc("/api/post/{id}/restore", h.PostRestore)
+	r.HandleFunc("/api/forum/{slug}/details", h.ForumDetails)
+	r.HandleFunc("/api/thread/{slug_or_id}/create", h.PostCreate)
+	r.HandleFunc("/api/thread/{slug_or_id}/details", h.ThreadDetails)
+	r.HandleFunc("/api/thread/{slug_or_id}/history", h.ThreadHistory)
+	r.HandleFunc("/api/thread/{slug_or_id}/posts", h.ThreadPosts)
+	r.HandleFunc("/api/thread/{slug_or_id}/restore", h.ThreadRestore)
+
+	return r
+}
+
+func TestPostHistory(t *testing.T) {
+	r := editServer(t)
+
+	for _, edit := range []struct {
+		caller  string
+		message string
+	}{
+		{"a", "one\nTWO"},
+		{"a", "one\nTWO"}, // Тот же текст - без новой правки
+		{"m", "zero\none\nTWO"},
+	} {
+		if rec := request(r, http.MethodPost, "/api/post/1/details", edit.caller, `{"message": "`+strings.Replace(edit.message, "\n", `\n`, -1)+`"}`); rec.Code != http.StatusOK {
+			t.Fatalf("edit as %s: got %d %s", edit.caller, rec.Code, rec.Body)
+		}
+	}
+
+	rec := request(r, http.MethodGet, "/api/post/1/history", "a", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("history: got %d %s", rec.Code, rec.Body)
+	}
+
+	var revisions []models.PostRevision
+	if err := json.Unmarshal(rec.Body.Bytes(), &revisions); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.PostRevision{
+		{Revision: 1, Editor: "a", Previous: "one\ntwo", Message: "one\nTWO"},
+		{Revision: 2, Editor: "m", Previous: "one\nTWO", Message: "zero\none\nTWO"},
+	}
+	if len(revisions) != len(want) {
+		t.Fatalf("got %+v", revisions)
+	}
+	for i := range want {
+		got := revisions[i]
+		got.Edited = want[i].Edited
+		if got != want[i] {
+			t.Errorf("revision %d: got %+v, want %+v", i+1, revisions[i], want[i])
+		}
+	}
+
+	diffs := []struct {
+		query string
+		from  int32
+		to    int32
+		diff  string
+	}{
+		{"from=0", 0, 2, "--- version 0\n+++ version 2\n@@ -1,2 +1,3 @@\n+zero\n one\n-two\n+TWO\n"},
+		{"to=1", 0, 1, "--- version 0\n+++ version 1\n@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n"},
+		{"from=1&to=2", 1, 2, "--- version 1\n+++ version 2\n@@ -1,2 +1,3 @@\n+zero\n one\n TWO\n"},
+		{"from=2&to=1", 2, 1, "--- version 2\n+++ version 1\n@@ -1,3 +1,2 @@\n-zero\n one\n TWO\n"},
+		{"from=2&to=2", 2, 2, ""},
+	}
+
+	for _, d := range diffs {
+		rec := request(r, http.MethodGet, "/api/post/1/history?"+d.query, "a", "")
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: got %d %s", d.query, rec.Code, rec.Body)
+			continue
+		}
+
+		var got models.RevisionDiff
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != (models.RevisionDiff{From: d.from, To: d.to, Diff: d.diff}) {
+			t.Errorf("%s:\n got %+v\nwant %q", d.query, got, d.diff)
+		}
+	}
+
+	for _, query := range []string{"from=3", "to=-1", "from=x"} {
+		if rec := request(r, http.MethodGet, "/api/post/1/history?"+query, "a", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestPostHistoryUnedited(t *testing.T) {
+	r := editServer(t)
+
+	rec := request(r, http.MethodGet, "/api/post/1/history", "a", "")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("history: got %d %s", rec.Code, rec.Body)
+	}
+
+	// Без правок есть только версия 0
+	rec = request(r, http.MethodGet, "/api/post/1/history?from=0", "a", "")
+	var got models.RevisionDiff
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got != (models.RevisionDiff{}) {
+		t.Errorf("diff: got %d %s", rec.Code, rec.Body)
+	}
+	if rec := request(r, http.MethodGet, "/api/post/1/history?to=1", "a", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("to=1: got %d", rec.Code)
+	}
+}
+
+func TestHistoryAccess(t *testing.T) {
+	r := editServer(t)
+
+	for _, path := range []string{"/api/post/1/history", "/api/thread/1/history"} {
+		for caller, code := range map[string]int{"a": http.StatusOK, "m": http.StatusOK, "owner": http.StatusOK, "other": http.StatusForbidden, "": http.StatusUnauthorized} {
+			if rec := request(r, http.MethodGet, path, caller, ""); rec.Code != code {
+				t.Errorf("%s as %q: got %d, want %d", path, caller, rec.Code, code)
+			}
+		}
+	}
+
+	for _, path := range []string{"/api/post/2/history", "/api/post/x/history", "/api/thread/2/history"} {
+		if rec := request(r, http.MethodGet, path, "a", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestThreadHistory(t *testing.T) {
+	r := editServer(t)
+
+	for _, body := range []string{`{"title": "new title"}`, `{"message": "one\nthree"}`, `{"title": "new title", "message": "one\nthree"}`} {
+		if rec := request(r, http.MethodPost, "/api/thread/1/details", "a", body); rec.Code != http.StatusOK {
+			t.Fatalf("edit %s: got %d %s", body, rec.Code, rec.Body)
+		}
+	}
+
+	rec := request(r, http.MethodGet, "/api/thread/1/history", "a", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("history: got %d %s", rec.Code, rec.Body)
+	}
+
+	var revisions []models.ThreadRevision
+	if err := json.Unmarshal(rec.Body.Bytes(), &revisions); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 2 {
+		t.Fatalf("got %+v", revisions)
+	}
+	for i, want := range []struct {
+		changed string
+		title   string
+		message string
+	}{
+		{"[title]", "new title", "one\ntwo"},
+		{"[message]", "new title", "one\nthree"},
+	} {
+		rev := revisions[i]
+		if rev.Revision != int32(i+1) || rev.Editor != "a" || fmt.Sprint(rev.Changed) != want.changed || rev.Title != want.title || rev.Message != want.message {
+			t.Errorf("revision %d: got %+v", i+1, rev)
+		}
+	}
+	if revisions[0].PreviousTitle != "title" || revisions[1].PreviousMessage != "one\ntwo" {
+		t.Errorf("previous values: got %+v", revisions)
+	}
+
+	rec = request(r, http.MethodGet, "/api/thread/1/history?from=0", "a", "")
+	var got models.RevisionDiff
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "--- version 0\n+++ version 2\n@@ -1,4 +1,4 @@\n-title\n+new title\n \n one\n-two\n+three\n"
+	if got.From != 0 || got.To != 2 || got.Diff != want {
+		t.Errorf("diff:\n got %+v\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/Grisha23/ForumsApi/cursor"
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+// pager keeps the keyset pagination state of a list request. Lists answer the legacy bare
+// array unless the client asks for the wrapped page with ?v=2 or passes a cursor.
+type pager struct {
+	list    string
+	sort    string
+	desc    bool // Порядок списка, из курсора или параметра desc
+	limit   int
+	wrapped bool
+	cursor  *cursor.Cursor
+	middle  bool   // Страница начинается не с начала списка: задан since или курсор
+	filter  string // Фильтры запроса, см. cursor.Cursor.Filter
+}
+
+// newPager reads v, cursor and since. With a cursor the list order and sort come from it,
+// desc and sort are what the request says otherwise. filters name the query parameters that
+// select the items of the list, a cursor is accepted only with the same values of them.
+// It answers 400 for a bad cursor.
+func newPager(w http.ResponseWriter, r *http.Request, list, sort string, desc bool, limit int, filters ...string) (*pager, bool) {
+	query := r.URL.Query()
+
+	p := &pager{
+		list:    list,
+		sort:    sort,
+		desc:    desc,
+		limit:   limit,
+		wrapped: query.Get("v") == "2",
+		middle:  query.Get("since") != "",
+		filter:  filterValues(r, filters...),
+	}
+
+	token := query.Get("cursor")
+	if token == "" {
+		return p, true
+	}
+
+	c, err := cursor.Decode(token)
+	if err != nil || c.List != list {
+		sendError("Bad cursor " + token + "\n", 400, &w)
+		return nil, false
+	}
+
+	if c.Filter != p.filter {
+		sendError("Cursor " + token + " was issued for other filters\n", 400, &w)
+		return nil, false
+	}
+
+	p.cursor = c
+	p.sort, p.desc = c.Sort, c.Desc
+	p.wrapped, p.middle = true, true
+
+	return p, true
+}
+
+// key returns the cursor key or nil for a request without a cursor.
+func (p *pager) key() []string {
+	if p.cursor == nil {
+		return nil
+	}
+	return p.cursor.Key
+}
+
+// readDesc is the order to read the store in: a previous page is read backwards.
+func (p *pager) readDesc() bool {
+	return p.desc != (p.cursor != nil && p.cursor.Back)
+}
+
+// fetchLimit asks the store for one more unit to learn whether the list goes on.
+func (p *pager) fetchLimit() int {
+	if p.wrapped && p.limit > 0 {
+		return p.limit + 1
+	}
+	return p.limit
+}
+
+// send answers items, a slice read with readDesc and fetchLimit. units are the indexes where
+// paging units start: every item, or every root branch for parent_tree. key returns the
+// cursor key of an item.
+func (p *pager) send(w http.ResponseWriter, items interface{}, units []int, key func(item interface{}) []string) {
+	if !p.wrapped {
+		sendJSON(items, http.StatusOK, &w)
+		return
+	}
+
+	v := reflect.ValueOf(items)
+
+	more := p.limit > 0 && len(units) > p.limit
+	if more {
+		v = v.Slice(0, units[p.limit])
+		units = units[:p.limit]
+	}
+
+	back := p.cursor != nil && p.cursor.Back
+	if back {
+		v = reverseUnits(v, units)
+	}
+
+	page := models.Page{Items: v.Interface()}
+
+	if v.Len() > 0 {
+		first := key(v.Index(0).Interface())
+		last := key(v.Index(v.Len() - 1).Interface())
+
+		// Назад читали, начиная со следующей страницы, значит она есть
+		if more || back {
+			page.NextCursor = p.encode(last, false)
+		}
+		if more && back || !back && p.middle {
+			page.PrevCursor = p.encode(first, true)
+		}
+	}
+
+	page.HasMore = page.NextCursor != ""
+
+	sendJSON(page, http.StatusOK, &w)
+}
+
+func (p *pager) encode(key []string, back bool) string {
+	c := cursor.Cursor{List: p.list, Sort: p.sort, Key: key, Desc: p.desc, Back: back, Filter: p.filter}
+	return c.Encode()
+}
+
+// filterValues is the canonical query string of the non-empty parameters among names.
+func filterValues(r *http.Request, names ...string) string {
+	values := url.Values{}
+
+	for _, name := range names {
+		if v := r.URL.Query().Get(name); v != "" {
+			values.Set(name, v)
+		}
+	}
+
+	return values.Encode()
+}
+
+// reverseUnits reverses the order of units keeping the order of items inside each of them.
+func reverseUnits(v reflect.Value, units []int) reflect.Value {
+	res := reflect.MakeSlice(v.Type(), 0, v.Len())
+
+	for i := len(units) - 1; i >= 0; i-- {
+		end := v.Len()
+		if i+1 < len(units) {
+			end = units[i+1]
+		}
+		res = reflect.AppendSlice(res, v.Slice(units[i], end))
+	}
+
+	return res
+}
+
+// everyItem makes units for lists paged by single items.
+func everyItem(n int) []int {
+	units := make([]int, n)
+	for i := range units {
+		units[i] = i
+	}
+	return units
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+var pagesBase = time.Date(2018, 3, 4, 10, 0, 0, 0, time.UTC)
+
+type threadPage struct {
+	Items      []models.Thread `json:"items"`
+	NextCursor string          `json:"next_cursor"`
+	PrevCursor string          `json:"prev_cursor"`
+	HasMore    bool            `json:"has_more"`
+}
+
+// threadsServer serves ForumThreads of forum f with threads of a and b created in the same
+// and different hours, some with equal votes.
+func threadsServer(t *testing.T) *mux.Router {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	for _, nickname := range []string{"a", "b"} {
+		if err := s.Users.Create(ctx, &models.User{NickName: nickname, Email: nickname + "@mail.ru", FullName: nickname}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Forums.Create(ctx, &models.Forum{Slug: "f", Title: "f", User: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, hours := range []int{0, 1, 1, 2, 3, 3, 4, 5} {
+		thr := &models.Thread{Author: []string{"a", "b"}[i%3%2], Created: pagesBase.Add(time.Duration(hours) * time.Hour), Title: "t", Message: "m"}
+		if err := s.Threads.Create(ctx, "f", thr); err != nil {
+			t.Fatal(err)
+		}
+
+		if i%3 == 0 {
+			if _, err := s.Votes.Vote(ctx, fmt.Sprint(thr.Id), &models.Vote{Nickname: "a", Voice: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if i%4 == 1 {
+			if _, err := s.Posts.Create(ctx, thr, []models.Post{{Author: "b", Message: "p"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/forum/{slug}/threads", New(s, Options{}).ForumThreads)
+
+	return r
+}
+
+func getThreads(t *testing.T, r http.Handler, query url.Values) (threadPage, int) {
+	req := httptest.NewRequest(http.MethodGet, "/api/forum/f/threads?"+query.Encode(), nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var page threadPage
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatalf("%s: %v", query.Encode(), err)
+		}
+	}
+
+	return page, rec.Code
+}
+
+func pageIds(thrs []models.Thread) string {
+	ids := make([]int32, len(thrs))
+	for i := range thrs {
+		ids[i] = thrs[i].Id
+	}
+	return fmt.Sprint(ids)
+}
+
+// Переход по next_cursor дает весь список, по prev_cursor - те же страницы в обратном порядке
+func TestThreadCursors(t *testing.T) {
+	r := threadsServer(t)
+
+	at := func(hours int) string {
+		return pagesBase.Add(time.Duration(hours) * time.Hour).Format(time.RFC3339)
+	}
+
+	filters := []url.Values{
+		{},
+		{"since": {at(1)}},
+		{"until": {at(4)}},
+		{"since": {at(1)}, "until": {at(3)}},
+		{"since": {at(3)}, "until": {at(1)}},
+		{"author": {"A"}},
+		{"author": {"b"}, "since": {at(1)}},
+	}
+
+	for _, sortName := range []string{store.ThreadsByCreated, store.ThreadsByVotes, store.ThreadsByActivity, store.ThreadsByPosts} {
+		for _, desc := range []string{"false", "true"} {
+			for _, f := range filters {
+				query := url.Values{"v": {"2"}, "sort": {sortName}, "desc": {desc}}
+				for name, v := range f {
+					query[name] = v
+				}
+				name := query.Encode()
+
+				whole, code := getThreads(t, r, query)
+				if code != http.StatusOK || whole.HasMore {
+					t.Fatalf("%s: %d %+v", name, code, whole)
+				}
+				// Окно в обратном порядке пусто, его проверяет TestThreadWindow
+				if len(whole.Items) == 0 {
+					continue
+				}
+
+				query.Set("limit", "2")
+				pages := make([]threadPage, 0)
+				items := make([]models.Thread, 0)
+
+				for len(pages) <= len(whole.Items) {
+					page, code := getThreads(t, r, query)
+					if code != http.StatusOK {
+						t.Fatalf("%s: %d", query.Encode(), code)
+					}
+
+					pages = append(pages, page)
+					items = append(items, page.Items...)
+
+					if page.NextCursor == "" {
+						break
+					}
+					query.Set("cursor", page.NextCursor)
+				}
+
+				if pageIds(items) != pageIds(whole.Items) {
+					t.Errorf("%s next:\n got %s\nwant %s", name, pageIds(items), pageIds(whole.Items))
+					continue
+				}
+
+				for i := len(pages) - 1; i > 0; i-- {
+					if pages[i].PrevCursor == "" {
+						t.Errorf("%s: no prev_cursor on page %d", name, i)
+						break
+					}
+
+					query.Set("cursor", pages[i].PrevCursor)
+					prev, code := getThreads(t, r, query)
+
+					if code != http.StatusOK || pageIds(prev.Items) != pageIds(pages[i-1].Items) {
+						t.Errorf("%s prev of page %d: %d\n got %s\nwant %s", name, i, code, pageIds(prev.Items), pageIds(pages[i-1].Items))
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// В убывающем списке since - поздняя граница, until - ранняя, при любой сортировке
+func TestThreadWindow(t *testing.T) {
+	r := threadsServer(t)
+
+	at := func(hours int) string {
+		return pagesBase.Add(time.Duration(hours) * time.Hour).Format(time.RFC3339)
+	}
+
+	cases := []struct {
+		desc   string
+		window url.Values
+		want   string
+	}{
+		{"false", url.Values{"since": {at(1)}, "until": {at(3)}}, "[2 3 4 5 6]"},
+		{"true", url.Values{"since": {at(3)}, "until": {at(1)}}, "[2 3 4 5 6]"},
+		{"false", url.Values{"since": {at(3)}, "until": {at(1)}}, "[]"},
+		{"true", url.Values{"since": {at(1)}, "until": {at(3)}}, "[]"},
+		{"false", url.Values{"since": {at(3)}}, "[5 6 7 8]"},
+		{"true", url.Values{"since": {at(3)}}, "[1 2 3 4 5 6]"},
+		{"false", url.Values{"until": {at(1)}}, "[1 2 3]"},
+		{"true", url.Values{"until": {at(1)}}, "[2 3 4 5 6 7 8]"},
+	}
+
+	for _, sortName := range []string{store.ThreadsByCreated, store.ThreadsByVotes, store.ThreadsByActivity, store.ThreadsByPosts} {
+		for _, c := range cases {
+			query := url.Values{"v": {"2"}, "sort": {sortName}, "desc": {c.desc}}
+			for name, v := range c.window {
+				query[name] = v
+			}
+
+			page, code := getThreads(t, r, query)
+			if code != http.StatusOK {
+				t.Errorf("%s: got %d", query.Encode(), code)
+				continue
+			}
+
+			sort.Slice(page.Items, func(i, j int) bool { return page.Items[i].Id < page.Items[j].Id })
+			if got := pageIds(page.Items); got != c.want {
+				t.Errorf("%s:\n got %s\nwant %s", query.Encode(), got, c.want)
+			}
+		}
+	}
+}
+
+func TestThreadCursorFilters(t *testing.T) {
+	r := threadsServer(t)
+
+	query := url.Values{"v": {"2"}, "limit": {"2"}, "author": {"a"}}
+	page, code := getThreads(t, r, query)
+	if code != http.StatusOK || page.NextCursor == "" {
+		t.Fatalf("%d %+v", code, page)
+	}
+
+	query.Set("cursor", page.NextCursor)
+
+	for _, change := range []url.Values{
+		{"author": {"b"}},
+		{"author": {""}},
+		{"since": {pagesBase.Format(time.RFC3339)}},
+		{"until": {pagesBase.Format(time.RFC3339)}},
+	} {
+		changed := url.Values{}
+		for name, v := range query {
+			changed[name] = v
+		}
+		for name, v := range change {
+			changed[name] = v
+		}
+
+		if _, code := getThreads(t, r, changed); code != http.StatusBadRequest {
+			t.Errorf("%s: got %d", changed.Encode(), code)
+		}
+	}
+}
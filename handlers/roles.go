@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Grisha23/ForumsApi/auth"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) isAdmin(nickname string) bool {
+	for _, admin := range h.opts.Auth.Admins {
+		if strings.EqualFold(admin, nickname) {
+			return true
+		}
+	}
+	return false
+}
+
+// forumRole returns the rights of nickname in forum.
+func (h *Handler) forumRole(ctx context.Context, forum *models.Forum, nickname string) (auth.Role, error) {
+	if h.isAdmin(nickname) {
+		return auth.RoleAdmin, nil
+	}
+	if strings.EqualFold(forum.User, nickname) {
+		return auth.RoleOwner, nil
+	}
+
+	role, err := h.store.Forums.Role(ctx, forum.Slug, nickname)
+	if err != nil {
+		return auth.RoleMember, err
+	}
+
+	switch role {
+	case store.RoleModerator:
+		return auth.RoleModerator, nil
+	case store.RoleBanned:
+		return auth.RoleBanned, nil
+	}
+
+	return auth.RoleMember, nil
+}
+
+// authorizeEdit checks that the caller may change a thread or post of author in forum:
+// the author himself unless banned, or a moderator of the forum and above.
+func (h *Handler) authorizeEdit(w http.ResponseWriter, r *http.Request, forumSlug, author string) bool {
+	if !h.opts.Auth.Enforce {
+		return true
+	}
+
+	caller := auth.User(r.Context())
+	if caller == "" {
+		sendError("Authentication required\n", http.StatusUnauthorized, &w)
+		return false
+	}
+
+	forum, err := h.store.Forums.Get(r.Context(), forumSlug)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return false
+	}
+
+	role, err := h.forumRole(r.Context(), forum, caller)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return false
+	}
+
+	if role == auth.RoleBanned {
+		sendError(caller + " is banned in forum " + forum.Slug + "\n", http.StatusForbidden, &w)
+		return false
+	}
+
+	if !strings.EqualFold(caller, author) && role < auth.RoleModerator {
+		sendError("Only " + author + " or moderators can do this\n", http.StatusForbidden, &w)
+		return false
+	}
+
+	return true
+}
+
+// checkBanned answers 403 if any of authors is banned in the forum. When auth is enforced
+// the caller must be every one of authors, and it's the caller who is checked: otherwise a
+// banned user could write on behalf of anyone.
+func (h *Handler) checkBanned(w http.ResponseWriter, r *http.Request, forumSlug string, authors []string) bool {
+	if h.opts.Auth.Enforce {
+		caller := auth.User(r.Context())
+		if caller == "" {
+			sendError("Authentication required\n", http.StatusUnauthorized, &w)
+			return false
+		}
+
+		for _, author := range authors {
+			if !strings.EqualFold(author, caller) {
+				sendError("Only " + caller + " can be the author\n", http.StatusForbidden, &w)
+				return false
+			}
+		}
+
+		authors = []string{caller}
+	}
+
+	banned, err := h.store.Forums.Banned(r.Context(), forumSlug, authors)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return false
+	}
+
+	if banned != "" {
+		sendError(banned + " is banned in forum " + forumSlug + "\n", http.StatusForbidden, &w)
+		return false
+	}
+
+	return true
+}
+
+// ForumModerators lists moderators, the owner and admins grant the right with POST {"nickname": ...}.
+func (h *Handler) ForumModerators(w http.ResponseWriter, r *http.Request) {
+	h.forumRoles(w, r, store.RoleModerator, auth.RoleOwner)
+}
+
+// ForumModerator revokes moderator rights with DELETE.
+func (h *Handler) ForumModerator(w http.ResponseWriter, r *http.Request) {
+	h.revokeForumRole(w, r, store.RoleModerator, auth.RoleOwner)
+}
+
+// ForumBans lists banned users, moderators and above ban with POST {"nickname": ...}.
+func (h *Handler) ForumBans(w http.ResponseWriter, r *http.Request) {
+	h.forumRoles(w, r, store.RoleBanned, auth.RoleModerator)
+}
+
+// ForumBan lifts a ban with DELETE.
+func (h *Handler) ForumBan(w http.ResponseWriter, r *http.Request) {
+	h.revokeForumRole(w, r, store.RoleBanned, auth.RoleModerator)
+}
+
+func (h *Handler) forumRoles(w http.ResponseWriter, r *http.Request, role string, required auth.Role) {
+	slug := mux.Vars(r)["slug"]
+
+	if r.Method == http.MethodGet {
+		roles, err := h.store.Forums.Roles(r.Context(), slug, role)
+
+		if err == store.ErrNotFound {
+			sendError("Can't find forum with slug " + slug + "\n", 404, &w)
+			return
+		}
+		if err != nil {
+			sendInternalError(err, &w, r)
+			return
+		}
+
+		sendJSON(roles, http.StatusOK, &w)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	grant := models.ForumRole{}
+
+	if err := json.Unmarshal(body, &grant); err != nil || grant.Nickname == "" {
+		sendError("Bad role, expected {\"nickname\": ...}\n", http.StatusBadRequest, &w)
+		return
+	}
+
+	forum, caller, ok := h.roleManager(w, r, slug, grant.Nickname, required)
+	if !ok {
+		return
+	}
+
+	grant.Role = role
+	grant.GrantedBy = caller
+
+	err = h.store.Forums.SetRole(r.Context(), forum.Slug, &grant)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find user with nickname " + grant.Nickname + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	sendJSON(grant, http.StatusCreated, &w)
+}
+
+func (h *Handler) revokeForumRole(w http.ResponseWriter, r *http.Request, role string, required auth.Role) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	nickname := vars["nickname"]
+
+	forum, _, ok := h.roleManager(w, r, slug, nickname, required)
+	if !ok {
+		return
+	}
+
+	err := h.store.Forums.DeleteRole(r.Context(), forum.Slug, nickname, role)
+
+	if err == store.ErrNotFound {
+		sendError(nickname + " is not " + role + " in forum " + forum.Slug + "\n", 404, &w)
+		return
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// roleManager checks that the caller has at least the required role in the forum and
+// outranks target, so moderators can't ban each other and nobody can ban the owner.
+func (h *Handler) roleManager(w http.ResponseWriter, r *http.Request, slug, target string, required auth.Role) (*models.Forum, string, bool) {
+	caller := auth.User(r.Context())
+	if caller == "" {
+		sendError("Authentication required\n", http.StatusUnauthorized, &w)
+		return nil, "", false
+	}
+
+	forum, err := h.store.Forums.Get(r.Context(), slug)
+
+	if err == store.ErrNotFound {
+		sendError("Can't find forum with slug " + slug + "\n", 404, &w)
+		return nil, "", false
+	}
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return nil, "", false
+	}
+
+	callerRole, err := h.forumRole(r.Context(), forum, caller)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return nil, "", false
+	}
+
+	targetRole, err := h.forumRole(r.Context(), forum, target)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return nil, "", false
+	}
+
+	if callerRole < required {
+		sendError("Only " + required.String() + " and above can do this\n", http.StatusForbidden, &w)
+		return nil, "", false
+	}
+
+	if targetRole >= callerRole || targetRole >= auth.RoleOwner {
+		sendError("Can't change the role of " + target + ", who is " + targetRole.String() + "\n", http.StatusForbidden, &w)
+		return nil, "", false
+	}
+
+	return forum, caller, true
+}
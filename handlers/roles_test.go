@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Grisha23/ForumsApi/auth"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/gorilla/mux"
+)
+
+// bannedServer has forum f with a thread, user bad is banned there, good is not.
+func bannedServer(t *testing.T) *mux.Router {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	for _, nickname := range []string{"owner", "bad", "good"} {
+		if err := s.Users.Create(ctx, &models.User{NickName: nickname, Email: nickname + "@mail.ru", FullName: nickname}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Forums.Create(ctx, &models.Forum{Slug: "f", Title: "f", User: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Threads.Create(ctx, "f", &models.Thread{Author: "owner", Title: "t", Message: "m"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Forums.SetRole(ctx, "f", &models.ForumRole{Nickname: "bad", Role: store.RoleBanned, GrantedBy: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(s, Options{Auth: AuthOptions{Enforce: true}})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/forum/{slug}/create", h.ThreadCreate)
+	r.HandleFunc("/api/thread/{slug_or_id}/create", h.PostCreate)
+
+	return r
+}
+
+func TestBannedCallerCantWrite(t *testing.T) {
+	r := bannedServer(t)
+
+	cases := []struct {
+		caller string
+		path   string
+		body   string
+		code   int
+	}{
+		{"bad", "/api/thread/1/create", `[{"author": "good", "message": "m"}]`, http.StatusForbidden},
+		{"bad", "/api/thread/1/create", `[{"author": "bad", "message": "m"}, {"author": "good", "message": "m"}]`, http.StatusForbidden},
+		{"bad", "/api/thread/1/create", `[{"author": "bad", "message": "m"}]`, http.StatusForbidden},
+		{"good", "/api/thread/1/create", `[{"author": "bad", "message": "m"}]`, http.StatusForbidden},
+		{"", "/api/thread/1/create", `[{"author": "good", "message": "m"}]`, http.StatusUnauthorized},
+		{"GOOD", "/api/thread/1/create", `[{"author": "good", "message": "m"}]`, http.StatusCreated},
+
+		{"bad", "/api/forum/f/create", `{"author": "good", "title": "t", "message": "m"}`, http.StatusForbidden},
+		{"bad", "/api/forum/f/create", `{"author": "bad", "title": "t", "message": "m"}`, http.StatusForbidden},
+		{"", "/api/forum/f/create", `{"author": "good", "title": "t", "message": "m"}`, http.StatusUnauthorized},
+		{"good", "/api/forum/f/create", `{"author": "good", "title": "t", "message": "m"}`, http.StatusCreated},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(c.body))
+		if c.caller != "" {
+			req = req.WithContext(auth.WithUser(req.Context(), c.caller))
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != c.code {
+			t.Errorf("%s %s as %q: got %d, want %d: %s", c.path, c.body, c.caller, rec.Code, c.code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/cursor"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+)
+
+const (
+	searchLimit    = 20  // Размер страницы по умолчанию
+	searchMaxLimit = 100
+)
+
+// Search finds posts and threads: /api/search?q=...&forum=...&author=...&since=...&type=post|thread.
+// The next page is requested with cursor set to the next field of the previous answer.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := store.SearchFilter{
+		Query:  strings.TrimSpace(query.Get("q")),
+		Forum:  query.Get("forum"),
+		Author: query.Get("author"),
+		Kind:   query.Get("type"),
+		Limit:  searchLimit,
+	}
+
+	if filter.Query == "" {
+		sendError("Empty query\n", 400, &w)
+		return
+	}
+
+	if filter.Kind != "" && filter.Kind != store.SearchPost && filter.Kind != store.SearchThread {
+		sendError("Bad type " + filter.Kind + "\n", 400, &w)
+		return
+	}
+
+	var err error
+
+	if limitVal := query.Get("limit"); limitVal != "" {
+		filter.Limit, err = strconv.Atoi(limitVal)
+		if err != nil || filter.Limit <= 0 || filter.Limit > searchMaxLimit {
+			sendError("Bad limit " + limitVal + "\n", 400, &w)
+			return
+		}
+	}
+	if sinceVal := query.Get("since"); sinceVal != "" {
+		filter.Since, err = time.Parse(time.RFC3339Nano, sinceVal)
+		if err != nil {
+			sendError("Bad since " + sinceVal + "\n", 400, &w)
+			return
+		}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		filter.After = decodeSearchCursor(cursor, searchFilters(r))
+		if filter.After == nil {
+			sendError("Bad cursor " + cursor + "\n", 400, &w)
+			return
+		}
+	}
+
+	// Лишний результат показывает, есть ли следующая страница, как в pager.fetchLimit
+	limit := filter.Limit
+	filter.Limit++
+
+	hits, err := h.store.Search.Search(r.Context(), filter)
+	if err != nil {
+		sendInternalError(err, &w, r)
+		return
+	}
+
+	result := models.SearchResult{Hits: hits}
+
+	if len(hits) > limit {
+		result.Hits = hits[:limit]
+		last := result.Hits[limit-1]
+		result.Next = encodeSearchCursor(store.SearchKey{Rank: last.Rank, Kind: last.Kind, Id: last.Id}, searchFilters(r))
+	}
+
+	sendJSON(result, http.StatusOK, &w)
+}
+
+// searchFilters are the parameters a search cursor is bound to, the rank depends on q.
+func searchFilters(r *http.Request) string {
+	return filterValues(r, "q", "forum", "author", "type", "since")
+}
+
+// The search cursor keeps the rank, kind and id of the last hit.
+func encodeSearchCursor(k store.SearchKey, filter string) string {
+	c := cursor.Cursor{
+		List:   "search",
+		Key:    []string{strconv.FormatFloat(float64(k.Rank), 'g', -1, 32), k.Kind, strconv.FormatInt(k.Id, 10)},
+		Desc:   true,
+		Filter: filter,
+	}
+	return c.Encode()
+}
+
+func decodeSearchCursor(token, filter string) *store.SearchKey {
+	c, err := cursor.Decode(token)
+	if err != nil || c.List != "search" || len(c.Key) != 3 || c.Filter != filter {
+		return nil
+	}
+
+	rank, err := strconv.ParseFloat(c.Key[0], 32)
+	if err != nil {
+		return nil
+	}
+
+	id, err := strconv.ParseInt(c.Key[2], 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return &store.SearchKey{Rank: float32(rank), Kind: c.Key[1], Id: id}
+}
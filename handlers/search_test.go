@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/Grisha23/ForumsApi/store"
+)
+
+// searchServer has n posts with the word go and the thread they are in, which has no such word.
+func searchServer(t *testing.T, n int) http.Handler {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	if err := s.Users.Create(ctx, &models.User{NickName: "a", Email: "a@mail.ru", FullName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Forums.Create(ctx, &models.Forum{Slug: "f", Title: "f", User: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	thr := &models.Thread{Author: "a", Title: "t", Message: "m"}
+	if err := s.Threads.Create(ctx, "f", thr); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := make([]models.Post, n)
+	for i := range posts {
+		posts[i] = models.Post{Author: "a", Message: "a <i>go</i> b" + strings.Repeat(" x", i)}
+	}
+	if _, err := s.Posts.Create(ctx, thr, posts); err != nil {
+		t.Fatal(err)
+	}
+
+	return http.HandlerFunc(New(s, Options{}).Search)
+}
+
+func search(t *testing.T, h http.Handler, query url.Values) (models.SearchResult, int) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/search?"+query.Encode(), nil))
+
+	var result models.SearchResult
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: %v", query.Encode(), err)
+		}
+	}
+
+	return result, rec.Code
+}
+
+func TestSearchNext(t *testing.T) {
+	cases := []struct {
+		posts int
+		limit string
+		pages []int
+	}{
+		{0, "3", []int{0}},
+		{2, "3", []int{2}},
+		{3, "3", []int{3}},
+		{4, "3", []int{3, 1}},
+		{6, "3", []int{3, 3}},
+		{7, "3", []int{3, 3, 1}},
+		{21, "", []int{20, 1}},
+	}
+
+	for _, c := range cases {
+		h := searchServer(t, c.posts)
+		query := url.Values{"q": {"go"}, "limit": {c.limit}}
+		if c.limit == "" {
+			query.Del("limit")
+		}
+
+		pages := make([]int, 0)
+		ids := make(map[int64]bool)
+
+		for len(pages) <= c.posts {
+			result, code := search(t, h, query)
+			if code != http.StatusOK {
+				t.Fatalf("%s: got %d", query.Encode(), code)
+			}
+
+			pages = append(pages, len(result.Hits))
+			for _, hit := range result.Hits {
+				ids[hit.Id] = true
+			}
+
+			if result.Next == "" {
+				break
+			}
+			query.Set("cursor", result.Next)
+		}
+
+		if len(ids) != c.posts || len(pages) != len(c.pages) {
+			t.Errorf("%d posts by %q: got pages %v, %d hits, want pages %v", c.posts, c.limit, pages, len(ids), c.pages)
+			continue
+		}
+		for i := range pages {
+			if pages[i] != c.pages[i] {
+				t.Errorf("%d posts by %q: got pages %v, want %v", c.posts, c.limit, pages, c.pages)
+				break
+			}
+		}
+	}
+}
+
+func TestSearchSnippetEscaped(t *testing.T) {
+	result, code := search(t, searchServer(t, 1), url.Values{"q": {"go"}})
+	if code != http.StatusOK || len(result.Hits) != 1 {
+		t.Fatalf("%d %+v", code, result)
+	}
+
+	if want := "a &lt;i&gt;<b>go</b>&lt;/i&gt; b"; result.Hits[0].Snippet != want {
+		t.Errorf("got %q, want %q", result.Hits[0].Snippet, want)
+	}
+}
+
+func TestSearchBadCursor(t *testing.T) {
+	h := searchServer(t, 4)
+
+	result, _ := search(t, h, url.Values{"q": {"go"}, "limit": {"2"}})
+	if result.Next == "" {
+		t.Fatalf("no next: %+v", result)
+	}
+
+	for _, query := range []url.Values{
+		{"q": {"go"}, "limit": {"2"}, "cursor": {"garbage"}},
+		{"q": {"x"}, "limit": {"2"}, "cursor": {result.Next}},
+		{"q": {"go"}, "type": {"thread"}, "cursor": {result.Next}},
+	} {
+		if _, code := search(t, h, query); code != http.StatusBadRequest {
+			t.Errorf("%s: got %d", query.Encode(), code)
+		}
+	}
+}
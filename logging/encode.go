@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// value converts a field value to something both encoders print the same way.
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+// pairs calls fn for every key-value pair, an odd trailing value gets the key "!BADKEY".
+func pairs(fields []interface{}, fn func(key string, v interface{})) {
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			fn("!BADKEY", value(fields[i]))
+			break
+		}
+
+		key, ok := fields[i].(string)
+		if !ok {
+			key = fmt.Sprint(fields[i])
+		}
+
+		fn(key, value(fields[i+1]))
+	}
+}
+
+func encodeJSON(fields []interface{}) []byte {
+	buf := []byte{'{'}
+
+	pairs(fields, func(key string, v interface{}) {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+
+		k, _ := json.Marshal(key)
+		val, err := json.Marshal(v)
+		if err != nil {
+			val, _ = json.Marshal(fmt.Sprint(v))
+		}
+
+		buf = append(buf, k...)
+		buf = append(buf, ':')
+		buf = append(buf, val...)
+	})
+
+	return append(buf, '}', '\n')
+}
+
+func encodeLogfmt(fields []interface{}) []byte {
+	var buf []byte
+
+	pairs(fields, func(key string, v interface{}) {
+		if len(buf) > 0 {
+			buf = append(buf, ' ')
+		}
+
+		buf = append(buf, key...)
+		buf = append(buf, '=')
+
+		switch v := v.(type) {
+		case nil:
+		case string:
+			buf = appendLogfmtString(buf, v)
+		case float64:
+			buf = strconv.AppendFloat(buf, v, 'f', -1, 64)
+		case float32:
+			buf = strconv.AppendFloat(buf, float64(v), 'f', -1, 32)
+		default:
+			buf = appendLogfmtString(buf, fmt.Sprint(v))
+		}
+	})
+
+	return append(buf, '\n')
+}
+
+func appendLogfmtString(buf []byte, s string) []byte {
+	if s != "" && !strings.ContainsAny(s, " =\"\t\r\n\\") {
+		return append(buf, s...)
+	}
+	return strconv.AppendQuote(buf, s)
+}
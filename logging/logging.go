@@ -0,0 +1,147 @@
+// Package logging writes structured, leveled records in logfmt or JSON.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+const (
+	FormatLogfmt = "logfmt"
+	FormatJSON   = "json"
+)
+
+// Logger is safe for concurrent use. Loggers created with With share the sink of their parent.
+type Logger struct {
+	sink   *sink
+	level  Level
+	json   bool
+	sample float64
+	fields []interface{}
+}
+
+type sink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// New creates a logger writing records of at least level to w.
+// Records below LevelWarn are written only with probability sample (1 - all of them),
+// so warnings and errors never get lost to sampling.
+func New(w io.Writer, level Level, format string, sample float64) (*Logger, error) {
+	switch format {
+	case FormatLogfmt, FormatJSON:
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	if sample < 0 || sample > 1 {
+		return nil, fmt.Errorf("sample rate must be between 0 and 1, got %v", sample)
+	}
+
+	return &Logger{
+		sink:   &sink{w: w},
+		level:  level,
+		json:   format == FormatJSON,
+		sample: sample,
+	}, nil
+}
+
+// Open returns the writer for a sink name: "stdout", "stderr" or a file path (appended to).
+func Open(output string) (io.WriteCloser, error) {
+	switch output {
+	case "", "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "stderr":
+		return nopCloser{os.Stderr}, nil
+	}
+
+	return os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// With returns a logger that adds the key-value pairs to every record.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	child := *l
+	child.fields = append(append([]interface{}{}, l.fields...), kv...)
+	return &child
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.log(LevelInfo, msg, kv) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.log(LevelWarn, msg, kv) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+// Log writes a record with a level chosen at run time, e.g. by response status.
+func (l *Logger) Log(level Level, msg string, kv ...interface{}) { l.log(level, msg, kv) }
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	if level < LevelWarn && l.sample < 1 && rand.Float64() >= l.sample {
+		return
+	}
+
+	fields := make([]interface{}, 0, 6+len(l.fields)+len(kv))
+	fields = append(fields, "time", time.Now().Format(time.RFC3339Nano), "level", level.String(), "msg", msg)
+	fields = append(fields, l.fields...)
+	fields = append(fields, kv...)
+
+	var line []byte
+	if l.json {
+		line = encodeJSON(fields)
+	} else {
+		line = encodeLogfmt(fields)
+	}
+
+	l.sink.mu.Lock()
+	l.sink.w.Write(line)
+	l.sink.mu.Unlock()
+}
+
+// Discard is used when nothing was put into the context.
+var Discard = &Logger{sink: &sink{w: ioutil.Discard}, level: LevelError + 1}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"github.com/Grisha23/ForumsApi/auth"
+	"github.com/Grisha23/ForumsApi/config"
 	"github.com/Grisha23/ForumsApi/handlers"
+	"github.com/Grisha23/ForumsApi/logging"
+	"github.com/Grisha23/ForumsApi/migrations"
+	"github.com/Grisha23/ForumsApi/store"
+	"github.com/Grisha23/ForumsApi/tracing"
 	// "ForumsApi/handlers"
+	"context"
 	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -13,31 +23,92 @@ import (
 )
 
 
-func AccessLogMiddleware (mux *mux.Router,) http.HandlerFunc   {
+func AccessLogMiddleware (router *mux.Router, features config.Features, log *logging.Logger, tracer *tracing.Tracer) http.HandlerFunc   {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		begin := time.Now()
 
-		mux.ServeHTTP(w, r)
+		// Шаблон маршрута вместо пути, чтобы записи по /api/user/{nickname}/profile можно было группировать
+		route := ""
+		var match mux.RouteMatch
+		if router.Match(r, &match) && match.Route != nil {
+			route, _ = match.Route.GetPathTemplate()
+		}
+
+		// ID от балансировщика или клиента сохраняем, чтобы по нему можно было найти запрос во всех логах
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = logging.NewRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		ctx := logging.WithRequestID(r.Context(), requestID)
+
+		if tracer != nil {
+			ctx = tracing.NewContext(ctx, tracer)
+		}
 
-		sortVal := r.URL.Query().Get("sort")
-		if sortVal != "" {
-			fmt.Println("method ", r.Method, "; url", r.URL.Path,  " Sort: ", sortVal,
-				"Time work: ", time.Since(begin))
-		} else {
-			fmt.Println("method ", r.Method, "; url", r.URL.Path,
-				"Time work: ", time.Since(begin))
+		ctx, span := tracing.StartRemote(ctx, r.Method+" "+route, r.Header.Get("traceparent"))
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("http.target", r.URL.RequestURI())
+		span.SetAttr("request_id", requestID)
 
+		reqLog := log.With("request_id", requestID)
+		if span != nil {
+			reqLog = reqLog.With("trace_id", span.TraceID())
 		}
 
-		HitStat.With(prometheus.Labels{
-			"url":    r.URL.Path,
-			"method": r.Method,
-			"code":   w.Header().Get("Status-Code"),
-		}).Inc()
+		r = r.WithContext(logging.NewContext(ctx, reqLog))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		// Паника обработчика не должна оставить запрос в счетчике навсегда
+		func() {
+			requestsInFlight.Inc()
+			defer requestsInFlight.Dec()
+
+			router.ServeHTTP(rec, r)
+		}()
+
+		latency := time.Since(begin)
+
+		span.SetAttr("http.status_code", rec.status)
+		span.SetAttr("http.response_size", rec.size)
+		span.SetError(rec.err)
+		span.End()
+
+		// Ошибки пишутся всегда, даже если access log выключен
+		if features.AccessLog || rec.err != nil || rec.status >= http.StatusInternalServerError {
+			level := logging.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = logging.LevelError
+			}
+
+			fields := []interface{}{
+				"method", r.Method,
+				"url", r.URL.Path,
+				"route", route,
+				"status", rec.status,
+				"size", rec.size,
+				"latency_ms", float64(latency) / float64(time.Millisecond),
+			}
+
+			if sortVal := r.URL.Query().Get("sort"); sortVal != "" {
+				fields = append(fields, "sort", sortVal)
+			}
+
+			if rec.err != nil {
+				fields = append(fields, "error", rec.err)
+				if code := store.ErrorCode(rec.err); code != "" {
+					fields = append(fields, "db_code", code)
+				}
+			}
+
+			reqLog.Log(level, "request", fields...)
+		}
 
+		observeRequest(r, route, rec, latency)
 
-		rps.Add(1)
-		
 		// if error != nil {
 		// 	fmt.Println("error here")
 		// 	return
@@ -57,74 +128,297 @@ func AccessLogMiddleware (mux *mux.Router,) http.HandlerFunc   {
 	})
 }
 
-var (
-	HitStat = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: "ForumsApi",
-			Subsystem: "hit_stat",
-			Name:      "HitStat",
-			Help:      "Hit info.",
-		},
-		[]string{
-			"url",
-			"method",
-			"code",
-		},
-	)
+// reconcileCounters periodically fixes the drift of the status counters until ctx is done.
+func reconcileCounters(ctx context.Context, service store.ServiceStore, interval time.Duration, log *logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	rps =prometheus.NewCounter(
-		prometheus.CounterOpts{
-		  Name: "rps_total",
-		})
-	)
-	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-func init() {
-	// Metrics have to be registered to be exposed:
-	prometheus.MustRegister(HitStat)
-	prometheus.MustRegister(rps)
+		drift, err := service.Reconcile(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("can't reconcile status counters", "error", err)
+			}
+			continue
+		}
 
+		for name, delta := range drift {
+			log.Warn("status counter drifted, corrected", "counter", name, "delta", delta)
+		}
+	}
 }
 
+// validRequestID accepts short IDs of printable characters, so a client can't break log lines with it.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
 
-func main(){
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' || id[i] == '"' || id[i] == '\\' {
+			return false
+		}
+	}
 
-	// The Handler function provides a default handler to expose metrics
-	// via an HTTP server. "/metrics" is the usual endpoint for that.
+	return true
+}
+
+// statusRecorder remembers what the handler wrote, net/http doesn't expose it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+	err         error
+}
 
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
 
-	db, _ := handlers.InitDb()
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += n
+	return n, err
+}
 
-	router := mux.NewRouter()
+// RecordError implements handlers.ErrorRecorder.
+func (rec *statusRecorder) RecordError(err error) {
+	rec.err = err
+}
 
-	http.Handle("/metrics", promhttp.Handler())
+func main(){
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
 
-	router.HandleFunc("/api/forum/create", handlers.ForumCreate)
-	router.HandleFunc(`/api/forum/{slug}/create`, handlers.ThreadCreate)
-	router.HandleFunc(`/api/forum/{slug}/details`, handlers.ForumDetails) // +
-	router.HandleFunc(`/api/forum/{slug}/threads`, handlers.ForumThreads) // - не оч
-	router.HandleFunc(`/api/forum/{slug}/users`, handlers.ForumUsers) // +
+	os.Exit(run())
+}
 
-	router.HandleFunc(`/api/post/{id}/details`, handlers.PostDetails) // +
+// run serves requests until SIGINT/SIGTERM and returns the exit code.
+// It's separate from main so deferred cleanups run before os.Exit.
+func run() int {
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	logOut, err := logging.Open(cfg.Log.Output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can't open log output:", err)
+		return 1
+	}
+
+	defer logOut.Close()
+
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+
+	log, err := logging.New(logOut, level, cfg.Log.Format, cfg.Log.SampleRate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var tracer *tracing.Tracer
+
+	switch cfg.Tracing.Exporter {
+	case "stdout":
+		tracer = tracing.New(tracing.NewWriterExporter(os.Stdout), cfg.Tracing.SampleRate)
+	case "http":
+		exporter := tracing.NewHTTPExporter(cfg.Tracing.Endpoint, func(err error) {
+			log.Warn("can't export spans", "error", err)
+		})
+		tracer = tracing.New(exporter, cfg.Tracing.SampleRate)
+	}
 
-	router.HandleFunc(`/api/service/clear`, handlers.ServiceClear)
-	router.HandleFunc(`/api/service/status`, handlers.ServiceStatus) // -
+	if tracer != nil {
+		// Отправляем оставшиеся спаны после остановки сервера
+		defer tracer.Close()
+	}
 
-	router.HandleFunc(`/api/thread/{slug_or_id}/create`, handlers.PostCreate)
-	router.HandleFunc(`/api/thread/{slug_or_id}/details`, handlers.ThreadDetails) // +
-	router.HandleFunc(`/api/thread/{slug_or_id}/posts`, handlers.ThreadPosts) // +
-	router.HandleFunc(`/api/thread/{slug_or_id}/vote`, handlers.ThreadVote)
+	var st *store.Store
 
-	router.HandleFunc(`/api/user/{nickname}/create`, handlers.UserCreate)
-	router.HandleFunc(`/api/user/{nickname}/profile`, handlers.UserProfile)  // + быстро
+	if cfg.Storage == config.StorageMemory {
+		st = store.NewMemory()
 
-	siteHandler := AccessLogMiddleware(router)
+		log.Warn("using in-memory storage, data is lost on exit")
+	} else {
+		db, err := store.OpenPostgres(cfg.DB)
+		if err != nil {
+			log.Error("can't connect to database", "error", err)
+			return 1
+		}
 
-	http.Handle("/", siteHandler)
-	http.ListenAndServe(":5000", nil)
+		// Закрываем пул только после того, как сервер дождался всех запросов
+		defer db.Close()
 
-	defer db.Close()
+		log.Info("connected to database")
 
-	return
-}
+		if cfg.Features.MigrateOnStart {
+			err = migrations.Up(db, func(line string) { log.Info(line) })
+			if err != nil {
+				log.Error("can't migrate database", "error", err)
+				return 1
+			}
+		}
 
+		if cfg.Features.Metrics {
+			prometheus.MustRegister(newDBStatsCollector(db))
+		}
+
+		st = store.NewPostgres(db)
+	}
+
+	if cfg.Storage == config.StoragePostgres && cfg.Features.CountersReconcileInterval > 0 {
+		jobs, stopJobs := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			reconcileCounters(jobs, st.Service, cfg.Features.CountersReconcileInterval, log)
+		}()
+
+		// Выполняется до закрытия пула: останавливаем пересчет и ждем его
+		defer func() {
+			stopJobs()
+			<-done
+		}()
+	}
+
+	secret := []byte(cfg.Auth.Secret)
+	if len(secret) == 0 {
+		log.Warn("auth.secret is not set, tokens will be invalid after restart")
+		secret = auth.RandomSecret()
+	}
+
+	h := handlers.New(st, handlers.Options{
+		StatusMode: cfg.Features.StatusMode,
+		Log:        log,
+		Auth: handlers.AuthOptions{
+			Enforce:      cfg.Auth.Enforce,
+			Signer:       auth.NewSigner(secret),
+			SessionTTL:   cfg.Auth.SessionTTL,
+			TokenTTL:     cfg.Auth.TokenTTL,
+			CookieSecure: cfg.Auth.CookieSecure,
+			Admins:       cfg.Auth.Admins,
+		},
+	})
+
+	mainMux := http.NewServeMux()
+	router := mux.NewRouter()
+
+	// The Handler function provides a default handler to expose metrics
+	// via an HTTP server. "/metrics" is the usual endpoint for that.
+	if cfg.Features.Metrics {
+		mainMux.Handle("/metrics", promhttp.Handler())
+	}
+
+	// Пробы не проходят через access log, чтобы не засорять его
+	mainMux.HandleFunc("/healthz", h.Healthz)
+	mainMux.HandleFunc("/readyz", h.Readyz)
+
+	// Таймаут из конфига ограничивает и обработчик, и его запросы к базе
+	handle := func(path, endpoint string, fn http.HandlerFunc) {
+		router.HandleFunc(path, handlers.WithTimeout(cfg.Timeouts.For(endpoint), fn))
+	}
+
+	router.Use(h.Authenticate)
+
+	handle(`/api/auth/login`, "auth_login", h.AuthLogin)
+	handle(`/api/auth/logout`, "auth_logout", h.AuthLogout)
+	handle(`/api/auth/token`, "auth_token", h.AuthToken)
+
+	handle(`/api/categories`, "categories", h.Categories)
+	handle(`/api/category/create`, "category_create", h.CategoryCreate)
+	handle(`/api/category/{slug}/details`, "category_details", h.CategoryDetails)
+
+	handle("/api/forum/create", "forum_create", h.ForumCreate)
+	handle(`/api/forum/{slug}/create`, "thread_create", h.ThreadCreate)
+	handle(`/api/forum/{slug}/details`, "forum_details", h.ForumDetails) // +
+	handle(`/api/forum/{slug}/threads`, "forum_threads", h.ForumThreads) // - не оч
+	handle(`/api/forum/{slug}/users`, "forum_users", h.ForumUsers) // +
+	handle(`/api/forum/{slug}/moderators`, "forum_moderators", h.ForumModerators)
+	handle(`/api/forum/{slug}/moderators/{nickname}`, "forum_moderators", h.ForumModerator)
+	handle(`/api/forum/{slug}/bans`, "forum_bans", h.ForumBans)
+	handle(`/api/forum/{slug}/bans/{nickname}`, "forum_bans", h.ForumBan)
+	handle(`/api/forums`, "forums", h.Forums)
+
+	handle(`/api/post/{id}/details`, "post_details", h.PostDetails) // +
+	handle(`/api/post/{id}/history`, "post_history", h.PostHistory)
+	handle(`/api/post/{id}/restore`, "post_restore", h.PostRestore)
+
+	handle(`/api/search`, "search", h.Search)
+
+	handle(`/api/service/clear`, "service_clear", h.ServiceClear)
+	handle(`/api/service/status`, "service_status", h.ServiceStatus) // -
+
+	handle(`/api/thread/{slug_or_id}/create`, "post_create", h.PostCreate)
+	handle(`/api/thread/{slug_or_id}/details`, "thread_details", h.ThreadDetails) // +
+	handle(`/api/thread/{slug_or_id}/history`, "thread_history", h.ThreadHistory)
+	handle(`/api/thread/{slug_or_id}/posts`, "thread_posts", h.ThreadPosts) // +
+	handle(`/api/thread/{slug_or_id}/restore`, "thread_restore", h.ThreadRestore)
+	handle(`/api/thread/{slug_or_id}/vote`, "thread_vote", h.ThreadVote)
+
+	handle(`/api/user/{nickname}/create`, "user_create", h.UserCreate)
+	handle(`/api/user/{nickname}/posts`, "user_posts", h.UserPosts)
+	handle(`/api/user/{nickname}/profile`, "user_profile", h.UserProfile)  // + быстро
+	handle(`/api/user/{nickname}/threads`, "user_threads", h.UserThreads)
+
+	siteHandler := AccessLogMiddleware(router, cfg.Features, log, tracer)
+
+	mainMux.Handle("/", siteHandler)
+
+	server := &http.Server{
+		Addr:         cfg.Listen,
+		Handler:      mainMux,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
+		log.Error("server failed", "error", err)
+		return 1
+	case sig := <-stop:
+		log.Info("stop accepting connections", "signal", sig, "wait", cfg.Server.ShutdownTimeout)
+	}
+
+	h.Drain()
+	time.Sleep(cfg.Server.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Shutdown закрывает слушающий сокет и ждет завершения текущих запросов,
+	// транзакции с пачками постов успевают закоммититься до закрытия пула
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error("graceful shutdown failed", "error", err)
+		server.Close()
+		return 1
+	}
+
+	log.Info("server stopped")
+
+	return 0
+}
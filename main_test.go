@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/logging"
+	"github.com/Grisha23/ForumsApi/store"
+)
+
+// driftService answers Reconcile with the next of results and stops the job after the last one.
+type driftService struct {
+	store.ServiceStore
+	results []error
+	drift   map[string]int64
+	calls   int
+	stop    context.CancelFunc
+}
+
+func (s *driftService) Reconcile(ctx context.Context) (map[string]int64, error) {
+	err := s.results[s.calls]
+	s.calls++
+	if s.calls == len(s.results) {
+		s.stop()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.drift, nil
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestReconcileCounters(t *testing.T) {
+	var logged syncBuffer
+	log, err := logging.New(&logged, logging.LevelInfo, logging.FormatLogfmt, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	service := &driftService{
+		results: []error{errors.New("deadlock detected"), nil, nil},
+		drift:   map[string]int64{"posts": -3},
+		stop:    cancel,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		reconcileCounters(ctx, service, time.Millisecond, log)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job doesn't stop with its context")
+	}
+
+	if service.calls != len(service.results) {
+		t.Errorf("reconciled %d times", service.calls)
+	}
+
+	out := logged.String()
+	if !strings.Contains(out, "can't reconcile status counters") || !strings.Contains(out, "deadlock detected") {
+		t.Errorf("the error isn't logged: %s", out)
+	}
+	// Последний вызов завершился вместе с контекстом, его поправка тоже в логе
+	if n := strings.Count(out, "status counter drifted"); n != 2 || !strings.Contains(out, "counter=posts delta=-3") {
+		t.Errorf("drift is logged %d times: %s", n, out)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Метки берутся из шаблона маршрута, а не из пути, иначе на каждый nickname и slug
+// заводилась бы отдельная серия.
+var (
+	requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ForumsApi",
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of handled requests by route template, method and status code.",
+		},
+		[]string{"route", "method", "code"},
+	)
+
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ForumsApi",
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Request latency by route template and method.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+		[]string{"route", "method"},
+	)
+
+	requestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "ForumsApi",
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "Number of requests being served right now.",
+		},
+	)
+
+	requestSize = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Namespace:  "ForumsApi",
+			Subsystem:  "http",
+			Name:       "request_size_bytes",
+			Help:       "Request body size by route template.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"route"},
+	)
+
+	responseSize = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Namespace:  "ForumsApi",
+			Subsystem:  "http",
+			Name:       "response_size_bytes",
+			Help:       "Response body size by route template.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"route"},
+	)
+)
+
+func init() {
+	// Metrics have to be registered to be exposed:
+	prometheus.MustRegister(requestsTotal, requestDuration, requestsInFlight, requestSize, responseSize)
+}
+
+func observeRequest(r *http.Request, route string, rec *statusRecorder, latency time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+
+	method := methodLabel(r.Method)
+
+	requestsTotal.WithLabelValues(route, method, strconv.Itoa(rec.status)).Inc()
+	requestDuration.WithLabelValues(route, method).Observe(latency.Seconds())
+
+	if r.ContentLength >= 0 {
+		requestSize.WithLabelValues(route).Observe(float64(r.ContentLength))
+	}
+	responseSize.WithLabelValues(route).Observe(float64(rec.size))
+}
+
+// methodLabel keeps the method label bounded: a client can send any method name.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "other"
+}
+
+// dbStatsCollector exports sql.DB pool statistics, they are read on every scrape.
+type dbStatsCollector struct {
+	db *sql.DB
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+func newDBStatsCollector(db *sql.DB) *dbStatsCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("ForumsApi", "db_pool", name), help, nil, nil)
+	}
+
+	return &dbStatsCollector{
+		db:                db,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections (0 - unlimited)."),
+		open:              desc("open_connections", "Number of established connections, in use and idle."),
+		inUse:             desc("in_use_connections", "Number of connections in use."),
+		idle:              desc("idle_connections", "Number of idle connections."),
+		waitCount:         desc("wait_total", "Number of times a query waited for a free connection."),
+		waitDuration:      desc("wait_seconds_total", "Total time spent waiting for a free connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "Connections closed because of db.max_idle_conns."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Connections closed because of db.conn_max_lifetime."),
+	}
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxLifetimeClosed
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
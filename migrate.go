@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Grisha23/ForumsApi/config"
+	"github.com/Grisha23/ForumsApi/migrations"
+	"github.com/Grisha23/ForumsApi/store"
+)
+
+const migrateUsage = `usage: ForumsApi migrate up|down [N]|status [config flags]
+
+  up      apply all pending migrations
+  down    roll back the last N applied migrations (1 by default)
+  status  list migrations and whether they are applied`
+
+// runMigrate implements the "migrate" subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	action := args[0]
+	args = args[1:]
+
+	steps := 1
+	if action == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			steps = n
+			args = args[1:]
+		}
+	}
+
+	cfg, err := config.Load(os.Args[0]+" migrate "+action, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if cfg.Storage != config.StoragePostgres {
+		fmt.Fprintln(os.Stderr, "migrations are only needed for postgres storage")
+		return 2
+	}
+
+	db, err := store.OpenPostgres(cfg.DB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can't connect to database:", err)
+		return 1
+	}
+
+	defer db.Close()
+
+	logLine := func(line string) {
+		fmt.Println(line)
+	}
+
+	switch action {
+	case "up":
+		err = migrations.Up(db, logLine)
+	case "down":
+		err = migrations.Down(db, steps, logLine)
+	case "status":
+		var states []migrations.State
+		states, err = migrations.Status(db)
+
+		for _, s := range states {
+			switch {
+			case s.Unknown:
+				fmt.Printf("%04d %-24s applied %s (unknown to this build)\n", s.Version, "?", s.AppliedAt.Format("2006-01-02 15:04:05"))
+			case s.Applied:
+				fmt.Printf("%04d %-24s applied %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			default:
+				fmt.Printf("%04d %-24s pending\n", s.Version, s.Name)
+			}
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -1,19 +1,14 @@
-DROP TABLE IF EXISTS votes CASCADE;
-DROP TABLE IF EXISTS users CASCADE;
-DROP TABLE IF EXISTS forums CASCADE;
-DROP TABLE IF EXISTS posts CASCADE;
-DROP TABLE IF EXISTS threads CASCADE;
-DROP TABLE IF EXISTS forum_users CASCADE;
------------------------------------------------
+package migrations
+
+// Схема из бывшего forum.sql. Все объекты создаются идемпотентно, чтобы миграцию
+// можно было применить к базе, развернутой из forum.sql, без потери данных.
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "init",
+		Up: `
 CREATE EXTENSION IF NOT EXISTS citext;
------------------------------------------------
------------------------------------------------
-SET LOCAL synchronous_commit TO OFF;
------------------------------------------------
------------------------------------------------
------------------------------------------------
------------------------------------------------
------------------------------------------------
+
 -------------------- USERS --------------------
 CREATE TABLE IF NOT EXISTS users (
 	about CITEXT,
@@ -24,10 +19,6 @@ CREATE TABLE IF NOT EXISTS users (
 
 CREATE INDEX IF NOT EXISTS user_nickname ON users (nickname);
 
------------------------------------------------
-
-
-
 -------------------- FORUMS -------------------
 CREATE TABLE IF NOT EXISTS forums (
 	posts BIGINT DEFAULT 0,
@@ -39,10 +30,6 @@ CREATE TABLE IF NOT EXISTS forums (
 
 CREATE INDEX IF NOT EXISTS forum_slug ON forums (slug);
 
------------------------------------------------
-
-
-
 -------------------- THREADS ------------------
 CREATE TABLE IF NOT EXISTS threads (
 	id SERIAL PRIMARY KEY,
@@ -63,19 +50,15 @@ CREATE OR REPLACE FUNCTION thread_create() RETURNS TRIGGER AS '
 '
 LANGUAGE plpgsql;
 
-
+DROP TRIGGER IF EXISTS thread_create ON threads;
 CREATE TRIGGER thread_create
 BEFORE INSERT ON threads FOR EACH ROW
 EXECUTE PROCEDURE thread_create();
 
-
 CREATE INDEX IF NOT EXISTS thtead_id ON threads (id);
 CREATE INDEX IF NOT EXISTS thread_slug ON threads (slug);
 CREATE INDEX IF NOT EXISTS thread_frm_cr ON threads (forum,created);
 CREATE INDEX IF NOT EXISTS thread_frm_athr ON threads (forum,author);
------------------------------------------------
-
-
 
 -------------------- POSTS --------------------
 CREATE TABLE IF NOT EXISTS posts (
@@ -90,7 +73,6 @@ CREATE TABLE IF NOT EXISTS posts (
 	id_array BIGINT ARRAY DEFAULT '{}'
 );
 
-
 CREATE OR REPLACE FUNCTION check_message() RETURNS TRIGGER AS '
   BEGIN
     NEW.isedited:=false;
@@ -99,7 +81,6 @@ CREATE OR REPLACE FUNCTION check_message() RETURNS TRIGGER AS '
 '
 LANGUAGE plpgsql;
 
-
 CREATE OR REPLACE FUNCTION post_create() RETURNS TRIGGER AS '
   BEGIN
     IF NEW.parent<>0 AND NOT EXISTS (SELECT id FROM posts WHERE id=NEW.parent AND thread=NEW.thread) THEN
@@ -112,59 +93,22 @@ CREATE OR REPLACE FUNCTION post_create() RETURNS TRIGGER AS '
 '
 LANGUAGE plpgsql;
 
-
--- CREATE OR REPLACE FUNCTION post_create() RETURNS TRIGGER AS'
---   BEGIN
---     IF NEW.parent<>0 AND NOT EXISTS (SELECT id FROM posts WHERE id=NEW.parent AND thread=NEW.thread) THEN
---       RAISE ''Parent post exc'';
---     END IF;
---     RETURN NEW;
---   END;
--- '
--- LANGUAGE plpgsql;
---
---
--- CREATE OR REPLACE FUNCTION post_create_after() RETURNS TRIGGER AS'
---   BEGIN
---     INSERT INTO forum_users(forum,author) VALUES (NEW.forum, NEW.author) ON CONFLICT DO NOTHING;
---     UPDATE forums SET posts=posts+1 WHERE slug=NEW.forum;
---     RETURN NEW;
---   END;
--- '
--- LANGUAGE plpgsql;
---
--- CREATE OR REPLACE FUNCTION post_create_after() RETURNS TRIGGER AS'
---   BEGIN
---    NEW.id_array=array_append((SELECT id_array FROM posts WHERE id=NEW.parent), NEW.id);
---    RETURN NEW;
---   END;
--- '
--- LANGUAGE plpgsql;
-
-
+DROP TRIGGER IF EXISTS change_message ON posts;
 CREATE TRIGGER change_message
 BEFORE UPDATE ON posts FOR EACH ROW WHEN (new.message=old.message)
 EXECUTE PROCEDURE check_message();
 
+DROP TRIGGER IF EXISTS post_create ON posts;
 CREATE TRIGGER post_create
 BEFORE INSERT ON posts FOR EACH ROW
 EXECUTE PROCEDURE post_create();
---
--- CREATE TRIGGER post_create_after
--- BEFORE INSERT ON posts FOR EACH ROW
--- EXECUTE PROCEDURE post_create_after();
-
-CREATE INDEX IF NOT EXISTS post_i_cr ON posts (id, created); --+
-CREATE INDEX IF NOT EXISTS post_thr_i_cr ON posts (thread, id, created); --+
---CREATE INDEX IF NOT EXISTS post_frm_athr_cr ON posts (forum, author, created);
-CREATE INDEX IF NOT EXISTS post_prnt_thr ON posts (parent, thread); -- +
-CREATE INDEX IF NOT EXISTS post_id_array ON posts (thread, (id_array[0]), id_array); -- +
------------------------------------------------
-
 
+CREATE INDEX IF NOT EXISTS post_i_cr ON posts (id, created);
+CREATE INDEX IF NOT EXISTS post_thr_i_cr ON posts (thread, id, created);
+CREATE INDEX IF NOT EXISTS post_prnt_thr ON posts (parent, thread);
+CREATE INDEX IF NOT EXISTS post_id_array ON posts (thread, (id_array[0]), id_array);
 
 -------------------- VOTES --------------------
-
 CREATE TABLE IF NOT EXISTS votes (
 	nickname CITEXT COLLATE "ucs_basic" NOT NULL REFERENCES users (nickname),
 	voice INTEGER NOT NULL,
@@ -172,8 +116,7 @@ CREATE TABLE IF NOT EXISTS votes (
 	UNIQUE (nickname, thread)
 );
 
-
-CREATE OR REPLACE FUNCTION vote_create() RETURNS TRIGGER AS'
+CREATE OR REPLACE FUNCTION vote_create() RETURNS TRIGGER AS '
   BEGIN
     UPDATE threads SET votes=votes+NEW.voice WHERE id=NEW.thread;
     RETURN NEW;
@@ -181,7 +124,7 @@ CREATE OR REPLACE FUNCTION vote_create() RETURNS TRIGGER AS'
 '
 LANGUAGE plpgsql;
 
-CREATE OR REPLACE FUNCTION vote_update() RETURNS TRIGGER AS'
+CREATE OR REPLACE FUNCTION vote_update() RETURNS TRIGGER AS '
   BEGIN
     IF (OLD.voice<>NEW.voice) THEN
       IF (NEW.voice=-1) THEN
@@ -195,25 +138,17 @@ CREATE OR REPLACE FUNCTION vote_update() RETURNS TRIGGER AS'
 '
 LANGUAGE plpgsql;
 
-
+DROP TRIGGER IF EXISTS vote_create ON votes;
 CREATE TRIGGER vote_create
 AFTER INSERT ON votes FOR EACH ROW
 EXECUTE PROCEDURE vote_create();
--- --
+
+DROP TRIGGER IF EXISTS vote_update ON votes;
 CREATE TRIGGER vote_update
 AFTER UPDATE ON votes FOR EACH ROW
 EXECUTE PROCEDURE vote_update();
 
-
-
---CREATE INDEX IF NOT EXISTS vote_nick_thr ON votes (nickname, thread); --+
-
------------------------------------------------
-
-
-
 ---------------- FORUM USERS ------------------
-
 CREATE TABLE IF NOT EXISTS forum_users (
   forum CITEXT REFERENCES forums(slug),
   author CITEXT REFERENCES users(nickname),
@@ -221,9 +156,10 @@ CREATE TABLE IF NOT EXISTS forum_users (
 );
 
 CREATE INDEX IF NOT EXISTS frm_users ON forum_users (forum, author);
------------------------------------------------
-
-
-
-
-
+`,
+		Down: `
+DROP TABLE IF EXISTS forum_users, votes, posts, threads, forums, users CASCADE;
+DROP FUNCTION IF EXISTS thread_create(), check_message(), post_create(), vote_create(), vote_update();
+`,
+	})
+}
@@ -0,0 +1,109 @@
+package migrations
+
+// Счетчики для /api/service/status. Каждый счетчик разбит на 16 строк по pg_backend_pid(),
+// чтобы параллельные вставки не ждали блокировку одной строки; значение - сумма по строкам.
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "service_counters",
+		Up: `
+CREATE TABLE IF NOT EXISTS service_counters (
+	name TEXT NOT NULL,
+	shard INTEGER NOT NULL,
+	value BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (name, shard)
+);
+
+CREATE OR REPLACE FUNCTION service_counter_add(counter TEXT, delta BIGINT) RETURNS VOID AS '
+  BEGIN
+    INSERT INTO service_counters(name, shard, value) VALUES (counter, pg_backend_pid() % 16, delta)
+    ON CONFLICT (name, shard) DO UPDATE SET value=service_counters.value+EXCLUDED.value;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION thread_create() RETURNS TRIGGER AS '
+  BEGIN
+    UPDATE forums SET threads=threads+1 WHERE slug=NEW.forum;
+    PERFORM service_counter_add(''threads'', 1);
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION post_create() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.parent<>0 AND NOT EXISTS (SELECT id FROM posts WHERE id=NEW.parent AND thread=NEW.thread) THEN
+      RAISE ''Parent post exc'';
+    END IF;
+    NEW.id_array=array_append((SELECT id_array FROM posts WHERE id=NEW.parent), NEW.id);
+    UPDATE forums SET posts=posts+1 WHERE slug=NEW.forum;
+    PERFORM service_counter_add(''posts'', 1);
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION user_create() RETURNS TRIGGER AS '
+  BEGIN
+    PERFORM service_counter_add(''users'', 1);
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION forum_create() RETURNS TRIGGER AS '
+  BEGIN
+    PERFORM service_counter_add(''forums'', 1);
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS user_create ON users;
+CREATE TRIGGER user_create
+AFTER INSERT ON users FOR EACH ROW
+EXECUTE PROCEDURE user_create();
+
+DROP TRIGGER IF EXISTS forum_create ON forums;
+CREATE TRIGGER forum_create
+AFTER INSERT ON forums FOR EACH ROW
+EXECUTE PROCEDURE forum_create();
+
+DELETE FROM service_counters;
+INSERT INTO service_counters(name, shard, value)
+SELECT 'users', 0, count(*) FROM users
+UNION ALL SELECT 'forums', 0, count(*) FROM forums
+UNION ALL SELECT 'threads', 0, count(*) FROM threads
+UNION ALL SELECT 'posts', 0, count(*) FROM posts;
+`,
+		Down: `
+DROP TRIGGER IF EXISTS user_create ON users;
+DROP TRIGGER IF EXISTS forum_create ON forums;
+DROP FUNCTION IF EXISTS user_create(), forum_create();
+
+CREATE OR REPLACE FUNCTION thread_create() RETURNS TRIGGER AS '
+  BEGIN
+    UPDATE forums SET threads=threads+1 WHERE slug=NEW.forum;
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION post_create() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.parent<>0 AND NOT EXISTS (SELECT id FROM posts WHERE id=NEW.parent AND thread=NEW.thread) THEN
+      RAISE ''Parent post exc'';
+    END IF;
+    NEW.id_array=array_append((SELECT id_array FROM posts WHERE id=NEW.parent), NEW.id);
+    UPDATE forums SET posts=posts+1 WHERE slug=NEW.forum;
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS service_counter_add(TEXT, BIGINT);
+DROP TABLE IF EXISTS service_counters;
+`,
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+// Пароли пользователей и сессии. В sessions хранится только sha256 от токена,
+// сам токен знает лишь клиент.
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "auth",
+		Up: `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash TEXT;
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id TEXT PRIMARY KEY,
+	nickname CITEXT COLLATE "ucs_basic" NOT NULL REFERENCES users (nickname),
+	kind TEXT NOT NULL,
+	created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp,
+	expires TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS session_nickname ON sessions (nickname);
+`,
+		Down: `
+DROP TABLE IF EXISTS sessions;
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
+`,
+	})
+}
@@ -0,0 +1,25 @@
+package migrations
+
+// Роли пользователей в форумах. Владелец - forums.author, администраторы задаются
+// в конфиге, остальные без записи здесь - обычные участники.
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "forum_roles",
+		Up: `
+CREATE TABLE IF NOT EXISTS forum_roles (
+	forum CITEXT NOT NULL REFERENCES forums (slug),
+	nickname CITEXT COLLATE "ucs_basic" NOT NULL REFERENCES users (nickname),
+	role TEXT NOT NULL CHECK (role IN ('moderator', 'banned')),
+	granted_by CITEXT COLLATE "ucs_basic" NOT NULL,
+	created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp,
+	PRIMARY KEY (forum, nickname)
+);
+
+CREATE INDEX IF NOT EXISTS forum_roles_role ON forum_roles (forum, role);
+`,
+		Down: `
+DROP TABLE IF EXISTS forum_roles;
+`,
+	})
+}
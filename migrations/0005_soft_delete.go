@@ -0,0 +1,73 @@
+package migrations
+
+// Мягкое удаление постов и веток. Строки остаются на месте, чтобы id_array потомков
+// по-прежнему указывал на существующие посты; счетчики форума и service_counters
+// учитывают только неудаленные записи.
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "soft_delete",
+		Up: `
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS isdeleted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS isdeleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- change_message срабатывал на любой UPDATE без изменения текста и сбрасывал isedited
+-- при удалении поста. Теперь только на UPDATE, в котором есть message.
+DROP TRIGGER IF EXISTS change_message ON posts;
+CREATE TRIGGER change_message
+BEFORE UPDATE OF message ON posts FOR EACH ROW WHEN (new.message=old.message)
+EXECUTE PROCEDURE check_message();
+
+CREATE OR REPLACE FUNCTION post_delete() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.isdeleted THEN
+      UPDATE forums SET posts=posts-1 WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''posts'', -1);
+    ELSE
+      UPDATE forums SET posts=posts+1 WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''posts'', 1);
+    END IF;
+    RETURN NULL;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION thread_delete() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.isdeleted THEN
+      UPDATE forums SET threads=threads-1 WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''threads'', -1);
+    ELSE
+      UPDATE forums SET threads=threads+1 WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''threads'', 1);
+    END IF;
+    RETURN NULL;
+  END;
+'
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS post_delete ON posts;
+CREATE TRIGGER post_delete
+AFTER UPDATE OF isdeleted ON posts FOR EACH ROW WHEN (OLD.isdeleted <> NEW.isdeleted)
+EXECUTE PROCEDURE post_delete();
+
+DROP TRIGGER IF EXISTS thread_delete ON threads;
+CREATE TRIGGER thread_delete
+AFTER UPDATE OF isdeleted ON threads FOR EACH ROW WHEN (OLD.isdeleted <> NEW.isdeleted)
+EXECUTE PROCEDURE thread_delete();
+`,
+		Down: `
+DROP TRIGGER IF EXISTS post_delete ON posts;
+DROP TRIGGER IF EXISTS thread_delete ON threads;
+DROP FUNCTION IF EXISTS post_delete(), thread_delete();
+
+DROP TRIGGER IF EXISTS change_message ON posts;
+CREATE TRIGGER change_message
+BEFORE UPDATE ON posts FOR EACH ROW WHEN (new.message=old.message)
+EXECUTE PROCEDURE check_message();
+
+ALTER TABLE posts DROP COLUMN IF EXISTS isdeleted;
+ALTER TABLE threads DROP COLUMN IF EXISTS isdeleted;
+`,
+	})
+}
@@ -0,0 +1,23 @@
+package migrations
+
+// История правок постов. Каждая запись хранит текст до правки, текущий текст
+// остается в posts.message.
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "post_revisions",
+		Up: `
+CREATE TABLE IF NOT EXISTS post_revisions (
+	post BIGINT NOT NULL REFERENCES posts (id),
+	revision INTEGER NOT NULL,
+	editor CITEXT COLLATE "ucs_basic",
+	edited TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp,
+	message TEXT NOT NULL,
+	PRIMARY KEY (post, revision)
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS post_revisions;
+`,
+	})
+}
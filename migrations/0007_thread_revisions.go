@@ -0,0 +1,29 @@
+package migrations
+
+// История правок веток, как post_revisions для постов: запись хранит заголовок и
+// описание до правки.
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "thread_revisions",
+		Up: `
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS isedited BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS edited TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS thread_revisions (
+	thread INTEGER NOT NULL REFERENCES threads (id),
+	revision INTEGER NOT NULL,
+	editor CITEXT COLLATE "ucs_basic",
+	edited TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp,
+	title TEXT NOT NULL,
+	message TEXT NOT NULL,
+	PRIMARY KEY (thread, revision)
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS thread_revisions;
+ALTER TABLE threads DROP COLUMN IF EXISTS edited;
+ALTER TABLE threads DROP COLUMN IF EXISTS isedited;
+`,
+	})
+}
@@ -0,0 +1,57 @@
+package migrations
+
+// Полнотекстовый поиск. Колонки search поддерживаются триггерами (в PostgreSQL 10 нет
+// генерируемых колонок), конфигурация simple не зависит от языка сообщений. В ветке
+// заголовок весит больше описания.
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "search",
+		Up: `
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search TSVECTOR;
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS search TSVECTOR;
+
+CREATE OR REPLACE FUNCTION post_search() RETURNS TRIGGER AS '
+  BEGIN
+    NEW.search:=to_tsvector(''simple'', NEW.message);
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION thread_search() RETURNS TRIGGER AS '
+  BEGIN
+    NEW.search:=setweight(to_tsvector(''simple'', coalesce(NEW.title, '''')), ''A'') ||
+      setweight(to_tsvector(''simple'', coalesce(NEW.message, '''')), ''B'');
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS post_search ON posts;
+CREATE TRIGGER post_search
+BEFORE INSERT OR UPDATE OF message ON posts FOR EACH ROW
+EXECUTE PROCEDURE post_search();
+
+DROP TRIGGER IF EXISTS thread_search ON threads;
+CREATE TRIGGER thread_search
+BEFORE INSERT OR UPDATE OF title, message ON threads FOR EACH ROW
+EXECUTE PROCEDURE thread_search();
+
+UPDATE posts SET search=to_tsvector('simple', message);
+UPDATE threads SET search=setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(message, '')), 'B');
+
+CREATE INDEX IF NOT EXISTS post_search_gin ON posts USING GIN (search);
+CREATE INDEX IF NOT EXISTS thread_search_gin ON threads USING GIN (search);
+`,
+		Down: `
+DROP TRIGGER IF EXISTS post_search ON posts;
+DROP TRIGGER IF EXISTS thread_search ON threads;
+DROP FUNCTION IF EXISTS post_search(), thread_search();
+DROP INDEX IF EXISTS post_search_gin, thread_search_gin;
+ALTER TABLE posts DROP COLUMN IF EXISTS search;
+ALTER TABLE threads DROP COLUMN IF EXISTS search;
+`,
+	})
+}
@@ -0,0 +1,17 @@
+package migrations
+
+// Индексы для ленты активности пользователя и его статистики.
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "user_activity",
+		Up: `
+CREATE INDEX IF NOT EXISTS post_athr_cr ON posts (author, created, id);
+CREATE INDEX IF NOT EXISTS thread_athr_cr ON threads (author, created, id);
+CREATE INDEX IF NOT EXISTS frm_users_athr ON forum_users (author);
+`,
+		Down: `
+DROP INDEX IF EXISTS post_athr_cr, thread_athr_cr, frm_users_athr;
+`,
+	})
+}
@@ -0,0 +1,98 @@
+package migrations
+
+// Последняя активность ветки: кол-во постов, время и автор последнего поста.
+// Ведутся триггерами post_create и post_delete, учитываются только неудаленные посты.
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "thread_activity",
+		Up: `
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS posts BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS last_post_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS last_post_author CITEXT COLLATE "ucs_basic";
+
+-- Посты одной пачки создаются с одним временем, последним считается пост с большим id
+CREATE OR REPLACE FUNCTION post_create() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.parent<>0 AND NOT EXISTS (SELECT id FROM posts WHERE id=NEW.parent AND thread=NEW.thread) THEN
+      RAISE ''Parent post exc'';
+    END IF;
+    NEW.id_array=array_append((SELECT id_array FROM posts WHERE id=NEW.parent), NEW.id);
+    UPDATE forums SET posts=posts+1 WHERE slug=NEW.forum;
+    UPDATE threads SET posts=posts+1,
+      last_post_author=CASE WHEN last_post_at IS NULL OR last_post_at<=NEW.created THEN NEW.author ELSE last_post_author END,
+      last_post_at=GREATEST(last_post_at, NEW.created)
+      WHERE id=NEW.thread;
+    PERFORM service_counter_add(''posts'', 1);
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+-- Удаление и восстановление редки, последний пост просто ищется заново
+CREATE OR REPLACE FUNCTION post_delete() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.isdeleted THEN
+      UPDATE forums SET posts=posts-1 WHERE slug=NEW.forum;
+      UPDATE threads SET posts=posts-1 WHERE id=NEW.thread;
+      PERFORM service_counter_add(''posts'', -1);
+    ELSE
+      UPDATE forums SET posts=posts+1 WHERE slug=NEW.forum;
+      UPDATE threads SET posts=posts+1 WHERE id=NEW.thread;
+      PERFORM service_counter_add(''posts'', 1);
+    END IF;
+    UPDATE threads SET (last_post_at, last_post_author)=(SELECT created, author FROM posts
+      WHERE thread=NEW.thread AND NOT isdeleted ORDER BY created DESC, id DESC LIMIT 1)
+      WHERE id=NEW.thread;
+    RETURN NULL;
+  END;
+'
+LANGUAGE plpgsql;
+
+UPDATE threads t SET posts=a.posts, last_post_at=a.created, last_post_author=a.author
+FROM (
+  SELECT DISTINCT ON (thread) thread, created, author, count(*) OVER (PARTITION BY thread) AS posts
+  FROM posts WHERE NOT isdeleted ORDER BY thread, created DESC, id DESC
+) a
+WHERE t.id=a.thread;
+
+CREATE INDEX IF NOT EXISTS thread_frm_activity ON threads (forum, (COALESCE(last_post_at, created)), id);
+CREATE INDEX IF NOT EXISTS thread_frm_posts ON threads (forum, posts, id);
+CREATE INDEX IF NOT EXISTS post_thr_cr ON posts (thread, created, id);
+`,
+		Down: `
+DROP INDEX IF EXISTS thread_frm_activity, thread_frm_posts, post_thr_cr;
+
+CREATE OR REPLACE FUNCTION post_create() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.parent<>0 AND NOT EXISTS (SELECT id FROM posts WHERE id=NEW.parent AND thread=NEW.thread) THEN
+      RAISE ''Parent post exc'';
+    END IF;
+    NEW.id_array=array_append((SELECT id_array FROM posts WHERE id=NEW.parent), NEW.id);
+    UPDATE forums SET posts=posts+1 WHERE slug=NEW.forum;
+    PERFORM service_counter_add(''posts'', 1);
+    RETURN NEW;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION post_delete() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.isdeleted THEN
+      UPDATE forums SET posts=posts-1 WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''posts'', -1);
+    ELSE
+      UPDATE forums SET posts=posts+1 WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''posts'', 1);
+    END IF;
+    RETURN NULL;
+  END;
+'
+LANGUAGE plpgsql;
+
+ALTER TABLE threads DROP COLUMN IF EXISTS posts;
+ALTER TABLE threads DROP COLUMN IF EXISTS last_post_at;
+ALTER TABLE threads DROP COLUMN IF EXISTS last_post_author;
+`,
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+// Категории форумов и вложенные форумы. Подфорум всегда в категории родителя;
+// суммарные счетчики по иерархии считаются при чтении.
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "categories",
+		Up: `
+CREATE TABLE IF NOT EXISTS categories (
+	slug CITEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	position INTEGER NOT NULL DEFAULT 0
+);
+
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS category CITEXT REFERENCES categories (slug);
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS parent CITEXT REFERENCES forums (slug);
+
+CREATE INDEX IF NOT EXISTS frm_category ON forums (category);
+CREATE INDEX IF NOT EXISTS frm_parent ON forums (parent);
+`,
+		Down: `
+DROP INDEX IF EXISTS frm_category, frm_parent;
+
+ALTER TABLE forums DROP COLUMN IF EXISTS parent;
+ALTER TABLE forums DROP COLUMN IF EXISTS category;
+
+DROP TABLE IF EXISTS categories;
+`,
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+// Время создания форума и индексы для списка форумов. У существующих форумов
+// время создания неизвестно, им ставится время миграции.
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "forum_listing",
+		Up: `
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp;
+
+CREATE INDEX IF NOT EXISTS frm_title ON forums (title, slug);
+CREATE INDEX IF NOT EXISTS frm_created ON forums (created, slug);
+CREATE INDEX IF NOT EXISTS frm_posts ON forums (posts, slug);
+CREATE INDEX IF NOT EXISTS frm_threads ON forums (threads, slug);
+CREATE INDEX IF NOT EXISTS frm_author ON forums (author);
+`,
+		Down: `
+DROP INDEX IF EXISTS frm_title, frm_created, frm_posts, frm_threads, frm_author;
+
+ALTER TABLE forums DROP COLUMN IF EXISTS created;
+`,
+	})
+}
@@ -0,0 +1,102 @@
+package migrations
+
+// Посты удаленной ветки не учитываются в счетчиках форума и service_counters:
+// thread_delete вычитает и возвращает ее неудаленные посты, post_delete в удаленной
+// ветке меняет только счетчик самой ветки.
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "thread_delete_posts",
+		Up: `
+CREATE OR REPLACE FUNCTION thread_delete() RETURNS TRIGGER AS '
+  DECLARE
+    live BIGINT := (SELECT count(*) FROM posts WHERE thread=NEW.id AND NOT isdeleted);
+  BEGIN
+    IF NEW.isdeleted THEN
+      UPDATE forums SET threads=threads-1, posts=posts-live WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''threads'', -1);
+      PERFORM service_counter_add(''posts'', -live);
+    ELSE
+      UPDATE forums SET threads=threads+1, posts=posts+live WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''threads'', 1);
+      PERFORM service_counter_add(''posts'', live);
+    END IF;
+    RETURN NULL;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION post_delete() RETURNS TRIGGER AS '
+  DECLARE
+    delta INTEGER := CASE WHEN NEW.isdeleted THEN -1 ELSE 1 END;
+  BEGIN
+    UPDATE threads SET posts=posts+delta WHERE id=NEW.thread;
+    IF NOT (SELECT isdeleted FROM threads WHERE id=NEW.thread) THEN
+      UPDATE forums SET posts=posts+delta WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''posts'', delta);
+    END IF;
+    UPDATE threads SET (last_post_at, last_post_author)=(SELECT created, author FROM posts
+      WHERE thread=NEW.thread AND NOT isdeleted ORDER BY created DESC, id DESC LIMIT 1)
+      WHERE id=NEW.thread;
+    RETURN NULL;
+  END;
+'
+LANGUAGE plpgsql;
+
+-- Посты уже удаленных веток
+UPDATE forums f SET posts=f.posts-c.live
+FROM (
+  SELECT p.forum, count(*) AS live FROM posts p JOIN threads t ON t.id=p.thread
+  WHERE t.isdeleted AND NOT p.isdeleted GROUP BY p.forum
+) c
+WHERE f.slug=c.forum;
+
+SELECT service_counter_add('posts', -(SELECT count(*) FROM posts p JOIN threads t ON t.id=p.thread
+  WHERE t.isdeleted AND NOT p.isdeleted));
+`,
+		Down: `
+UPDATE forums f SET posts=f.posts+c.live
+FROM (
+  SELECT p.forum, count(*) AS live FROM posts p JOIN threads t ON t.id=p.thread
+  WHERE t.isdeleted AND NOT p.isdeleted GROUP BY p.forum
+) c
+WHERE f.slug=c.forum;
+
+SELECT service_counter_add('posts', (SELECT count(*) FROM posts p JOIN threads t ON t.id=p.thread
+  WHERE t.isdeleted AND NOT p.isdeleted));
+
+CREATE OR REPLACE FUNCTION post_delete() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.isdeleted THEN
+      UPDATE forums SET posts=posts-1 WHERE slug=NEW.forum;
+      UPDATE threads SET posts=posts-1 WHERE id=NEW.thread;
+      PERFORM service_counter_add(''posts'', -1);
+    ELSE
+      UPDATE forums SET posts=posts+1 WHERE slug=NEW.forum;
+      UPDATE threads SET posts=posts+1 WHERE id=NEW.thread;
+      PERFORM service_counter_add(''posts'', 1);
+    END IF;
+    UPDATE threads SET (last_post_at, last_post_author)=(SELECT created, author FROM posts
+      WHERE thread=NEW.thread AND NOT isdeleted ORDER BY created DESC, id DESC LIMIT 1)
+      WHERE id=NEW.thread;
+    RETURN NULL;
+  END;
+'
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION thread_delete() RETURNS TRIGGER AS '
+  BEGIN
+    IF NEW.isdeleted THEN
+      UPDATE forums SET threads=threads-1 WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''threads'', -1);
+    ELSE
+      UPDATE forums SET threads=threads+1 WHERE slug=NEW.forum;
+      PERFORM service_counter_add(''threads'', 1);
+    END IF;
+    RETURN NULL;
+  END;
+'
+LANGUAGE plpgsql;
+`,
+	})
+}
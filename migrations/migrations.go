@@ -0,0 +1,235 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// lockKey is the pg_advisory_lock key held while migrations run, so two instances
+// started at the same time don't apply the same migration twice.
+const lockKey = 7235116130
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type State struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Unknown   bool // Применена в базе, но отсутствует в этой сборке
+}
+
+var all []Migration
+
+// register is called from init of every migration file.
+func register(m Migration) {
+	for _, existing := range all {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("migrations: duplicate version %d", m.Version))
+		}
+	}
+
+	all = append(all, m)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+}
+
+// Latest returns the version of the newest known migration.
+func Latest() int {
+	if len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
+
+// Current returns the newest applied version, 0 if nothing was applied.
+func Current(ctx context.Context, db *sql.DB) (int, error) {
+	var version sql.NullInt64
+
+	err := db.QueryRowContext(ctx, "SELECT max(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		if pqUndefinedTable(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// Up applies all pending migrations in order, each one in its own transaction.
+func Up(db *sql.DB, log func(string)) error {
+	return locked(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range all {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			err := inTx(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(m.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec("INSERT INTO schema_migrations(version, name) VALUES ($1, $2)", m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %v", m.Version, m.Name, err)
+			}
+
+			log(fmt.Sprintf("applied %04d_%s", m.Version, m.Name))
+		}
+
+		return nil
+	})
+}
+
+// Down rolls back the last steps applied migrations.
+func Down(db *sql.DB, steps int, log func(string)) error {
+	return locked(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(all) - 1; i >= 0 && steps > 0; i-- {
+			m := all[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+
+			err := inTx(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(m.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec("DELETE FROM schema_migrations WHERE version=$1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("rollback %04d_%s: %v", m.Version, m.Name, err)
+			}
+
+			log(fmt.Sprintf("rolled back %04d_%s", m.Version, m.Name))
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Status lists known migrations and applied versions this build doesn't know about.
+// It only reads: no lock is taken, and without the schema_migrations table all
+// migrations are pending.
+func Status(db *sql.DB) ([]State, error) {
+	applied, err := appliedVersions(db)
+	if pqUndefinedTable(err) {
+		applied, err = make(map[int]time.Time), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var states []State
+
+	for _, m := range all {
+		at, ok := applied[m.Version]
+		states = append(states, State{Migration: m, Applied: ok, AppliedAt: at})
+		delete(applied, m.Version)
+	}
+
+	for version, at := range applied {
+		states = append(states, State{Migration: Migration{Version: version}, Applied: true, AppliedAt: at, Unknown: true})
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+
+	return states, nil
+}
+
+// locked runs fn on a single connection holding the migrations advisory lock.
+func locked(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return err
+	}
+
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations ("+
+		"version BIGINT PRIMARY KEY, "+
+		"name TEXT NOT NULL, "+
+		"applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp)")
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// querier is a *sql.DB or the *sql.Conn holding the lock.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func appliedVersions(conn querier) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+func inTx(conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func pqUndefinedTable(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code.Name() == "undefined_table"
+}
@@ -0,0 +1,407 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// fakeDB is the state of a database behind the fake driver: schema_migrations and
+// the statements run, each prefixed with the number of its connection.
+type fakeDB struct {
+	mu         sync.Mutex
+	conns      int
+	table      bool
+	applied    map[int64]time.Time
+	statements []string
+	failOn     string // Текст миграции, выполнение которой завершится ошибкой
+}
+
+var (
+	fakeMu  sync.Mutex
+	fakeDBs = make(map[string]*fakeDB)
+)
+
+type fakeDriver struct{}
+
+func init() {
+	sql.Register("fakepg", fakeDriver{})
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeMu.Lock()
+	db := fakeDBs[name]
+	fakeMu.Unlock()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.conns++
+	return &fakeConn{db: db, id: db.conns}, nil
+}
+
+func openFake(t *testing.T) (*sql.DB, *fakeDB) {
+	fake := &fakeDB{applied: make(map[int64]time.Time)}
+
+	fakeMu.Lock()
+	fakeDBs[t.Name()] = fake
+	fakeMu.Unlock()
+
+	db, err := sql.Open("fakepg", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db, fake
+}
+
+// log returns the statements run since the previous call.
+func (db *fakeDB) log() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res := db.statements
+	db.statements = nil
+	return res
+}
+
+type fakeConn struct {
+	db *fakeDB
+	id int
+}
+
+func (c *fakeConn) record(query string) {
+	c.db.statements = append(c.db.statements, fmt.Sprintf("%d: %s", c.id, query))
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare isn't supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.record("BEGIN")
+	return fakeTx{c}, nil
+}
+
+// Откат не восстанавливает таблицу: тесты с ошибкой проверяют только журнал
+type fakeTx struct {
+	c *fakeConn
+}
+
+func (tx fakeTx) Commit() error {
+	tx.c.db.mu.Lock()
+	defer tx.c.db.mu.Unlock()
+
+	tx.c.record("COMMIT")
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.c.db.mu.Lock()
+	defer tx.c.db.mu.Unlock()
+
+	tx.c.record("ROLLBACK")
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.record(query)
+
+	switch {
+	case c.db.failOn != "" && query == c.db.failOn:
+		return nil, fmt.Errorf("syntax error")
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		c.db.table = true
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		c.db.applied[args[0].Value.(int64)] = time.Now()
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		delete(c.db.applied, args[0].Value.(int64))
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.record(query)
+
+	if strings.Contains(query, "schema_migrations") && !c.db.table {
+		return nil, &pq.Error{Code: "42P01", Message: `relation "schema_migrations" does not exist`}
+	}
+
+	rows := &fakeRows{}
+
+	switch query {
+	case "SELECT version, applied_at FROM schema_migrations":
+		rows.columns = []string{"version", "applied_at"}
+		for version, at := range c.db.applied {
+			rows.values = append(rows.values, []driver.Value{version, at})
+		}
+	case "SELECT max(version) FROM schema_migrations":
+		rows.columns = []string{"max"}
+		var max driver.Value
+		for version := range c.db.applied {
+			if max == nil || version > max.(int64) {
+				max = version
+			}
+		}
+		rows.values = [][]driver.Value{{max}}
+	default:
+		return nil, fmt.Errorf("unexpected query %s", query)
+	}
+
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+const (
+	lockStatement   = "SELECT pg_advisory_lock($1)"
+	unlockStatement = "SELECT pg_advisory_unlock($1)"
+)
+
+// checkLocked fails unless log is the work of a single connection between the lock and the unlock.
+func checkLocked(t *testing.T, log []string) {
+	t.Helper()
+
+	if len(log) < 2 {
+		t.Fatalf("log: %q", log)
+	}
+
+	conn := log[0][:strings.Index(log[0], ":")+2]
+
+	if log[0] != conn+lockStatement || log[len(log)-1] != conn+unlockStatement {
+		t.Errorf("not between lock and unlock: %q", log)
+	}
+	for _, s := range log {
+		if !strings.HasPrefix(s, conn) {
+			t.Errorf("%q isn't on the locked connection %s", s, conn)
+		}
+	}
+	for _, s := range log[1 : len(log)-1] {
+		if strings.Contains(s, "pg_advisory") {
+			t.Errorf("lock statement in the middle: %q", log)
+		}
+	}
+}
+
+func statementsOf(log []string) []string {
+	res := make([]string, len(log))
+	for i, s := range log {
+		res[i] = s[strings.Index(s, ": ")+2:]
+	}
+	return res
+}
+
+func TestUpInOrder(t *testing.T) {
+	db, fake := openFake(t)
+	ctx := context.Background()
+
+	if version, err := Current(ctx, db); err != nil || version != 0 {
+		t.Fatalf("empty database: got %d, %v", version, err)
+	}
+	fake.log()
+
+	var lines []string
+	if err := Up(db, func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatal(err)
+	}
+
+	log := fake.log()
+	checkLocked(t, log)
+
+	// Каждая миграция в своей транзакции вместе с записью о ней, по возрастанию версий
+	want := []string{lockStatement, "", "SELECT version, applied_at FROM schema_migrations"}
+	for _, m := range all {
+		want = append(want, "BEGIN", m.Up, "INSERT INTO schema_migrations(version, name) VALUES ($1, $2)", "COMMIT")
+	}
+	want = append(want, unlockStatement)
+
+	got := statementsOf(log)
+	if len(got) != len(want) {
+		t.Fatalf("got %d statements, want %d: %q", len(got), len(want), got)
+	}
+	for i := range want {
+		if i == 1 {
+			if !strings.HasPrefix(got[i], "CREATE TABLE IF NOT EXISTS schema_migrations") {
+				t.Errorf("statement 1: got %q", got[i])
+			}
+			continue
+		}
+		if got[i] != want[i] {
+			t.Errorf("statement %d:\n got %.80q\nwant %.80q", i, got[i], want[i])
+		}
+	}
+
+	if len(lines) != len(all) || lines[0] != fmt.Sprintf("applied %04d_%s", all[0].Version, all[0].Name) {
+		t.Errorf("log lines: %q", lines)
+	}
+
+	if version, err := Current(ctx, db); err != nil || version != Latest() {
+		t.Errorf("current: got %d, %v, want %d", version, err, Latest())
+	}
+}
+
+func TestRegisteredInOrder(t *testing.T) {
+	if len(all) == 0 {
+		t.Fatal("no migrations")
+	}
+	for i := range all {
+		if all[i].Version != i+1 || all[i].Name == "" || all[i].Up == "" || all[i].Down == "" {
+			t.Errorf("migration %d: %d %q", i, all[i].Version, all[i].Name)
+		}
+	}
+}
+
+func TestUpAgain(t *testing.T) {
+	db, fake := openFake(t)
+
+	if err := Up(db, func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+	fake.log()
+
+	var lines []string
+	if err := Up(db, func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatal(err)
+	}
+
+	log := fake.log()
+	checkLocked(t, log)
+
+	if len(log) != 4 || len(lines) != 0 {
+		t.Errorf("second run isn't empty: %q %q", log, lines)
+	}
+}
+
+func TestUpStopsOnError(t *testing.T) {
+	db, fake := openFake(t)
+	fake.failOn = all[1].Up
+
+	err := Up(db, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("migration %04d_%s", all[1].Version, all[1].Name)) {
+		t.Fatalf("got %v", err)
+	}
+
+	log := fake.log()
+	checkLocked(t, log)
+
+	got := statementsOf(log)
+	if got[len(got)-2] != "ROLLBACK" {
+		t.Errorf("failed migration isn't rolled back: %q", got[len(got)-3:])
+	}
+	if len(fake.applied) != 1 {
+		t.Errorf("applied %v", fake.applied)
+	}
+}
+
+func TestDown(t *testing.T) {
+	db, fake := openFake(t)
+
+	if err := Up(db, func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+	fake.log()
+
+	if err := Down(db, 2, func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := fake.log()
+	checkLocked(t, log)
+
+	got := statementsOf(log)
+	last, prev := all[len(all)-1], all[len(all)-2]
+
+	if got[4] != last.Down || got[8] != prev.Down {
+		t.Errorf("rollback order: %.60q", got)
+	}
+	if version, err := Current(context.Background(), db); err != nil || version != prev.Version-1 {
+		t.Errorf("current: got %d, %v", version, err)
+	}
+}
+
+func TestStatusReadOnly(t *testing.T) {
+	db, fake := openFake(t)
+
+	states, err := Status(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != len(all) {
+		t.Fatalf("got %d states", len(states))
+	}
+	for i, s := range states {
+		if s.Applied || s.Unknown || s.Version != all[i].Version {
+			t.Errorf("without the table: %+v", s)
+		}
+	}
+
+	if log := statementsOf(fake.log()); len(log) != 1 || log[0] != "SELECT version, applied_at FROM schema_migrations" {
+		t.Errorf("status isn't read-only: %q", log)
+	}
+	if fake.table {
+		t.Errorf("status created schema_migrations")
+	}
+
+	if err := Up(db, func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+	fake.applied[int64(Latest()+5)] = time.Now()
+	fake.log()
+
+	states, err = Status(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != len(all)+1 {
+		t.Fatalf("got %d states", len(states))
+	}
+	for _, s := range states[:len(all)] {
+		if !s.Applied || s.Unknown {
+			t.Errorf("after up: %+v", s)
+		}
+	}
+	if s := states[len(all)]; !s.Unknown || s.Version != Latest()+5 {
+		t.Errorf("unknown version: %+v", s)
+	}
+
+	if log := statementsOf(fake.log()); len(log) != 1 {
+		t.Errorf("status isn't read-only: %q", log)
+	}
+}
@@ -12,6 +12,57 @@ type Forum struct {
 	Threads int32 			`json:"threads"`		// Кол-во веток в данном форуме
 	Title string  			`json:"title"`			// Название форума
 	User string   			`json:"user"`			// Nickname создателя
+	Created time.Time 		`json:"created"`		// Время создания форума.
+	Category string 		`json:"category,omitempty"`	// Категория, у подфорума - категория родителя.
+	Parent string 			`json:"parent,omitempty"`	// Родительский форум, пустой у корневого.
+	Totals *ForumTotals 	`json:"totals,omitempty"`	// Счетчики вместе со всеми подфорумами, только в деталях и дереве.
+	Breadcrumbs []Crumb 	`json:"breadcrumbs,omitempty"`	// Путь от категории до родителя, только в деталях.
+	Children []Forum 		`json:"children,omitempty"`	// Подфорумы, только в деталях и дереве.
+}
+
+type ForumTotals struct {
+	Posts int64 			`json:"posts"`			// Кол-во сообщений в форуме и его подфорумах.
+	Threads int64 			`json:"threads"`		// Кол-во веток в форуме и его подфорумах.
+}
+
+type Crumb struct {
+	Kind string 			`json:"kind"`			// category или forum.
+	Slug string 			`json:"slug"`
+	Title string 			`json:"title"`
+}
+
+type Category struct {
+	Slug string 			`json:"slug"`			// Человекопонятный URL категории.
+	Title string 			`json:"title"`			// Название категории.
+	Description string 		`json:"description"`	// Описание категории.
+	Position int32 			`json:"position"`		// Порядок категорий на главной, по возрастанию.
+	Totals *ForumTotals 	`json:"totals,omitempty"`	// Счетчики всех форумов категории, только в дереве.
+	Forums []Forum 			`json:"forums,omitempty"`	// Корневые форумы категории, только в дереве.
+}
+
+type CategoryUpdate struct {
+	Title string 			`json:"title"`			// Пустое - не менять.
+	Description string 		`json:"description"`	// Пустое - не менять.
+	Position *int32 		`json:"position"`		// Отсутствует - не менять.
+}
+
+type ForumIndex struct {
+	Categories []Category 	`json:"categories"`		// Категории по position с деревьями форумов.
+	Forums []Forum 			`json:"forums"`			// Корневые форумы без категории.
+}
+
+type ForumRole struct {
+	Nickname string 		`json:"nickname"`		// Пользователь, получивший роль.
+	Role string 			`json:"role"`			// moderator или banned.
+	GrantedBy string 		`json:"granted_by"`		// Кто выдал роль.
+	Created time.Time 		`json:"created"`		// Когда роль выдана.
+}
+
+type Page struct {
+	Items interface{} 		`json:"items"`			// Элементы страницы в порядке списка.
+	NextCursor string 		`json:"next_cursor,omitempty"`	// Курсор следующей страницы.
+	PrevCursor string 		`json:"prev_cursor,omitempty"`	// Курсор предыдущей страницы.
+	HasMore bool 			`json:"has_more"`		// Есть ли элементы после этой страницы.
 }
 
 type Post struct {
@@ -20,11 +71,42 @@ type Post struct {
 	Forum string 			`json:"forum"`			// Идентификатор форума
 	Id int64 				`json:"id"`				// Идентификатор данного сообщения
 	IsEdited bool 			`json:"isEdited"`		// Истина, если данное сообщение было изменено.
+	IsDeleted bool 			`json:"isDeleted,omitempty"`	// Истина для удаленного сообщения, в дереве остается без автора и текста.
 	Message string 			`json:"message"`		// Собственно сообщение форума.
 	Parent int64 			`json:"parent"`			// Идентификатор родительского сообщения (0 - корневое сообщение обсуждения).
 	Thread int32 			`json:"thread"`			// Идентификатор ветви (id) обсуждения данного сообещния.
 }
 
+type PostRevision struct {
+	Revision int32 			`json:"revision"`		// Номер правки, начиная с 1. Версия 0 - исходный текст.
+	Editor string 			`json:"editor,omitempty"`	// Кто правил, пусто для правок без авторизации.
+	Edited time.Time 		`json:"edited"`			// Время правки.
+	Previous string 		`json:"previous"`		// Текст до правки.
+	Message string 			`json:"message"`		// Текст после правки.
+}
+
+type RevisionDiff struct {
+	From int32 				`json:"from"`			// Версия, с которой сравниваем.
+	To int32 				`json:"to"`				// Версия, которую сравниваем с from.
+	Diff string 			`json:"diff"`			// Построчный diff в unified формате, пустой для одинаковых версий.
+}
+
+type SearchHit struct {
+	Kind string 			`json:"kind"`			// post или thread.
+	Id int64 				`json:"id"`				// Идентификатор поста или ветки.
+	Thread int32 			`json:"thread"`			// Ветка, в которой найден пост, для ветки - она сама.
+	Forum string 			`json:"forum"`			// Форум поста или ветки.
+	Author string 			`json:"author"`			// Автор поста или ветки.
+	Created time.Time 		`json:"created"`		// Дата создания.
+	Rank float32 			`json:"rank"`			// Релевантность, больше - лучше.
+	Snippet string 			`json:"snippet"`		// Фрагмент текста в HTML: текст экранирован, найденные слова выделены <b></b>.
+}
+
+type SearchResult struct {
+	Hits []SearchHit 		`json:"hits"`			// Результаты по убыванию релевантности.
+	Next string 			`json:"next,omitempty"`	// Курсор следующей страницы, пустой на последней.
+}
+
 type Status struct {
 	Forum int32 			`json:"forum"` 			// Кол-во разделов в базе данных.
 	Post int64 				`json:"post"`			// Кол-во сообщений в базе данных.
@@ -32,6 +114,11 @@ type Status struct {
 	User int32 				`json:"user"`			// Кол-во пользователей в базе данных.
 }
 
+type Health struct {
+	Status string 			`json:"status"`			// ok или unavailable
+	Checks map[string]string `json:"checks,omitempty"` // Результат каждой проверки
+}
+
 type Thread struct {
 	Author string   		`json:"author"`			// Пользователь, создавший данную тему.
 	Created time.Time 		`json:"created"` 		// Дата создания ветки на форуме.
@@ -41,6 +128,23 @@ type Thread struct {
 	Slug string				`json:"slug"`			// Человекопонятный URL. В данной структуре slug опционален и не может быть числом.
 	Title string 			`json:"title"`			// Заголовок ветки обсуждения.
 	Votes int32 			`json:"votes"`			// Кол-во голосов непосредственно за данное сообщение форума.
+	IsDeleted bool 			`json:"isDeleted,omitempty"`	// Истина для удаленной ветки, видна только при восстановлении.
+	IsEdited bool 			`json:"isEdited"`		// Истина, если заголовок или описание ветки изменялись.
+	Edited *time.Time 		`json:"edited,omitempty"`	// Время последней правки.
+	Posts int64 			`json:"posts"`			// Кол-во неудаленных постов в ветке.
+	LastPostAt *time.Time 	`json:"lastPostAt,omitempty"`	// Время последнего поста, нет у ветки без постов.
+	LastPostAuthor string 	`json:"lastPostAuthor,omitempty"`	// Автор последнего поста.
+}
+
+type ThreadRevision struct {
+	Revision int32 			`json:"revision"`		// Номер правки, начиная с 1. Версия 0 - исходная ветка.
+	Editor string 			`json:"editor,omitempty"`	// Кто правил, пусто для правок без авторизации.
+	Edited time.Time 		`json:"edited"`			// Время правки.
+	Changed []string 		`json:"changed"`		// Измененные поля: title, message.
+	PreviousTitle string 	`json:"previousTitle"`	// Заголовок до правки.
+	Title string 			`json:"title"`			// Заголовок после правки.
+	PreviousMessage string 	`json:"previousMessage"`	// Описание до правки.
+	Message string 			`json:"message"`		// Описание после правки.
 }
 
 type User struct {
@@ -48,6 +152,27 @@ type User struct {
 	Email string 			`json:"email"`			// Почтовый адрес пользователя (уникальное поле).
 	FullName string 		`json:"fullname"`		// Полное имя пользователя.
 	NickName string 		`json:"nickname"`		// Имя пользователя (уникальное поле). Данное поле допускает только латиницу, цифры и знак подчеркивания. Сравнение имени регистронезависимо.
+	Password string 		`json:"password,omitempty"`	// Только во входящих запросах, в ответах всегда пустой.
+	PasswordHash string 	`json:"-"`				// Хэш пароля для сохранения, из базы не читается.
+	Stats *UserStats 		`json:"stats,omitempty"`	// Только в профиле с related=stats.
+}
+
+type UserStats struct {
+	Posts int64 			`json:"posts"`			// Кол-во сообщений пользователя.
+	Threads int32 			`json:"threads"`		// Кол-во веток, созданных пользователем.
+	Votes int64 			`json:"votes"`			// Сумма голосов за ветки пользователя.
+	Forums int32 			`json:"forums"`			// Кол-во форумов, в которых пользователь писал.
+}
+
+type Credentials struct {
+	Nickname string 		`json:"nickname"`
+	Password string 		`json:"password"`
+}
+
+type Token struct {
+	Token string 			`json:"token"`			// Передается в заголовке Authorization: Bearer <token> или в cookie.
+	Nickname string 		`json:"nickname"`		// Владелец токена.
+	Expires time.Time 		`json:"expires"`		// Время, после которого токен не принимается.
 }
 
 type Vote struct {
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+// memDB keeps the whole forum in process memory. It reproduces the behaviour of the
+// forum.sql schema: case insensitive citext keys, counters maintained by the
+// thread_create/post_create/vote_create/vote_update triggers and forum_users.
+type memDB struct {
+	mu sync.RWMutex
+
+	users  map[string]*models.User // Ключ - ник в нижнем регистре
+	emails map[string]string       // email в нижнем регистре -> ключ пользователя
+
+	passwords map[string]string   // Ключ пользователя -> хэш пароля
+	sessions  map[string]*Session // По id сессии
+
+	forums     map[string]*models.Forum                // Ключ - slug в нижнем регистре
+	forumUsers map[string]map[string]bool              // Форум -> пользователи, писавшие в нем
+	forumRoles map[string]map[string]*models.ForumRole // Форум -> пользователь -> роль
+	categories map[string]*models.Category             // Ключ - slug в нижнем регистре
+
+	threads     map[int32]*models.Thread
+	threadSlugs map[string]int32 // slug в нижнем регистре -> id ветки
+	threadSeq   int32
+
+	threadRevisions map[int32][]models.ThreadRevision
+
+	posts       map[int64]*memPost
+	threadPosts map[int32][]int64 // Посты ветки в порядке создания
+	postSeq     int64
+
+	votes map[memVoteKey]int32
+}
+
+type memPost struct {
+	post      models.Post
+	path      []int64 // Аналог posts.id_array: id всех предков и самого поста
+	revisions []models.PostRevision
+}
+
+type memVoteKey struct {
+	nickname string
+	thread   int32
+}
+
+func NewMemory() *Store {
+	m := &memDB{}
+	m.reset()
+
+	return &Store{
+		Users:      &memUsers{m},
+		Forums:     &memForums{m},
+		Categories: &memCategories{m},
+		Threads:    &memThreads{m},
+		Posts:      &memPosts{m},
+		Votes:      &memVotes{m},
+		Service:    &memService{m},
+		Sessions:   &memSessions{m},
+		Search:     &memSearch{m},
+	}
+}
+
+// reset drops all data but, like TRUNCATE, keeps the id sequences.
+func (m *memDB) reset() {
+	m.users = make(map[string]*models.User)
+	m.emails = make(map[string]string)
+	m.passwords = make(map[string]string)
+	m.sessions = make(map[string]*Session)
+	m.forums = make(map[string]*models.Forum)
+	m.forumUsers = make(map[string]map[string]bool)
+	m.forumRoles = make(map[string]map[string]*models.ForumRole)
+	m.categories = make(map[string]*models.Category)
+	m.threads = make(map[int32]*models.Thread)
+	m.threadSlugs = make(map[string]int32)
+	m.threadRevisions = make(map[int32][]models.ThreadRevision)
+	m.posts = make(map[int64]*memPost)
+	m.threadPosts = make(map[int32][]int64)
+	m.votes = make(map[memVoteKey]int32)
+}
+
+// key folds a citext value.
+func key(s string) string {
+	return strings.ToLower(s)
+}
+
+func (m *memDB) user(nickname string) *models.User {
+	return m.users[key(nickname)]
+}
+
+func (m *memDB) forum(slug string) *models.Forum {
+	return m.forums[key(slug)]
+}
+
+// thread skips deleted threads like the postgres queries do, see anyThread.
+func (m *memDB) thread(slugOrId string) *models.Thread {
+	thr := m.anyThread(slugOrId)
+	if thr == nil || thr.IsDeleted {
+		return nil
+	}
+
+	return thr
+}
+
+func (m *memDB) anyThread(slugOrId string) *models.Thread {
+	id, ok := parseThreadId(slugOrId)
+	if !ok {
+		id, ok = m.threadSlugs[key(slugOrId)]
+		if !ok {
+			return nil
+		}
+	}
+
+	return m.threads[id]
+}
+
+func (m *memDB) addForumUser(forum, nickname string) {
+	users := m.forumUsers[key(forum)]
+	if users == nil {
+		users = make(map[string]bool)
+		m.forumUsers[key(forum)] = users
+	}
+
+	users[key(nickname)] = true
+}
+
+func parseThreadId(slugOrId string) (int32, bool) {
+	id, err := strconv.Atoi(slugOrId)
+	if err != nil {
+		return 0, false
+	}
+
+	return int32(id), true
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type memCategories struct {
+	*memDB
+}
+
+func (s *memCategories) Get(ctx context.Context, slug string) (*models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	category := s.categories[key(slug)]
+	if category == nil {
+		return nil, ErrNotFound
+	}
+
+	res := *category
+	return &res, nil
+}
+
+func (s *memCategories) Create(ctx context.Context, category *models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.categories[key(category.Slug)] != nil {
+		return ErrConflict
+	}
+
+	category.Totals, category.Forums = nil, nil
+
+	stored := *category
+	s.categories[key(category.Slug)] = &stored
+
+	return nil
+}
+
+func (s *memCategories) Update(ctx context.Context, slug string, update *models.CategoryUpdate) (*models.Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category := s.categories[key(slug)]
+	if category == nil {
+		return nil, ErrNotFound
+	}
+
+	if update.Title != "" {
+		category.Title = update.Title
+	}
+	if update.Description != "" {
+		category.Description = update.Description
+	}
+	if update.Position != nil {
+		category.Position = *update.Position
+	}
+
+	res := *category
+	return &res, nil
+}
+
+func (s *memCategories) Delete(ctx context.Context, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.categories[key(slug)] == nil {
+		return ErrNotFound
+	}
+
+	// Как внешний ключ forums.category
+	for _, f := range s.forums {
+		if f.Category != "" && key(f.Category) == key(slug) {
+			return ErrConflict
+		}
+	}
+
+	delete(s.categories, key(slug))
+
+	return nil
+}
+
+func (s *memCategories) List(ctx context.Context) ([]models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categories := make([]models.Category, 0, len(s.categories))
+	for _, c := range s.categories {
+		categories = append(categories, *c)
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Position != categories[j].Position {
+			return categories[i].Position < categories[j].Position
+		}
+		return key(categories[i].Slug) < key(categories[j].Slug)
+	})
+
+	return categories, nil
+}
@@ -0,0 +1,306 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type memForums struct {
+	*memDB
+}
+
+func (s *memForums) Get(ctx context.Context, slug string) (*models.Forum, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	forum := s.forum(slug)
+	if forum == nil {
+		return nil, ErrNotFound
+	}
+
+	res := *forum
+	return &res, nil
+}
+
+func (s *memForums) Create(ctx context.Context, forum *models.Forum) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	author := s.user(forum.User)
+	if author == nil {
+		return ErrNotFound
+	}
+	if s.forum(forum.Slug) != nil {
+		return ErrConflict
+	}
+
+	// Как внешние ключи forums.category и forums.parent
+	if forum.Category != "" {
+		category := s.categories[key(forum.Category)]
+		if category == nil {
+			return ErrNotFound
+		}
+		forum.Category = category.Slug
+	}
+	if forum.Parent != "" {
+		parent := s.forum(forum.Parent)
+		if parent == nil {
+			return ErrNotFound
+		}
+		forum.Parent = parent.Slug
+	}
+
+	forum.User = author.NickName
+	forum.Created = time.Now()
+	forum.Posts = 0
+	forum.Threads = 0
+	forum.Totals, forum.Breadcrumbs, forum.Children = nil, nil, nil
+
+	stored := *forum
+	s.forums[key(forum.Slug)] = &stored
+
+	return nil
+}
+
+func (s *memForums) Users(ctx context.Context, slug string, filter UserFilter) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.forum(slug) == nil {
+		return nil, ErrNotFound
+	}
+
+	since := key(filter.Since)
+	nicknames := make([]string, 0, len(s.forumUsers[key(slug)]))
+
+	for nickname := range s.forumUsers[key(slug)] {
+		if since != "" && (!filter.Desc && nickname <= since || filter.Desc && nickname >= since) {
+			continue
+		}
+		nicknames = append(nicknames, nickname)
+	}
+
+	if filter.Desc {
+		sort.Sort(sort.Reverse(sort.StringSlice(nicknames)))
+	} else {
+		sort.Strings(nicknames)
+	}
+
+	if filter.Limit > 0 && len(nicknames) > filter.Limit {
+		nicknames = nicknames[:filter.Limit]
+	}
+
+	users := make([]models.User, 0, len(nicknames))
+	for _, nickname := range nicknames {
+		users = append(users, *s.users[nickname])
+	}
+
+	return users, nil
+}
+
+func (s *memForums) Threads(ctx context.Context, slug string, filter ThreadFilter) ([]models.Thread, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	forum := s.forum(slug)
+	if forum == nil {
+		return nil, ErrNotFound
+	}
+
+	thrs := make([]models.Thread, 0)
+
+	for _, thr := range s.threads {
+		if key(thr.Forum) != key(forum.Slug) || thr.IsDeleted {
+			continue
+		}
+		if filter.Author != "" && key(thr.Author) != key(filter.Author) {
+			continue
+		}
+		if !createdInWindow(thr.Created, filter) {
+			continue
+		}
+
+		if filter.After != nil && !keyBefore(*filter.After, threadSortKey(filter.Sort, thr), filter.Desc) {
+			continue
+		}
+		thrs = append(thrs, *thr)
+	}
+
+	sort.Slice(thrs, func(i, j int) bool {
+		return keyBefore(threadSortKey(filter.Sort, &thrs[i]), threadSortKey(filter.Sort, &thrs[j]), filter.Desc)
+	})
+
+	if filter.Limit > 0 && len(thrs) > filter.Limit {
+		thrs = thrs[:filter.Limit]
+	}
+
+	return thrs, nil
+}
+
+// threadSortKey is the position of the thread in ForumStore.Threads sorted by sort.
+func threadSortKey(sort string, thr *models.Thread) ThreadKey {
+	// Как и в курсоре, задано только значение сортировки: равные значения упорядочены по id
+	k := ThreadKey{Id: int64(thr.Id)}
+
+	switch sort {
+	case ThreadsByVotes:
+		k.Number = int64(thr.Votes)
+	case ThreadsByPosts:
+		k.Number = thr.Posts
+	case ThreadsByActivity:
+		k.Time = thr.Created
+		if thr.LastPostAt != nil {
+			k.Time = *thr.LastPostAt
+		}
+	default:
+		k.Time = thr.Created
+	}
+
+	return k
+}
+
+// createdInWindow is the Since and Until condition of ThreadFilter, see createdWindow.
+func createdInWindow(created time.Time, filter ThreadFilter) bool {
+	if !filter.Since.IsZero() && created.Before(filter.Since) {
+		return false
+	}
+	return filter.Until.IsZero() || !created.After(filter.Until)
+}
+
+// keyBefore is the ORDER BY value, id of the lists filtered by ThreadFilter. Keys of one
+// list set either Time or Number, the other one is equal in both.
+func keyBefore(a, b ThreadKey, desc bool) bool {
+	if desc {
+		a, b = b, a
+	}
+	switch {
+	case a.Number != b.Number:
+		return a.Number < b.Number
+	case !a.Time.Equal(b.Time):
+		return a.Time.Before(b.Time)
+	}
+	return a.Id < b.Id
+}
+
+func (s *memForums) Family(ctx context.Context, slug string) ([]models.Forum, []models.Forum, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	forum := s.forum(slug)
+	if forum == nil {
+		return nil, nil, ErrNotFound
+	}
+
+	ancestors := make([]models.Forum, 0)
+	for parent := s.forum(forum.Parent); parent != nil; parent = s.forum(parent.Parent) {
+		ancestors = append([]models.Forum{*parent}, ancestors...)
+	}
+
+	// Обход в ширину по уровням иерархии
+	descendants := make([]models.Forum, 0)
+	for level := map[string]bool{key(forum.Slug): true}; len(level) > 0; {
+		next := make(map[string]bool)
+		for _, f := range s.forums {
+			if f.Parent != "" && level[key(f.Parent)] {
+				descendants = append(descendants, *f)
+				next[key(f.Slug)] = true
+			}
+		}
+		level = next
+	}
+
+	sortForums(descendants)
+
+	return ancestors, descendants, nil
+}
+
+func (s *memForums) All(ctx context.Context) ([]models.Forum, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	forums := make([]models.Forum, 0, len(s.forums))
+	for _, f := range s.forums {
+		forums = append(forums, *f)
+	}
+
+	sortForums(forums)
+
+	return forums, nil
+}
+
+func (s *memForums) List(ctx context.Context, filter ForumFilter) ([]models.Forum, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := strings.ToLower(filter.Query)
+	forums := make([]models.Forum, 0)
+
+	for _, f := range s.forums {
+		if filter.Owner != "" && key(f.User) != key(filter.Owner) {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(f.Title), query) {
+			continue
+		}
+		if filter.After != nil && !forumKeyBefore(*filter.After, forumSortKey(filter.Sort, f), filter.Desc) {
+			continue
+		}
+		forums = append(forums, *f)
+	}
+
+	sort.Slice(forums, func(i, j int) bool {
+		return forumKeyBefore(forumSortKey(filter.Sort, &forums[i]), forumSortKey(filter.Sort, &forums[j]), filter.Desc)
+	})
+
+	if filter.Limit > 0 && len(forums) > filter.Limit {
+		forums = forums[:filter.Limit]
+	}
+
+	return forums, nil
+}
+
+// forumSortKey is the position of the forum in ForumStore.List sorted by sort.
+func forumSortKey(sort string, f *models.Forum) ForumKey {
+	k := ForumKey{Slug: f.Slug}
+
+	switch sort {
+	case ForumsByCreated:
+		k.Created = f.Created
+	case ForumsByPosts:
+		k.Number = f.Posts
+	case ForumsByThreads:
+		k.Number = int64(f.Threads)
+	default:
+		k.Title = f.Title
+	}
+
+	return k
+}
+
+// forumKeyBefore is the ORDER BY value, slug of ForumStore.List, like keyBefore. Titles
+// are citext.
+func forumKeyBefore(a, b ForumKey, desc bool) bool {
+	if desc {
+		a, b = b, a
+	}
+	switch {
+	case key(a.Title) != key(b.Title):
+		return key(a.Title) < key(b.Title)
+	case !a.Created.Equal(b.Created):
+		return a.Created.Before(b.Created)
+	case a.Number != b.Number:
+		return a.Number < b.Number
+	}
+	return key(a.Slug) < key(b.Slug)
+}
+
+// sortForums orders forums by slug like ORDER BY over citext.
+func sortForums(forums []models.Forum) {
+	sort.Slice(forums, func(i, j int) bool {
+		return key(forums[i].Slug) < key(forums[j].Slug)
+	})
+}
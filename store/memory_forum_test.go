@@ -0,0 +1,281 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+var threadsBase = time.Date(2018, 3, 4, 10, 0, 0, 0, time.UTC)
+
+// seedThreads makes forum f with threads of a and b: equal created times, votes and post
+// counts, a deleted thread and a thread of another forum. It returns the live threads of f.
+func seedThreads(t *testing.T, s *Store) []models.Thread {
+	ctx := context.Background()
+
+	for _, nickname := range []string{"a", "b", "v1", "v2", "v3"} {
+		user := &models.User{NickName: nickname, Email: nickname + "@mail.ru", FullName: nickname}
+		if err := s.Users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, slug := range []string{"f", "g"} {
+		if err := s.Forums.Create(ctx, &models.Forum{Slug: slug, Title: slug, User: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Часы создания, голоса и посты веток форума f
+	spec := []struct {
+		hours int
+		votes int
+		posts int
+	}{
+		{0, 1, 0}, {1, 0, 2}, {1, 1, 1}, {2, -1, 0}, {3, 2, 1}, {3, 0, 0}, {4, 1, 3}, {5, 0, 1}, {6, 3, 0},
+	}
+
+	voters := []string{"v1", "v2", "v3"}
+
+	for i, sp := range spec {
+		thr := &models.Thread{
+			Author:  []string{"a", "b"}[i%2],
+			Created: threadsBase.Add(time.Duration(sp.hours) * time.Hour),
+			Title:   fmt.Sprint("t", i),
+			Message: "m",
+		}
+		if err := s.Threads.Create(ctx, "f", thr); err != nil {
+			t.Fatal(err)
+		}
+
+		for v := 0; v < sp.votes || v < -sp.votes; v++ {
+			voice := int32(1)
+			if sp.votes < 0 {
+				voice = -1
+			}
+			if _, err := s.Votes.Vote(ctx, fmt.Sprint(thr.Id), &models.Vote{Nickname: voters[v], Voice: voice}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for p := 0; p < sp.posts; p++ {
+			if _, err := s.Posts.Create(ctx, thr, []models.Post{{Author: "b", Message: "p"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	deleted := &models.Thread{Author: "a", Created: threadsBase.Add(2 * time.Hour), Title: "d", Message: "m"}
+	if err := s.Threads.Create(ctx, "f", deleted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Threads.Delete(ctx, fmt.Sprint(deleted.Id)); err != nil {
+		t.Fatal(err)
+	}
+
+	other := &models.Thread{Author: "a", Created: threadsBase.Add(2 * time.Hour), Title: "o", Message: "m"}
+	if err := s.Threads.Create(ctx, "g", other); err != nil {
+		t.Fatal(err)
+	}
+
+	thrs := make([]models.Thread, 0, len(spec))
+	for id := deleted.Id - int32(len(spec)); id < deleted.Id; id++ {
+		thr, err := s.Threads.Get(ctx, fmt.Sprint(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		thrs = append(thrs, *thr)
+	}
+
+	return thrs
+}
+
+// sortValue is what the list is ordered by before id, like the ORDER BY of pgForums.Threads.
+func sortValue(sortName string, thr models.Thread) ThreadKey {
+	switch sortName {
+	case ThreadsByVotes:
+		return ThreadKey{Number: int64(thr.Votes), Id: int64(thr.Id)}
+	case ThreadsByPosts:
+		return ThreadKey{Number: thr.Posts, Id: int64(thr.Id)}
+	case ThreadsByActivity:
+		if thr.LastPostAt != nil {
+			return ThreadKey{Time: *thr.LastPostAt, Id: int64(thr.Id)}
+		}
+	}
+	return ThreadKey{Time: thr.Created, Id: int64(thr.Id)}
+}
+
+// expectedThreads filters and orders all by hand.
+func expectedThreads(all []models.Thread, filter ThreadFilter) []models.Thread {
+	res := make([]models.Thread, 0)
+
+	for _, thr := range all {
+		if filter.Author != "" && !strings.EqualFold(thr.Author, filter.Author) {
+			continue
+		}
+		if !filter.Since.IsZero() && thr.Created.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && thr.Created.After(filter.Until) {
+			continue
+		}
+		res = append(res, thr)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		a, b := sortValue(filter.Sort, res[i]), sortValue(filter.Sort, res[j])
+		if filter.Desc {
+			a, b = b, a
+		}
+		if a.Number != b.Number {
+			return a.Number < b.Number
+		}
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return a.Id < b.Id
+	})
+
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[:filter.Limit]
+	}
+
+	return res
+}
+
+func threadIds(thrs []models.Thread) []int32 {
+	ids := make([]int32, len(thrs))
+	for i := range thrs {
+		ids[i] = thrs[i].Id
+	}
+	return ids
+}
+
+func TestForumThreadsFilters(t *testing.T) {
+	s := NewMemory()
+	all := seedThreads(t, s)
+	ctx := context.Background()
+
+	times := []time.Time{{}, threadsBase.Add(time.Hour), threadsBase.Add(3*time.Hour + time.Minute), threadsBase.Add(10 * time.Hour)}
+
+	checked := 0
+
+	for _, since := range times {
+		for _, until := range times {
+			for _, author := range []string{"", "a", "B", "nobody"} {
+				for _, sortName := range []string{"", ThreadsByCreated, ThreadsByVotes, ThreadsByActivity, ThreadsByPosts} {
+					for _, desc := range []bool{false, true} {
+						for _, limit := range []int{0, 1, 3, 100} {
+							filter := ThreadFilter{Limit: limit, Since: since, Until: until, Author: author, Sort: sortName, Desc: desc}
+							name := fmt.Sprintf("%+v", filter)
+
+							got, err := s.Forums.Threads(ctx, "F", filter)
+							if err != nil {
+								t.Fatalf("%s: %v", name, err)
+							}
+
+							want := expectedThreads(all, filter)
+							if fmt.Sprint(threadIds(got)) != fmt.Sprint(threadIds(want)) {
+								t.Errorf("%s:\n got %v\nwant %v", name, threadIds(got), threadIds(want))
+							}
+
+							if limit == 0 || limit >= len(all) {
+								checked++
+								continue
+							}
+
+							// Постранично с After последней ветки страницы - тот же список целиком
+							filter.Limit = 0
+							whole := expectedThreads(all, filter)
+							filter.Limit = limit
+
+							pages := make([]models.Thread, 0)
+							for i := 0; i <= len(all); i++ {
+								page, err := s.Forums.Threads(ctx, "f", filter)
+								if err != nil {
+									t.Fatalf("%s: %v", name, err)
+								}
+
+								pages = append(pages, page...)
+								if len(page) < limit {
+									break
+								}
+
+								after := sortValue(sortName, page[len(page)-1])
+								filter.After = &after
+							}
+
+							if fmt.Sprint(threadIds(pages)) != fmt.Sprint(threadIds(whole)) {
+								t.Errorf("%s pages:\n got %v\nwant %v", name, threadIds(pages), threadIds(whole))
+							}
+
+							checked++
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if checked != len(times)*len(times)*4*5*2*4 {
+		t.Errorf("checked %d combinations", checked)
+	}
+}
+
+func TestForumThreadsNotFound(t *testing.T) {
+	s := NewMemory()
+
+	if _, err := s.Forums.Threads(context.Background(), "nope", ThreadFilter{}); err != ErrNotFound {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestForumListTitleCase(t *testing.T) {
+	s := NewMemory()
+	ctx := context.Background()
+
+	if err := s.Users.Create(ctx, &models.User{NickName: "a", Email: "a@mail.ru", FullName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// title - citext: регистр не влияет на порядок, равные названия идут по slug
+	for _, f := range [][2]string{{"f1", "beta"}, {"f2", "Alpha"}, {"f3", "Beta"}, {"f4", "alpha"}, {"f5", "Gamma"}} {
+		if err := s.Forums.Create(ctx, &models.Forum{Slug: f[0], Title: f[1], User: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, desc := range []bool{false, true} {
+		want := "[f2 f4 f1 f3 f5]"
+		if desc {
+			want = "[f5 f3 f1 f4 f2]"
+		}
+
+		filter := ForumFilter{Limit: 2, Desc: desc}
+		slugs := make([]string, 0)
+
+		for i := 0; i < 5; i++ {
+			page, err := s.Forums.List(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range page {
+				slugs = append(slugs, f.Slug)
+			}
+			if len(page) < filter.Limit {
+				break
+			}
+
+			after := forumSortKey(ForumsByTitle, &page[len(page)-1])
+			filter.After = &after
+		}
+
+		if fmt.Sprint(slugs) != want {
+			t.Errorf("desc %v: got %v, want %s", desc, slugs, want)
+		}
+	}
+}
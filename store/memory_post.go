@@ -0,0 +1,233 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type memPosts struct {
+	*memDB
+}
+
+func (s *memPosts) Get(ctx context.Context, id int64) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p := s.posts[id]
+	if p == nil {
+		return nil, ErrNotFound
+	}
+
+	res := p.post
+	return &res, nil
+}
+
+func (s *memPosts) Details(ctx context.Context, id int64, related Related) (*models.PostDetail, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p := s.posts[id]
+	if p == nil {
+		return nil, ErrNotFound
+	}
+
+	post := p.post
+	postDetail := &models.PostDetail{Post: &post}
+
+	if related.User {
+		user := *s.user(post.Author)
+		postDetail.Author = &user
+	}
+	if related.Thread {
+		thr := *s.threads[post.Thread]
+		postDetail.Thread = &thr
+	}
+	if related.Forum {
+		forum := *s.forum(post.Forum)
+		postDetail.Forum = &forum
+	}
+
+	return postDetail, nil
+}
+
+func (s *memPosts) Create(ctx context.Context, thr *models.Thread, posts []models.Post) ([]models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Как и в post_create, родитель проверяется раньше внешнего ключа на автора
+	batchPaths := make(map[int64][]int64, len(posts))
+	nextId := s.postSeq
+
+	for _, p := range posts {
+		nextId++
+
+		var parentPath []int64
+		if p.Parent != 0 {
+			if parent := s.posts[p.Parent]; parent != nil && parent.post.Thread == thr.Id {
+				parentPath = parent.path
+			} else if path, ok := batchPaths[p.Parent]; ok {
+				parentPath = path
+			} else {
+				return nil, ErrParentConflict
+			}
+		}
+
+		path := make([]int64, len(parentPath), len(parentPath)+1)
+		copy(path, parentPath)
+		batchPaths[nextId] = append(path, nextId)
+	}
+
+	for _, p := range posts {
+		if s.user(p.Author) == nil {
+			return nil, ErrNotFound
+		}
+	}
+
+	// Ветку или форум могли удалить после того, как обработчик их прочитал,
+	// postgres в этом случае отвечает нарушением внешнего ключа
+	forum, stored := s.forum(thr.Forum), s.threads[thr.Id]
+	if forum == nil || stored == nil {
+		return nil, ErrNotFound
+	}
+
+	created := time.Now()
+	data := make([]models.Post, 0, len(posts))
+
+	for _, p := range posts {
+		s.postSeq++
+
+		newPost := models.Post{
+			Author:  p.Author,
+			Created: created,
+			Forum:   forum.Slug,
+			Id:      s.postSeq,
+			Message: p.Message,
+			Parent:  p.Parent,
+			Thread:  thr.Id,
+		}
+
+		s.posts[newPost.Id] = &memPost{post: newPost, path: batchPaths[newPost.Id]}
+		s.threadPosts[thr.Id] = append(s.threadPosts[thr.Id], newPost.Id)
+		s.addForumUser(forum.Slug, p.Author)
+		forum.Posts++
+
+		data = append(data, newPost)
+	}
+
+	// Как в post_create: у постов пачки одно время, последний - с большим id
+	if len(data) > 0 {
+		stored.Posts += int64(len(data))
+		stored.LastPostAt = &created
+		stored.LastPostAuthor = data[len(data)-1].Author
+	}
+
+	return data, nil
+}
+
+func (s *memPosts) Update(ctx context.Context, id int64, message, editor string) (*models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.posts[id]
+	if p == nil || message != "" && p.post.IsDeleted {
+		return nil, ErrNotFound
+	}
+
+	if message != "" {
+		if message != p.post.Message {
+			p.revisions = append(p.revisions, models.PostRevision{
+				Revision: int32(len(p.revisions) + 1),
+				Editor:   editor,
+				Edited:   time.Now(),
+				Previous: p.post.Message,
+				Message:  message,
+			})
+		}
+
+		// Триггер change_message сбрасывает isedited, если текст не изменился
+		p.post.IsEdited = message != p.post.Message
+		p.post.Message = message
+	}
+
+	res := p.post
+	return &res, nil
+}
+
+func (s *memPosts) History(ctx context.Context, id int64) ([]models.PostRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p := s.posts[id]
+	if p == nil {
+		return nil, ErrNotFound
+	}
+
+	revisions := make([]models.PostRevision, len(p.revisions))
+	copy(revisions, p.revisions)
+
+	return revisions, nil
+}
+
+func (s *memPosts) Delete(ctx context.Context, id int64) (*models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.posts[id]
+	if p == nil || p.post.IsDeleted {
+		return nil, ErrNotFound
+	}
+
+	return s.setDeleted(p, true), nil
+}
+
+func (s *memPosts) Restore(ctx context.Context, id int64) (*models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.posts[id]
+	if p == nil || !p.post.IsDeleted {
+		return nil, ErrNotFound
+	}
+
+	return s.setDeleted(p, false), nil
+}
+
+// setDeleted changes the mark and the forum counter like trigger post_delete.
+func (s *memPosts) setDeleted(p *memPost, deleted bool) *models.Post {
+	p.post.IsDeleted = deleted
+
+	// Посты удаленной ветки уже вычтены из счетчика форума, см. memThreads.setDeleted
+	if !s.threads[p.post.Thread].IsDeleted {
+		if deleted {
+			s.forum(p.post.Forum).Posts--
+		} else {
+			s.forum(p.post.Forum).Posts++
+		}
+	}
+
+	s.threadActivity(s.threads[p.post.Thread])
+
+	res := p.post
+	return &res
+}
+
+// threadActivity recounts the posts of the thread and finds its last post, as post_delete does.
+func (s *memDB) threadActivity(thr *models.Thread) {
+	thr.Posts, thr.LastPostAt, thr.LastPostAuthor = 0, nil, ""
+
+	// threadPosts идут в порядке id, при равном времени последним остается больший id
+	for _, id := range s.threadPosts[thr.Id] {
+		post := s.posts[id].post
+		if post.IsDeleted {
+			continue
+		}
+
+		thr.Posts++
+		if thr.LastPostAt == nil || !post.Created.Before(*thr.LastPostAt) {
+			created := post.Created
+			thr.LastPostAt, thr.LastPostAuthor = &created, post.Author
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+// Ветка, прочитанная до очистки базы, больше не принимает посты
+func TestPostCreateGoneThread(t *testing.T) {
+	s := NewMemory()
+	ctx := context.Background()
+
+	if err := s.Users.Create(ctx, &models.User{NickName: "a", Email: "a@mail.ru", FullName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Forums.Create(ctx, &models.Forum{Slug: "f", Title: "f", User: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	thr := &models.Thread{Author: "a", Title: "t", Message: "m"}
+	if err := s.Threads.Create(ctx, "f", thr); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Service.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Users.Create(ctx, &models.User{NickName: "a", Email: "a@mail.ru", FullName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Posts.Create(ctx, thr, []models.Post{{Author: "a", Message: "p"}}); err != ErrNotFound {
+		t.Errorf("gone forum: got %v", err)
+	}
+
+	// Форум на месте, ветки нет
+	if err := s.Forums.Create(ctx, &models.Forum{Slug: "f", Title: "f", User: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Posts.Create(ctx, thr, []models.Post{{Author: "a", Message: "p"}}); err != ErrNotFound {
+		t.Errorf("gone thread: got %v", err)
+	}
+}
+
+func TestThreadActivity(t *testing.T) {
+	s := NewMemory()
+	ctx := context.Background()
+
+	for _, nickname := range []string{"a", "b"} {
+		if err := s.Users.Create(ctx, &models.User{NickName: nickname, Email: nickname + "@mail.ru", FullName: nickname}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Forums.Create(ctx, &models.Forum{Slug: "f", Title: "f", User: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	thr := &models.Thread{Author: "a", Title: "t", Message: "m"}
+	if err := s.Threads.Create(ctx, "f", thr); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(step string, posts int64, author string, at *time.Time) {
+		t.Helper()
+
+		got, err := s.Threads.Get(ctx, "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.Posts != posts || got.LastPostAuthor != author || (got.LastPostAt == nil) != (at == nil) || at != nil && !got.LastPostAt.Equal(*at) {
+			t.Errorf("%s: got %d posts, last %q at %v, want %d, %q at %v", step, got.Posts, got.LastPostAuthor, got.LastPostAt, posts, author, at)
+		}
+	}
+
+	check("no posts", 0, "", nil)
+
+	// У постов пачки одно время, последний - последний в пачке
+	first, err := s.Posts.Create(ctx, thr, []models.Post{{Author: "a", Message: "p"}, {Author: "b", Message: "p"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("first batch", 2, "b", &first[1].Created)
+
+	second, err := s.Posts.Create(ctx, thr, []models.Post{{Author: "a", Message: "p", Parent: first[0].Id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("second batch", 3, "a", &second[0].Created)
+
+	if _, err := s.Posts.Delete(ctx, second[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	check("last post deleted", 2, "b", &first[1].Created)
+
+	if _, err := s.Posts.Delete(ctx, first[1].Id); err != nil {
+		t.Fatal(err)
+	}
+	check("equal time", 1, "a", &first[0].Created)
+
+	if _, err := s.Posts.Delete(ctx, first[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	check("all deleted", 0, "", nil)
+
+	if _, err := s.Posts.Restore(ctx, second[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	check("restored", 1, "a", &second[0].Created)
+}
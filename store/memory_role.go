@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+func (s *memForums) Role(ctx context.Context, slug, nickname string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if fr, ok := s.forumRoles[key(slug)][key(nickname)]; ok {
+		return fr.Role, nil
+	}
+
+	return "", nil
+}
+
+func (s *memForums) Banned(ctx context.Context, slug string, nicknames []string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := s.forumRoles[key(slug)]
+
+	for _, nickname := range nicknames {
+		if fr, ok := roles[key(nickname)]; ok && fr.Role == RoleBanned {
+			return fr.Nickname, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (s *memForums) Roles(ctx context.Context, slug, role string) ([]models.ForumRole, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.forum(slug) == nil {
+		return nil, ErrNotFound
+	}
+
+	roles := make([]models.ForumRole, 0)
+
+	for _, fr := range s.forumRoles[key(slug)] {
+		if fr.Role == role {
+			roles = append(roles, *fr)
+		}
+	}
+
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Nickname < roles[j].Nickname })
+
+	return roles, nil
+}
+
+func (s *memForums) SetRole(ctx context.Context, slug string, role *models.ForumRole) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	forum := s.forum(slug)
+	user := s.user(role.Nickname)
+	if forum == nil || user == nil {
+		return ErrNotFound
+	}
+
+	roles := s.forumRoles[key(forum.Slug)]
+	if roles == nil {
+		roles = make(map[string]*models.ForumRole)
+		s.forumRoles[key(forum.Slug)] = roles
+	}
+
+	role.Nickname = user.NickName
+	role.Created = time.Now()
+
+	stored := *role
+	roles[key(user.NickName)] = &stored
+
+	return nil
+}
+
+func (s *memForums) DeleteRole(ctx context.Context, slug, nickname, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := s.forumRoles[key(slug)]
+
+	fr, ok := roles[key(nickname)]
+	if !ok || fr.Role != role {
+		return ErrNotFound
+	}
+
+	delete(roles, key(nickname))
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+// Веса частей текста как у ts_rank по умолчанию: заголовок ветки - A, остальное - B
+const (
+	titleWeight   = 1.0
+	messageWeight = 0.4
+)
+
+// snippetWords is the length of a snippet like MaxWords in headlineOptions.
+const snippetWords = 35
+
+type memSearch struct {
+	*memDB
+}
+
+// Search scans everything. Words are split like the simple text search configuration
+// does and the rank only approximates ts_rank: weighted matches per word of the text.
+func (s *memSearch) Search(ctx context.Context, filter SearchFilter) ([]models.SearchHit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := make(map[string]bool)
+	for _, w := range words(filter.Query) {
+		terms[w.text] = true
+	}
+
+	hits := make([]models.SearchHit, 0)
+	bodies := make(map[SearchKey]string)
+
+	if len(terms) == 0 {
+		return hits, nil
+	}
+
+	if filter.Kind != SearchThread {
+		for _, p := range s.posts {
+			post := p.post
+			if post.IsDeleted || s.threads[post.Thread].IsDeleted || !searchMatches(filter, post.Forum, post.Author, post.Created) {
+				continue
+			}
+
+			rank, ok := textRank(terms, text{post.Message, messageWeight})
+			if !ok {
+				continue
+			}
+
+			hit := models.SearchHit{Kind: SearchPost, Id: post.Id, Thread: post.Thread, Forum: post.Forum,
+				Author: post.Author, Created: post.Created, Rank: rank}
+			hits = append(hits, hit)
+			bodies[hitKey(hit)] = post.Message
+		}
+	}
+
+	if filter.Kind != SearchPost {
+		for _, thr := range s.threads {
+			if thr.IsDeleted || !searchMatches(filter, thr.Forum, thr.Author, thr.Created) {
+				continue
+			}
+
+			rank, ok := textRank(terms, text{thr.Title, titleWeight}, text{thr.Message, messageWeight})
+			if !ok {
+				continue
+			}
+
+			hit := models.SearchHit{Kind: SearchThread, Id: int64(thr.Id), Thread: thr.Id, Forum: thr.Forum,
+				Author: thr.Author, Created: thr.Created, Rank: rank}
+			hits = append(hits, hit)
+			bodies[hitKey(hit)] = thr.Title + "\n" + thr.Message
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		return searchBefore(hitKey(hits[i]), hitKey(hits[j]))
+	})
+
+	if filter.After != nil {
+		skip := sort.Search(len(hits), func(i int) bool {
+			return searchBefore(*filter.After, hitKey(hits[i]))
+		})
+		hits = hits[skip:]
+	}
+
+	if filter.Limit > 0 && len(hits) > filter.Limit {
+		hits = hits[:filter.Limit]
+	}
+
+	for i := range hits {
+		hits[i].Snippet = highlight(bodies[hitKey(hits[i])], terms)
+	}
+
+	return hits, nil
+}
+
+func searchMatches(filter SearchFilter, forum, author string, created time.Time) bool {
+	if filter.Forum != "" && key(forum) != key(filter.Forum) {
+		return false
+	}
+	if filter.Author != "" && key(author) != key(filter.Author) {
+		return false
+	}
+	if !filter.Since.IsZero() && created.Before(filter.Since) {
+		return false
+	}
+	return true
+}
+
+func hitKey(hit models.SearchHit) SearchKey {
+	return SearchKey{Rank: hit.Rank, Kind: hit.Kind, Id: hit.Id}
+}
+
+// searchBefore reports whether a goes before b in the search order.
+func searchBefore(a, b SearchKey) bool {
+	if a.Rank != b.Rank {
+		return a.Rank > b.Rank
+	}
+	if a.Kind != b.Kind {
+		return a.Kind > b.Kind
+	}
+	return a.Id > b.Id
+}
+
+type text struct {
+	value  string
+	weight float32
+}
+
+// textRank returns the rank of texts and false unless they contain every term.
+func textRank(terms map[string]bool, texts ...text) (float32, bool) {
+	found := make(map[string]bool, len(terms))
+	var score float32
+	total := 0
+
+	for _, t := range texts {
+		for _, w := range words(t.value) {
+			total++
+			if terms[w.text] {
+				found[w.text] = true
+				score += t.weight
+			}
+		}
+	}
+
+	if len(found) < len(terms) {
+		return 0, false
+	}
+
+	return score / float32(total), true
+}
+
+type word struct {
+	text       string // В нижнем регистре
+	start, end int    // Байтовые границы в исходном тексте
+}
+
+func words(s string) []word {
+	res := make([]word, 0)
+	start := -1
+
+	for i, c := range s {
+		inWord := unicode.IsLetter(c) || unicode.IsDigit(c)
+
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			res = append(res, word{strings.ToLower(s[start:i]), start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		res = append(res, word{strings.ToLower(s[start:]), start, len(s)})
+	}
+
+	return res
+}
+
+// highlight cuts a fragment of s around the first found term, escapes it for HTML and marks terms with <b></b>.
+func highlight(s string, terms map[string]bool) string {
+	ws := words(s)
+	if len(ws) == 0 {
+		return ""
+	}
+
+	first := 0
+	for i, w := range ws {
+		if terms[w.text] {
+			first = i
+			break
+		}
+	}
+
+	from := first - 5
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(ws) {
+		to = len(ws)
+	}
+
+	var out strings.Builder
+	pos := ws[from].start
+
+	for _, w := range ws[from:to] {
+		out.WriteString(html.EscapeString(s[pos:w.start]))
+		if terms[w.text] {
+			out.WriteString("<b>" + html.EscapeString(s[w.start:w.end]) + "</b>")
+		} else {
+			out.WriteString(html.EscapeString(s[w.start:w.end]))
+		}
+		pos = w.end
+	}
+
+	return out.String()
+}
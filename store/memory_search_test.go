@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+func TestHighlight(t *testing.T) {
+	terms := map[string]bool{"go": true, "code": true}
+
+	// Фрагмент идет от слова до слова, разметка текста остается текстом
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"Go is fun", "<b>Go</b> is fun"},
+		{"no match here", "no match here"},
+		{"<script>go()</script>", "script&gt;<b>go</b>()&lt;/script"},
+		{`a & b "code" 'x'`, "a &amp; b &#34;<b>code</b>&#34; &#39;x"},
+		{"<b>code</b>", "b&gt;<b>code</b>&lt;/b"},
+	}
+
+	for _, c := range cases {
+		if got := highlight(c.text, terms); got != c.want {
+			t.Errorf("%q:\n got %q\nwant %q", c.text, got, c.want)
+		}
+	}
+
+	// Фрагмент начинается за пять слов до первого найденного
+	long := strings.Repeat("w ", 10) + "go" + strings.Repeat(" w", 50)
+	if got := highlight(long, terms); !strings.HasPrefix(got, "w w w w w <b>go</b> w") || len(words(got)) != snippetWords+2 {
+		t.Errorf("long text: got %q", got)
+	}
+}
+
+func TestSearchPages(t *testing.T) {
+	s := NewMemory()
+	ctx := context.Background()
+
+	if err := s.Users.Create(ctx, &models.User{NickName: "a", Email: "a@mail.ru", FullName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Forums.Create(ctx, &models.Forum{Slug: "f", Title: "f", User: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	thr := &models.Thread{Author: "a", Title: "go", Message: "about go"}
+	if err := s.Threads.Create(ctx, "f", thr); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := make([]models.Post, 0)
+	for i := 0; i < 6; i++ {
+		// Чем короче текст, тем выше ранг; у пар постов ранг одинаковый
+		posts = append(posts, models.Post{Author: "a", Message: "go" + strings.Repeat(" x", i/2)})
+	}
+	posts = append(posts, models.Post{Author: "a", Message: "nothing"})
+	if _, err := s.Posts.Create(ctx, thr, posts); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := s.Search.Search(ctx, SearchFilter{Query: "GO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 7 {
+		t.Fatalf("got %d hits", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if !searchBefore(hitKey(all[i-1]), hitKey(all[i])) {
+			t.Errorf("hit %d %+v goes before %+v", i, all[i], all[i-1])
+		}
+	}
+
+	filter := SearchFilter{Query: "go", Limit: 3}
+	pages := make([]models.SearchHit, 0)
+	for i := 0; i < len(all); i++ {
+		page, err := s.Search.Search(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, page...)
+		if len(page) < filter.Limit {
+			break
+		}
+
+		after := hitKey(page[len(page)-1])
+		filter.After = &after
+	}
+
+	if fmt.Sprint(pages) != fmt.Sprint(all) {
+		t.Errorf("pages:\n got %v\nwant %v", pages, all)
+	}
+
+	for kind, want := range map[string]int{SearchPost: 6, SearchThread: 1} {
+		hits, err := s.Search.Search(ctx, SearchFilter{Query: "go", Kind: kind})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hits) != want {
+			t.Errorf("%s: got %d hits", kind, len(hits))
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type memVotes struct {
+	*memDB
+}
+
+func (s *memVotes) Vote(ctx context.Context, slugOrId string, vote *models.Vote) (*models.Thread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	thr := s.thread(slugOrId)
+	if thr == nil || s.user(vote.Nickname) == nil {
+		return nil, ErrNotFound
+	}
+
+	voteKey := memVoteKey{nickname: key(vote.Nickname), thread: thr.Id}
+
+	old, voted := s.votes[voteKey]
+	s.votes[voteKey] = vote.Voice
+
+	// Та же арифметика, что и в триггерах vote_create и vote_update
+	if !voted {
+		thr.Votes += vote.Voice
+	} else if old != vote.Voice {
+		if vote.Voice == -1 {
+			thr.Votes -= 2
+		} else {
+			thr.Votes += 2
+		}
+	}
+
+	res := *thr
+	return &res, nil
+}
+
+type memService struct {
+	*memDB
+}
+
+// Status is always exact, counting is free here.
+func (s *memService) Status(ctx context.Context, mode string) (*models.Status, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := &models.Status{
+		Forum: int32(len(s.forums)),
+		User:  int32(len(s.users)),
+	}
+
+	for _, p := range s.posts {
+		if !p.post.IsDeleted && !s.threads[p.post.Thread].IsDeleted {
+			status.Post++
+		}
+	}
+	for _, thr := range s.threads {
+		if !thr.IsDeleted {
+			status.Thread++
+		}
+	}
+
+	return status, nil
+}
+
+func (s *memService) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+
+	return nil
+}
+
+// Health reports the in-memory store as always up to date, it has no schema.
+func (s *memService) Health(ctx context.Context) (*Health, error) {
+	return &Health{}, nil
+}
+
+// Reconcile has nothing to correct, Status counts the maps directly.
+func (s *memService) Reconcile(ctx context.Context) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
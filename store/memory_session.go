@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+type memSessions struct {
+	*memDB
+}
+
+func (s *memSessions) Create(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.user(session.Nickname)
+	if user == nil {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+
+	for id, existing := range s.sessions {
+		if key(existing.Nickname) == key(user.NickName) && existing.Expires.Before(now) {
+			delete(s.sessions, id)
+		}
+	}
+
+	session.Nickname = user.NickName
+	session.Created = now
+
+	stored := *session
+	s.sessions[session.Id] = &stored
+
+	return nil
+}
+
+func (s *memSessions) Get(ctx context.Context, id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok || !session.Expires.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+
+	res := *session
+	return &res, nil
+}
+
+func (s *memSessions) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+
+	return nil
+}
+
+func (s *memSessions) DeleteUser(ctx context.Context, nickname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if key(session.Nickname) == key(nickname) {
+			delete(s.sessions, id)
+		}
+	}
+
+	return nil
+}
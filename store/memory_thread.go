@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type memThreads struct {
+	*memDB
+}
+
+func (s *memThreads) Get(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	thr := s.thread(slugOrId)
+	if thr == nil {
+		return nil, ErrNotFound
+	}
+
+	res := *thr
+	return &res, nil
+}
+
+func (s *memThreads) Deleted(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	thr := s.anyThread(slugOrId)
+	if thr == nil || !thr.IsDeleted {
+		return nil, ErrNotFound
+	}
+
+	res := *thr
+	return &res, nil
+}
+
+func (s *memThreads) Create(ctx context.Context, forumSlug string, thr *models.Thread) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Порядок проверок как в postgres: NOT NULL для forum, затем уникальность slug, затем внешний ключ author
+	forum := s.forum(forumSlug)
+	if forum == nil {
+		return ErrNotFound
+	}
+	if thr.Slug != "" {
+		if _, taken := s.threadSlugs[key(thr.Slug)]; taken {
+			return ErrConflict
+		}
+	}
+	if s.user(thr.Author) == nil {
+		return ErrNotFound
+	}
+
+	s.threadSeq++
+
+	thr.Id = s.threadSeq
+	thr.Forum = forum.Slug
+	thr.Votes = 0
+	thr.Posts, thr.LastPostAt, thr.LastPostAuthor = 0, nil, ""
+
+	stored := *thr
+	s.threads[thr.Id] = &stored
+	if thr.Slug != "" {
+		s.threadSlugs[key(thr.Slug)] = thr.Id
+	}
+
+	forum.Threads++
+	s.addForumUser(forum.Slug, thr.Author)
+
+	return nil
+}
+
+func (s *memThreads) Update(ctx context.Context, slugOrId string, update *models.Thread, editor string) (*models.Thread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	thr := s.thread(slugOrId)
+	if thr == nil {
+		return nil, ErrNotFound
+	}
+
+	rev := models.ThreadRevision{
+		Revision:        int32(len(s.threadRevisions[thr.Id]) + 1),
+		Editor:          editor,
+		Edited:          time.Now(),
+		PreviousTitle:   thr.Title,
+		Title:           thr.Title,
+		PreviousMessage: thr.Message,
+		Message:         thr.Message,
+	}
+
+	if update.Title != "" {
+		rev.Title = update.Title
+	}
+	if update.Message != "" {
+		rev.Message = update.Message
+	}
+
+	changedFields(&rev)
+
+	if len(rev.Changed) > 0 {
+		s.threadRevisions[thr.Id] = append(s.threadRevisions[thr.Id], rev)
+
+		edited := rev.Edited
+		thr.Title, thr.Message = rev.Title, rev.Message
+		thr.IsEdited, thr.Edited = true, &edited
+	}
+
+	res := *thr
+	return &res, nil
+}
+
+func (s *memThreads) History(ctx context.Context, slugOrId string) ([]models.ThreadRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	thr := s.thread(slugOrId)
+	if thr == nil {
+		return nil, ErrNotFound
+	}
+
+	revisions := make([]models.ThreadRevision, len(s.threadRevisions[thr.Id]))
+	copy(revisions, s.threadRevisions[thr.Id])
+
+	return revisions, nil
+}
+
+func (s *memThreads) Posts(ctx context.Context, thr *models.Thread, filter PostFilter) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.threadPosts[thr.Id]
+	selected := make([]*memPost, 0, len(ids))
+
+	var since *memPost
+	if filter.Since != 0 {
+		since = s.posts[filter.Since]
+		if since == nil && filter.Sort != SortFlat {
+			// Подзапрос по id_array вернул бы NULL, и ни одна строка не подошла бы
+			return []models.Post{}, nil
+		}
+	}
+
+	for _, id := range ids {
+		p := s.posts[id]
+
+		if p.post.IsDeleted && filter.Sort != SortTree && filter.Sort != SortParentTree {
+			continue
+		}
+
+		if filter.Since != 0 {
+			var cmp int
+			switch filter.Sort {
+			case SortTree:
+				cmp = comparePath(p.path, since.path)
+			case SortParentTree:
+				cmp = compareInt64(p.path[0], since.path[0])
+			default:
+				cmp = compareInt64(p.post.Id, filter.Since)
+			}
+
+			if !filter.Desc && cmp <= 0 || filter.Desc && cmp >= 0 {
+				continue
+			}
+		}
+
+		selected = append(selected, p)
+	}
+
+	switch filter.Sort {
+	case SortTree:
+		sort.Slice(selected, func(i, j int) bool {
+			if filter.Desc {
+				return comparePath(selected[i].path, selected[j].path) > 0
+			}
+			return comparePath(selected[i].path, selected[j].path) < 0
+		})
+
+		if filter.Limit > 0 && len(selected) > filter.Limit {
+			selected = selected[:filter.Limit]
+		}
+
+	case SortParentTree:
+		// Корневые ветки по направлению сортировки, внутри ветки - всегда по возрастанию
+		sort.Slice(selected, func(i, j int) bool {
+			a, b := selected[i].path, selected[j].path
+			if a[0] != b[0] {
+				if filter.Desc {
+					return a[0] > b[0]
+				}
+				return a[0] < b[0]
+			}
+			return comparePath(a, b) < 0
+		})
+
+		if filter.Limit > 0 {
+			roots := 0
+			for i, p := range selected {
+				if i == 0 || p.path[0] != selected[i-1].path[0] {
+					roots++
+				}
+				if roots > filter.Limit {
+					selected = selected[:i]
+					break
+				}
+			}
+		}
+
+	default:
+		sort.Slice(selected, func(i, j int) bool {
+			if filter.Desc {
+				return selected[i].post.Id > selected[j].post.Id
+			}
+			return selected[i].post.Id < selected[j].post.Id
+		})
+
+		if filter.Limit > 0 && len(selected) > filter.Limit {
+			selected = selected[:filter.Limit]
+		}
+	}
+
+	posts := make([]models.Post, 0, len(selected))
+	for _, p := range selected {
+		post := p.post
+		tombstone(&post)
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePath compares two id_array values the way postgres compares arrays.
+func comparePath(a, b []int64) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareInt64(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt64(int64(len(a)), int64(len(b)))
+}
+
+func (s *memThreads) Delete(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.setDeleted(s.thread(slugOrId), true)
+}
+
+func (s *memThreads) Restore(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	thr := s.anyThread(slugOrId)
+	if thr != nil && !thr.IsDeleted {
+		thr = nil
+	}
+
+	return s.setDeleted(thr, false)
+}
+
+// setDeleted changes the mark and the forum counters like trigger thread_delete.
+func (s *memThreads) setDeleted(thr *models.Thread, deleted bool) (*models.Thread, error) {
+	if thr == nil {
+		return nil, ErrNotFound
+	}
+
+	thr.IsDeleted = deleted
+
+	var live int64
+	for _, id := range s.threadPosts[thr.Id] {
+		if !s.posts[id].post.IsDeleted {
+			live++
+		}
+	}
+
+	forum := s.forum(thr.Forum)
+	if deleted {
+		forum.Threads--
+		forum.Posts -= live
+	} else {
+		forum.Threads++
+		forum.Posts += live
+	}
+
+	res := *thr
+	return &res, nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+func TestThreadDeleteCounters(t *testing.T) {
+	s := NewMemory()
+	ctx := context.Background()
+
+	if err := s.Users.Create(ctx, &models.User{NickName: "a", Email: "a@mail.ru", FullName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Forums.Create(ctx, &models.Forum{Slug: "f", Title: "f", User: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	thrs := make([]*models.Thread, 2)
+	for i := range thrs {
+		thrs[i] = &models.Thread{Author: "a", Title: "t", Message: "m"}
+		if err := s.Threads.Create(ctx, "f", thrs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	posts, err := s.Posts.Create(ctx, thrs[0], []models.Post{{Author: "a"}, {Author: "a"}, {Author: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Posts.Create(ctx, thrs[1], []models.Post{{Author: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Posts.Delete(ctx, posts[0].Id); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(step string, threads int32, posts int64) {
+		t.Helper()
+
+		forum, err := s.Forums.Get(ctx, "f")
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, err := s.Service.Status(ctx, StatusExact)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if forum.Threads != threads || forum.Posts != posts {
+			t.Errorf("%s: forum has %d threads and %d posts, want %d and %d", step, forum.Threads, forum.Posts, threads, posts)
+		}
+		if status.Thread != threads || status.Post != posts {
+			t.Errorf("%s: status has %d threads and %d posts, want %d and %d", step, status.Thread, status.Post, threads, posts)
+		}
+	}
+
+	check("created", 2, 3)
+
+	if _, err := s.Threads.Delete(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	check("thread deleted", 1, 1)
+
+	// Пост удаленной ветки уже не учитывается
+	if _, err := s.Posts.Delete(ctx, posts[1].Id); err != nil {
+		t.Fatal(err)
+	}
+	check("post of deleted thread deleted", 1, 1)
+
+	if _, err := s.Threads.Restore(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	check("thread restored", 2, 2)
+
+	if _, err := s.Posts.Restore(ctx, posts[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	check("post restored", 2, 3)
+}
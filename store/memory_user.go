@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type memUsers struct {
+	*memDB
+}
+
+func (s *memUsers) Get(ctx context.Context, nickname string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user := s.user(nickname)
+	if user == nil {
+		return nil, ErrNotFound
+	}
+
+	res := *user
+	return &res, nil
+}
+
+func (s *memUsers) Create(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.user(user.NickName) != nil {
+		return ErrConflict
+	}
+	if _, taken := s.emails[key(user.Email)]; taken {
+		return ErrConflict
+	}
+
+	stored := *user
+	stored.Password, stored.PasswordHash = "", ""
+	s.users[key(user.NickName)] = &stored
+	s.emails[key(user.Email)] = key(user.NickName)
+
+	if user.PasswordHash != "" {
+		s.passwords[key(user.NickName)] = user.PasswordHash
+	}
+
+	return nil
+}
+
+func (s *memUsers) Conflicting(ctx context.Context, nickname, email string) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]models.User, 0, 2)
+
+	byNick := s.user(nickname)
+	if byNick != nil {
+		users = append(users, *byNick)
+	}
+
+	if owner, ok := s.emails[key(email)]; ok {
+		byEmail := s.users[owner]
+		if byEmail != byNick {
+			users = append(users, *byEmail)
+		}
+	}
+
+	return users, nil
+}
+
+func (s *memUsers) Update(ctx context.Context, nickname string, update *models.User) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.user(nickname)
+	if user == nil {
+		return nil, ErrNotFound
+	}
+
+	if update.Email != "" && key(update.Email) != key(user.Email) {
+		if _, taken := s.emails[key(update.Email)]; taken {
+			return nil, ErrConflict
+		}
+
+		delete(s.emails, key(user.Email))
+		s.emails[key(update.Email)] = key(user.NickName)
+		user.Email = update.Email
+	}
+	if update.About != "" {
+		user.About = update.About
+	}
+	if update.FullName != "" {
+		user.FullName = update.FullName
+	}
+	if update.PasswordHash != "" {
+		s.passwords[key(user.NickName)] = update.PasswordHash
+	}
+
+	res := *user
+	return &res, nil
+}
+
+func (s *memUsers) PasswordHash(ctx context.Context, nickname string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.user(nickname) == nil {
+		return "", ErrNotFound
+	}
+
+	return s.passwords[key(nickname)], nil
+}
+
+func (s *memUsers) Posts(ctx context.Context, nickname string, filter ThreadFilter) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.user(nickname) == nil {
+		return nil, ErrNotFound
+	}
+
+	posts := make([]models.Post, 0)
+
+	for _, p := range s.posts {
+		post := p.post
+		if key(post.Author) != key(nickname) || post.IsDeleted || s.threads[post.Thread].IsDeleted {
+			continue
+		}
+		if !createdInWindow(post.Created, filter) {
+			continue
+		}
+		if filter.After != nil && !keyBefore(*filter.After, ThreadKey{Time: post.Created, Id: post.Id}, filter.Desc) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		a := ThreadKey{Time: posts[i].Created, Id: posts[i].Id}
+		return keyBefore(a, ThreadKey{Time: posts[j].Created, Id: posts[j].Id}, filter.Desc)
+	})
+
+	if filter.Limit > 0 && len(posts) > filter.Limit {
+		posts = posts[:filter.Limit]
+	}
+
+	return posts, nil
+}
+
+func (s *memUsers) Threads(ctx context.Context, nickname string, filter ThreadFilter) ([]models.Thread, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.user(nickname) == nil {
+		return nil, ErrNotFound
+	}
+
+	thrs := make([]models.Thread, 0)
+
+	for _, thr := range s.threads {
+		if key(thr.Author) != key(nickname) || thr.IsDeleted || !createdInWindow(thr.Created, filter) {
+			continue
+		}
+		if filter.After != nil && !keyBefore(*filter.After, threadSortKey(ThreadsByCreated, thr), filter.Desc) {
+			continue
+		}
+		thrs = append(thrs, *thr)
+	}
+
+	sort.Slice(thrs, func(i, j int) bool {
+		return keyBefore(threadSortKey(ThreadsByCreated, &thrs[i]), threadSortKey(ThreadsByCreated, &thrs[j]), filter.Desc)
+	})
+
+	if filter.Limit > 0 && len(thrs) > filter.Limit {
+		thrs = thrs[:filter.Limit]
+	}
+
+	return thrs, nil
+}
+
+func (s *memUsers) Stats(ctx context.Context, nickname string) (*models.UserStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.user(nickname) == nil {
+		return nil, ErrNotFound
+	}
+
+	stats := new(models.UserStats)
+
+	for _, p := range s.posts {
+		if key(p.post.Author) == key(nickname) && !p.post.IsDeleted && !s.threads[p.post.Thread].IsDeleted {
+			stats.Posts++
+		}
+	}
+	for _, thr := range s.threads {
+		if key(thr.Author) == key(nickname) && !thr.IsDeleted {
+			stats.Threads++
+			stats.Votes += int64(thr.Votes)
+		}
+	}
+	for _, users := range s.forumUsers {
+		if users[key(nickname)] {
+			stats.Forums++
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Grisha23/ForumsApi/config"
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/lib/pq"
+)
+
+const (
+	userColumns   = "about,email,fullname,nickname"
+	forumColumns  = "posts,slug,threads,title,author,category,parent,created"
+	threadColumns = "id,author,created,forum,message,slug,title,votes,isdeleted,isedited,edited,posts,last_post_at,last_post_author"
+	postColumns   = "author,created,forum,id,isedited,message,parent,thread,isdeleted"
+)
+
+// OpenPostgres connects to the database and configures the connection pool.
+func OpenPostgres(cfg config.DB) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.ConnString())
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func NewPostgres(db *sql.DB) *Store {
+	return &Store{
+		Users:      &pgUsers{db: db},
+		Forums:     &pgForums{db: db},
+		Categories: &pgCategories{db: db},
+		Threads:    &pgThreads{db: db},
+		Posts:      &pgPosts{db: db},
+		Votes:      &pgVotes{db: db},
+		Service:    &pgService{db: db},
+		Sessions:   &pgSessions{db: db},
+		Search:     &pgSearch{db: db},
+	}
+}
+
+// begin starts a transaction that doesn't wait for the WAL flush on commit.
+func begin(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
+	t, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("db.begin: %v", err)
+	}
+
+	_, err = traced(t).Exec(ctx, "SET LOCAL synchronous_commit TO OFF")
+	if err != nil {
+		t.Rollback()
+		return nil, fmt.Errorf("set local: %v", err)
+	}
+
+	return t, nil
+}
+
+// errorName returns the PostgreSQL condition name of err or "" for non pq errors.
+func errorName(err error) string {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code.Name()
+	}
+	return ""
+}
+
+// ErrorCode returns the SQLSTATE code of a PostgreSQL error or "" for other errors,
+// so callers can log it without depending on the driver.
+func ErrorCode(err error) string {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return string(pqErr.Code)
+	}
+	return ""
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner, user *models.User) error {
+	return row.Scan(userDest(user)...)
+}
+
+func scanForum(row scanner, forum *models.Forum) error {
+	var nulls forumNulls
+
+	if err := row.Scan(forumDest(forum, &nulls)...); err != nil {
+		return err
+	}
+
+	nulls.apply(forum)
+
+	return nil
+}
+
+// forumNulls receives the nullable columns of forums like threadNulls.
+type forumNulls struct {
+	category sql.NullString
+	parent   sql.NullString
+}
+
+func (n *forumNulls) apply(forum *models.Forum) {
+	forum.Category = n.category.String
+	forum.Parent = n.parent.String
+}
+
+func scanThread(row scanner, thr *models.Thread) error {
+	var nulls threadNulls
+
+	if err := row.Scan(threadDest(thr, &nulls)...); err != nil {
+		return err
+	}
+
+	nulls.apply(thr)
+
+	return nil
+}
+
+// threadNulls receives the nullable text columns of threads, apply copies them to the model.
+type threadNulls struct {
+	slug           sql.NullString
+	lastPostAuthor sql.NullString
+}
+
+func (n *threadNulls) apply(thr *models.Thread) {
+	thr.Slug = n.slug.String
+	thr.LastPostAuthor = n.lastPostAuthor.String
+}
+
+func scanPost(row scanner, post *models.Post) error {
+	return row.Scan(postDest(post)...)
+}
+
+// *Dest return scan destinations in the order of the matching *Columns constant,
+// so a joined row can be scanned into several models at once.
+
+func userDest(user *models.User) []interface{} {
+	return []interface{}{&user.About, &user.Email, &user.FullName, &user.NickName}
+}
+
+func forumDest(forum *models.Forum, nulls *forumNulls) []interface{} {
+	return []interface{}{&forum.Posts, &forum.Slug, &forum.Threads, &forum.Title, &forum.User, &nulls.category, &nulls.parent,
+		&forum.Created}
+}
+
+func threadDest(thr *models.Thread, nulls *threadNulls) []interface{} {
+	return []interface{}{&thr.Id, &thr.Author, &thr.Created, &thr.Forum, &thr.Message, &nulls.slug, &thr.Title, &thr.Votes, &thr.IsDeleted,
+		&thr.IsEdited, &thr.Edited, &thr.Posts, &thr.LastPostAt, &nulls.lastPostAuthor}
+}
+
+func postDest(post *models.Post) []interface{} {
+	return []interface{}{&post.Author, &post.Created, &post.Forum, &post.Id, &post.IsEdited, &post.Message,
+		&post.Parent, &post.Thread, &post.IsDeleted}
+}
+
+// prefixColumns turns "a,b" into "p.a,p.b".
+func prefixColumns(prefix, columns string) string {
+	return prefix + "." + strings.Replace(columns, ",", ","+prefix+".", -1)
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+const categoryColumns = "slug,title,description,position"
+
+type pgCategories struct {
+	db *sql.DB
+}
+
+func scanCategory(row scanner, category *models.Category) error {
+	return row.Scan(&category.Slug, &category.Title, &category.Description, &category.Position)
+}
+
+func (s *pgCategories) Get(ctx context.Context, slug string) (*models.Category, error) {
+	category := new(models.Category)
+
+	err := scanCategory(traced(s.db).QueryRow(ctx, "SELECT "+categoryColumns+" FROM categories WHERE slug=$1", slug), category)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+func (s *pgCategories) Create(ctx context.Context, category *models.Category) error {
+	row := traced(s.db).QueryRow(ctx, "INSERT INTO categories(slug, title, description, position) VALUES ($1, $2, $3, $4) "+
+		"RETURNING "+categoryColumns, category.Slug, category.Title, category.Description, category.Position)
+
+	err := scanCategory(row, category)
+	if errorName(err) == "unique_violation" {
+		return ErrConflict
+	}
+
+	return err
+}
+
+func (s *pgCategories) Update(ctx context.Context, slug string, update *models.CategoryUpdate) (*models.Category, error) {
+	q := newQuery("UPDATE categories")
+
+	if update.Title != "" {
+		q.set("title", update.Title)
+	}
+	if update.Description != "" {
+		q.set("description", update.Description)
+	}
+	if update.Position != nil {
+		q.set("position", *update.Position)
+	}
+
+	if !q.hasSet {
+		return s.Get(ctx, slug)
+	}
+
+	query, args, err := q.where("slug = ?", slug).add(" RETURNING " + categoryColumns).build()
+	if err != nil {
+		return nil, err
+	}
+
+	category := new(models.Category)
+
+	err = scanCategory(traced(s.db).QueryRow(ctx, query, args...), category)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+func (s *pgCategories) Delete(ctx context.Context, slug string) error {
+	res, err := traced(s.db).Exec(ctx, "DELETE FROM categories WHERE slug=$1", slug)
+	if errorName(err) == "foreign_key_violation" {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *pgCategories) List(ctx context.Context) ([]models.Category, error) {
+	rows, err := traced(s.db).Query(ctx, "SELECT "+categoryColumns+" FROM categories ORDER BY position, slug")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	categories := make([]models.Category, 0)
+
+	for rows.Next() {
+		category := models.Category{}
+
+		if err := scanCategory(rows, &category); err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
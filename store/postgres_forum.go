@@ -0,0 +1,287 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type pgForums struct {
+	db *sql.DB
+}
+
+func (s *pgForums) Get(ctx context.Context, slug string) (*models.Forum, error) {
+	forum := models.Forum{}
+
+	err := scanForum(traced(s.db).QueryRow(ctx, "SELECT "+forumColumns+" FROM forums WHERE slug=$1", slug), &forum)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &forum, nil
+}
+
+func (s *pgForums) Create(ctx context.Context, forum *models.Forum) error {
+	t, err := begin(ctx, s.db)
+	if err != nil {
+		return err
+	}
+
+	defer t.Rollback()
+
+	row := traced(t).QueryRow(ctx, "INSERT INTO forums(slug, title, author, category, parent) VALUES ($1, $2, "+
+		"(SELECT nickname FROM users WHERE nickname=$3), NULLIF($4, ''), NULLIF($5, '')) RETURNING "+forumColumns,
+		forum.Slug, forum.Title, forum.User, forum.Category, forum.Parent)
+
+	err = scanForum(row, forum)
+	if err != nil {
+		switch errorName(err) {
+		case "foreign_key_violation", "not_null_violation":
+			return ErrNotFound
+		case "unique_violation":
+			return ErrConflict
+		}
+		return err
+	}
+
+	return t.Commit()
+}
+
+func (s *pgForums) Users(ctx context.Context, slug string, filter UserFilter) ([]models.User, error) {
+	if _, err := s.Get(ctx, slug); err != nil {
+		return nil, err
+	}
+
+	dir := sortDirection(filter.Desc)
+
+	q := newQuery("SELECT " + prefixColumns("u", userColumns) + " FROM forum_users f_u JOIN users u ON f_u.author=u.nickname")
+	q.where("f_u.forum = ?", slug)
+
+	if filter.Since != "" {
+		if filter.Desc {
+			q.where("u.nickname < ?", filter.Since)
+		} else {
+			q.where("u.nickname > ?", filter.Since)
+		}
+	}
+
+	query, args, err := q.orderBy(dir, "u.nickname").limit(filter.Limit).build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(s.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+
+	for rows.Next() {
+		usr := models.User{}
+
+		if err := scanUser(rows, &usr); err != nil {
+			return nil, err
+		}
+
+		users = append(users, usr)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *pgForums) Threads(ctx context.Context, slug string, filter ThreadFilter) ([]models.Thread, error) {
+	query, args, err := forumThreadsQuery(slug, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(s.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	thrs := make([]models.Thread, 0)
+
+	for rows.Next() {
+		thr := models.Thread{}
+
+		if err := scanThread(rows, &thr); err != nil {
+			return nil, err
+		}
+
+		thrs = append(thrs, thr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Пустой список - проверяем, существует ли форум
+	if len(thrs) == 0 {
+		if _, err := s.Get(ctx, slug); err != nil {
+			return nil, err
+		}
+	}
+
+	return thrs, nil
+}
+
+func (s *pgForums) Family(ctx context.Context, slug string) ([]models.Forum, []models.Forum, error) {
+	forum, err := s.Get(ctx, slug)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ancestors := make([]models.Forum, 0)
+
+	// Глубина растет вверх по иерархии, корень - последний
+	if forum.Parent != "" {
+		ancestors, err = s.list(ctx, "WITH RECURSIVE up AS ("+
+			" SELECT "+forumColumns+", 1 AS depth FROM forums WHERE slug=$1"+
+			" UNION ALL SELECT "+prefixColumns("f", forumColumns)+", up.depth+1 FROM forums f JOIN up ON f.slug=up.parent"+
+			") SELECT "+forumColumns+" FROM up ORDER BY depth DESC", forum.Parent)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	descendants, err := s.list(ctx, "WITH RECURSIVE down AS ("+
+		" SELECT "+forumColumns+" FROM forums WHERE parent=$1"+
+		" UNION ALL SELECT "+prefixColumns("f", forumColumns)+" FROM forums f JOIN down ON f.parent=down.slug"+
+		") SELECT "+forumColumns+" FROM down ORDER BY slug", forum.Slug)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ancestors, descendants, nil
+}
+
+// forumThreadsQuery builds the statement of pgForums.Threads.
+func forumThreadsQuery(slug string, filter ThreadFilter) (string, []interface{}, error) {
+	dir := sortDirection(filter.Desc)
+
+	q := newQuery("SELECT " + prefixColumns("t", threadColumns) + " FROM threads t")
+	q.where("t.forum = ?", slug).where("NOT t.isdeleted")
+
+	if filter.Author != "" {
+		q.where("t.author = ?", filter.Author)
+	}
+
+	createdWindow(q, "t.", filter)
+
+	var column string
+	var value interface{}
+
+	switch filter.Sort {
+	case ThreadsByVotes:
+		column = "t.votes"
+	case ThreadsByActivity:
+		column = "COALESCE(t.last_post_at, t.created)"
+	case ThreadsByPosts:
+		column = "t.posts"
+	default:
+		column = "t.created"
+	}
+
+	if filter.After != nil {
+		value = filter.After.Time
+		if filter.Sort == ThreadsByVotes || filter.Sort == ThreadsByPosts {
+			value = filter.After.Number
+		}
+
+		afterKey(q, column, "t.id", value, filter)
+	}
+
+	return q.orderBy(dir, column, "t.id").limit(filter.Limit).build()
+}
+
+func (s *pgForums) List(ctx context.Context, filter ForumFilter) ([]models.Forum, error) {
+	query, args, err := forumListQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.list(ctx, query, args...)
+}
+
+// forumListQuery builds the statement of pgForums.List.
+func forumListQuery(filter ForumFilter) (string, []interface{}, error) {
+	q := newQuery("SELECT " + forumColumns + " FROM forums")
+
+	if filter.Owner != "" {
+		q.where("author = ?", filter.Owner)
+	}
+	if filter.Query != "" {
+		q.where("title ILIKE ?", "%"+likeEscaper.Replace(filter.Query)+"%")
+	}
+
+	// Имена сортировок совпадают с колонками, в запрос попадают только известные
+	column := ForumsByTitle
+	switch filter.Sort {
+	case ForumsByCreated, ForumsByPosts, ForumsByThreads:
+		column = filter.Sort
+	}
+
+	if filter.After != nil {
+		var value interface{}
+
+		switch column {
+		case ForumsByTitle:
+			value = filter.After.Title
+		case ForumsByCreated:
+			value = filter.After.Created
+		default:
+			value = filter.After.Number
+		}
+
+		after := " > "
+		if filter.Desc {
+			after = " < "
+		}
+
+		q.where("("+column+", slug)"+after+"(?, ?)", value, filter.After.Slug)
+	}
+
+	return q.orderBy(sortDirection(filter.Desc), column, "slug").limit(filter.Limit).build()
+}
+
+// likeEscaper makes user input match literally in LIKE with the default escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (s *pgForums) All(ctx context.Context) ([]models.Forum, error) {
+	return s.list(ctx, "SELECT "+forumColumns+" FROM forums ORDER BY slug")
+}
+
+// list runs a query returning forumColumns.
+func (s *pgForums) list(ctx context.Context, query string, args ...interface{}) ([]models.Forum, error) {
+	rows, err := traced(s.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	forums := make([]models.Forum, 0)
+
+	for rows.Next() {
+		forum := models.Forum{}
+
+		if err := scanForum(rows, &forum); err != nil {
+			return nil, err
+		}
+
+		forums = append(forums, forum)
+	}
+
+	return forums, rows.Err()
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestForumThreadsQuery(t *testing.T) {
+	since := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	until := since.Add(time.Hour)
+
+	selectThreads := "SELECT " + prefixColumns("t", threadColumns) + " FROM threads t WHERE t.forum = $1 AND NOT t.isdeleted"
+
+	cases := []struct {
+		filter ThreadFilter
+		where  string
+		order  string
+		args   []interface{}
+	}{
+		{
+			ThreadFilter{},
+			"", " ORDER BY t.created ASC, t.id ASC",
+			[]interface{}{"f"},
+		},
+		{
+			ThreadFilter{Limit: 3, Desc: true},
+			"", " ORDER BY t.created DESC, t.id DESC LIMIT $2",
+			[]interface{}{"f", 3},
+		},
+		{
+			ThreadFilter{Since: since, Until: until, Desc: true},
+			" AND t.created >= $2 AND t.created <= $3", " ORDER BY t.created DESC, t.id DESC",
+			[]interface{}{"f", since, until},
+		},
+		{
+			ThreadFilter{Until: until, Author: "a", Sort: ThreadsByVotes},
+			" AND t.author = $2 AND t.created <= $3", " ORDER BY t.votes ASC, t.id ASC",
+			[]interface{}{"f", "a", until},
+		},
+		{
+			ThreadFilter{Limit: 2, Since: since, Sort: ThreadsByVotes, Desc: true, After: &ThreadKey{Number: -1, Id: 7}},
+			" AND t.created >= $2 AND (t.votes, t.id) < ($3, $4)", " ORDER BY t.votes DESC, t.id DESC LIMIT $5",
+			[]interface{}{"f", since, int64(-1), int64(7), 2},
+		},
+		{
+			ThreadFilter{Sort: ThreadsByPosts, After: &ThreadKey{Number: 4, Id: 2}},
+			" AND (t.posts, t.id) > ($2, $3)", " ORDER BY t.posts ASC, t.id ASC",
+			[]interface{}{"f", int64(4), int64(2)},
+		},
+		{
+			ThreadFilter{Limit: 1, Author: "b", Sort: ThreadsByActivity, Desc: true, After: &ThreadKey{Time: since, Id: 3}},
+			" AND t.author = $2 AND (COALESCE(t.last_post_at, t.created), t.id) < ($3, $4)",
+			" ORDER BY COALESCE(t.last_post_at, t.created) DESC, t.id DESC LIMIT $5",
+			[]interface{}{"f", "b", since, int64(3), 1},
+		},
+		{
+			ThreadFilter{Sort: ThreadsByCreated, After: &ThreadKey{Time: since, Id: 5}},
+			" AND (t.created, t.id) > ($2, $3)", " ORDER BY t.created ASC, t.id ASC",
+			[]interface{}{"f", since, int64(5)},
+		},
+	}
+
+	for _, c := range cases {
+		query, args, err := forumThreadsQuery("f", c.filter)
+		if err != nil {
+			t.Fatalf("%+v: %v", c.filter, err)
+		}
+
+		if want := selectThreads + c.where + c.order; query != want {
+			t.Errorf("%+v:\n got %s\nwant %s", c.filter, query, want)
+		}
+		if !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%+v: args\n got %#v\nwant %#v", c.filter, args, c.args)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/lib/pq"
+)
+
+type pgPosts struct {
+	db *sql.DB
+}
+
+func (s *pgPosts) Get(ctx context.Context, id int64) (*models.Post, error) {
+	post := new(models.Post)
+
+	err := scanPost(traced(s.db).QueryRow(ctx, "SELECT "+postColumns+" FROM posts WHERE id=$1", id), post)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (s *pgPosts) Details(ctx context.Context, id int64, related Related) (*models.PostDetail, error) {
+	postDetail := new(models.PostDetail)
+	postDetail.Post = new(models.Post)
+
+	columns := prefixColumns("p", postColumns)
+	joins := ""
+	dest := postDest(postDetail.Post)
+
+	var thrNulls threadNulls
+	var frmNulls forumNulls
+
+	if related.User {
+		postDetail.Author = new(models.User)
+		columns += ", " + prefixColumns("u", userColumns)
+		joins += " JOIN users u ON u.nickname=p.author"
+		dest = append(dest, userDest(postDetail.Author)...)
+	}
+	if related.Thread {
+		postDetail.Thread = new(models.Thread)
+		columns += ", " + prefixColumns("t", threadColumns)
+		joins += " JOIN threads t ON p.thread=t.id"
+		dest = append(dest, threadDest(postDetail.Thread, &thrNulls)...)
+	}
+	if related.Forum {
+		postDetail.Forum = new(models.Forum)
+		columns += ", " + prefixColumns("f", forumColumns)
+		joins += " JOIN forums f ON p.forum=f.slug"
+		dest = append(dest, forumDest(postDetail.Forum, &frmNulls)...)
+	}
+
+	query := "SELECT " + columns + " FROM posts p" + joins + " WHERE p.id=$1"
+
+	err := traced(s.db).QueryRow(ctx, query, id).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if postDetail.Thread != nil {
+		thrNulls.apply(postDetail.Thread)
+	}
+	if postDetail.Forum != nil {
+		frmNulls.apply(postDetail.Forum)
+	}
+
+	return postDetail, nil
+}
+
+func (s *pgPosts) Create(ctx context.Context, thr *models.Thread, posts []models.Post) ([]models.Post, error) {
+	t, err := begin(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	defer t.Rollback()
+
+	authors := make([]string, 0, len(posts))
+	messages := make([]string, 0, len(posts))
+	parents := make([]int64, 0, len(posts))
+
+	for _, p := range posts {
+		authors = append(authors, p.Author)
+		messages = append(messages, p.Message)
+		parents = append(parents, p.Parent)
+	}
+
+	// Вся пачка и forum_users вставляются одним запросом, значения передаются массивами.
+	// ORDER BY ord сохраняет порядок постов из запроса, id выдаются в том же порядке.
+	query := "WITH input AS (" +
+		" SELECT * FROM unnest($1::citext[], $2::text[], $3::bigint[]) WITH ORDINALITY AS i(author, message, parent, ord)" +
+		"), ins AS (" +
+		" INSERT INTO posts(author, forum, message, parent, thread)" +
+		" SELECT author, $4, message, parent, $5 FROM input ORDER BY ord" +
+		" RETURNING " + postColumns +
+		"), users AS (" +
+		" INSERT INTO forum_users(forum, author) SELECT DISTINCT forum, author FROM ins ON CONFLICT DO NOTHING" +
+		") SELECT " + postColumns + " FROM ins ORDER BY id"
+
+	rows, err := traced(t).Query(ctx, query, pq.Array(authors), pq.Array(messages), pq.Array(parents), thr.Forum, thr.Id)
+	if err != nil {
+		return nil, postCreateError(err)
+	}
+
+	data := make([]models.Post, 0, len(posts))
+
+	for rows.Next() {
+		newPost := models.Post{}
+
+		if err := scanPost(rows, &newPost); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		data = append(data, newPost)
+	}
+
+	rows.Close()
+
+	// Ошибка триггера может прийти и во время чтения строк
+	if err := rows.Err(); err != nil {
+		return nil, postCreateError(err)
+	}
+
+	return data, t.Commit()
+}
+
+func postCreateError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Message == "Parent post exc" {
+		return ErrParentConflict
+	}
+
+	if errorName(err) == "foreign_key_violation" {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (s *pgPosts) Update(ctx context.Context, id int64, message, editor string) (*models.Post, error) {
+	if message == "" {
+		return s.Get(ctx, id)
+	}
+
+	t, err := begin(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	defer t.Rollback()
+
+	// Блокировка строки поста упорядочивает номера правок
+	var previous string
+
+	err = traced(t).QueryRow(ctx, "SELECT message FROM posts WHERE id=$1 AND NOT isdeleted FOR UPDATE", id).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != message {
+		_, err = traced(t).Exec(ctx, "INSERT INTO post_revisions(post, revision, editor, message) "+
+			"VALUES ($1, (SELECT COALESCE(max(revision), 0) + 1 FROM post_revisions WHERE post=$1), NULLIF($2, ''), $3)",
+			id, editor, previous)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	post := new(models.Post)
+
+	row := traced(t).QueryRow(ctx, "UPDATE posts SET message=$1, isedited=true WHERE id=$2 RETURNING "+postColumns, message, id)
+
+	if err := scanPost(row, post); err != nil {
+		return nil, err
+	}
+
+	return post, t.Commit()
+}
+
+func (s *pgPosts) History(ctx context.Context, id int64) ([]models.PostRevision, error) {
+	// Текст после правки - текст до следующей правки, для последней - текущий текст поста
+	query := "SELECT r.revision, r.editor, r.edited, r.message, " +
+		"COALESCE(lead(r.message) OVER (ORDER BY r.revision), p.message) " +
+		"FROM post_revisions r JOIN posts p ON p.id=r.post WHERE r.post=$1 ORDER BY r.revision"
+
+	rows, err := traced(s.db).Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := make([]models.PostRevision, 0)
+
+	for rows.Next() {
+		rev := models.PostRevision{}
+		var editor sql.NullString
+
+		if err := rows.Scan(&rev.Revision, &editor, &rev.Edited, &rev.Previous, &rev.Message); err != nil {
+			return nil, err
+		}
+
+		rev.Editor = editor.String
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Пустая история - проверяем, существует ли пост
+	if len(revisions) == 0 {
+		if _, err := s.Get(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return revisions, nil
+}
+
+func (s *pgPosts) Delete(ctx context.Context, id int64) (*models.Post, error) {
+	return s.setDeleted(ctx, "UPDATE posts SET isdeleted=true WHERE id=$1 AND NOT isdeleted RETURNING "+postColumns, id)
+}
+
+func (s *pgPosts) Restore(ctx context.Context, id int64) (*models.Post, error) {
+	return s.setDeleted(ctx, "UPDATE posts SET isdeleted=false WHERE id=$1 AND isdeleted RETURNING "+postColumns, id)
+}
+
+// setDeleted runs one of the mark updates, trigger post_delete adjusts the counters.
+func (s *pgPosts) setDeleted(ctx context.Context, query string, id int64) (*models.Post, error) {
+	post := new(models.Post)
+
+	err := scanPost(traced(s.db).QueryRow(ctx, query, id), post)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Grisha23/ForumsApi/models"
+	"github.com/lib/pq"
+)
+
+const roleColumns = "nickname,role,granted_by,created"
+
+func (s *pgForums) Role(ctx context.Context, slug, nickname string) (string, error) {
+	var role string
+
+	err := traced(s.db).QueryRow(ctx, "SELECT role FROM forum_roles WHERE forum=$1 AND nickname=$2", slug, nickname).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+func (s *pgForums) Banned(ctx context.Context, slug string, nicknames []string) (string, error) {
+	var nickname string
+
+	err := traced(s.db).QueryRow(ctx, "SELECT nickname FROM forum_roles WHERE forum=$1 AND role=$2 AND nickname = ANY($3::citext[]) LIMIT 1",
+		slug, RoleBanned, pq.Array(nicknames)).Scan(&nickname)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return nickname, nil
+}
+
+func (s *pgForums) Roles(ctx context.Context, slug, role string) ([]models.ForumRole, error) {
+	if _, err := s.Get(ctx, slug); err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(s.db).Query(ctx, "SELECT "+roleColumns+" FROM forum_roles WHERE forum=$1 AND role=$2 ORDER BY nickname", slug, role)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	roles := make([]models.ForumRole, 0)
+
+	for rows.Next() {
+		fr := models.ForumRole{}
+
+		if err := rows.Scan(&fr.Nickname, &fr.Role, &fr.GrantedBy, &fr.Created); err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, fr)
+	}
+
+	return roles, rows.Err()
+}
+
+func (s *pgForums) SetRole(ctx context.Context, slug string, role *models.ForumRole) error {
+	row := traced(s.db).QueryRow(ctx, "INSERT INTO forum_roles(forum, nickname, role, granted_by) VALUES ("+
+		"(SELECT slug FROM forums WHERE slug=$1), (SELECT nickname FROM users WHERE nickname=$2), $3, $4) "+
+		"ON CONFLICT (forum, nickname) DO UPDATE SET role=EXCLUDED.role, granted_by=EXCLUDED.granted_by, created=current_timestamp "+
+		"RETURNING "+roleColumns, slug, role.Nickname, role.Role, role.GrantedBy)
+
+	err := row.Scan(&role.Nickname, &role.Role, &role.GrantedBy, &role.Created)
+	if err != nil {
+		if errorName(err) == "not_null_violation" {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *pgForums) DeleteRole(ctx context.Context, slug, nickname, role string) error {
+	res, err := traced(s.db).Exec(ctx, "DELETE FROM forum_roles WHERE forum=$1 AND nickname=$2 AND role=$3", slug, nickname, role)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+// headlineOptions make ts_headline mark found words like the in-memory search does.
+const headlineOptions = "StartSel=<b>, StopSel=</b>, MaxWords=35, MinWords=15"
+
+// htmlEscapes are the replacements of html.EscapeString, & goes first.
+var htmlEscapes = [][2]string{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&#34;"}, {"'", "&#39;"}}
+
+// htmlEscaped is the SQL expression of column escaped like html.EscapeString: ts_headline
+// only adds the markers, the text of a post must not become markup of the snippet.
+func htmlEscaped(column string) string {
+	for _, r := range htmlEscapes {
+		column = "replace(" + column + ", '" + strings.Replace(r[0], "'", "''", -1) + "', '" + r[1] + "')"
+	}
+	return column
+}
+
+type pgSearch struct {
+	db *sql.DB
+}
+
+func (s *pgSearch) Search(ctx context.Context, filter SearchFilter) ([]models.SearchHit, error) {
+	posts := newQuery("SELECT 'post' AS kind, p.id, p.thread, p.forum, p.author, p.created, "+
+		"ts_rank(p.search, q) AS rank, p.message AS body "+
+		"FROM posts p JOIN threads t ON t.id=p.thread, plainto_tsquery('simple', ?) q", filter.Query)
+	posts.where("p.search @@ q").where("NOT p.isdeleted").where("NOT t.isdeleted")
+	searchConditions(posts, "p.", filter)
+
+	threads := newQuery("SELECT 'thread', t.id::bigint, t.id, t.forum, t.author, t.created, "+
+		"ts_rank(t.search, q), t.title || E'\\n' || t.message "+
+		"FROM threads t, plainto_tsquery('simple', ?) q", filter.Query)
+	threads.where("t.search @@ q").where("NOT t.isdeleted")
+	searchConditions(threads, "t.", filter)
+
+	hits := newQuery("SELECT * FROM (")
+
+	switch filter.Kind {
+	case SearchPost:
+		hits.sub(posts)
+	case SearchThread:
+		hits.sub(threads)
+	default:
+		hits.sub(posts).add(" UNION ALL ").sub(threads)
+	}
+
+	hits.add(") h")
+
+	if filter.After != nil {
+		hits.where("(rank, kind, id) < (?::real, ?, ?)", filter.After.Rank, filter.After.Kind, filter.After.Id)
+	}
+
+	hits.orderBy(descending, "rank", "kind", "id").limit(filter.Limit)
+
+	// ts_headline дорогой, считаем его только для страницы результатов
+	q := newQuery("SELECT kind, id, thread, forum, author, created, rank, "+
+		"ts_headline('simple', "+htmlEscaped("body")+", plainto_tsquery('simple', ?), '"+headlineOptions+"') FROM (", filter.Query)
+	q.sub(hits).add(") h").orderBy(descending, "rank", "kind", "id")
+
+	query, args, err := q.build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(s.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	found := make([]models.SearchHit, 0)
+
+	for rows.Next() {
+		hit := models.SearchHit{}
+
+		err := rows.Scan(&hit.Kind, &hit.Id, &hit.Thread, &hit.Forum, &hit.Author, &hit.Created, &hit.Rank, &hit.Snippet)
+		if err != nil {
+			return nil, err
+		}
+
+		found = append(found, hit)
+	}
+
+	return found, rows.Err()
+}
+
+// searchConditions adds the filters shared by the posts and threads parts, prefix is the table alias with a dot.
+func searchConditions(q *query, prefix string, filter SearchFilter) {
+	if filter.Forum != "" {
+		q.where(prefix+"forum = ?", filter.Forum)
+	}
+	if filter.Author != "" {
+		q.where(prefix+"author = ?", filter.Author)
+	}
+	if !filter.Since.IsZero() {
+		q.where(prefix+"created >= ?", filter.Since)
+	}
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/Grisha23/ForumsApi/migrations"
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type pgVotes struct {
+	db *sql.DB
+}
+
+func (s *pgVotes) Vote(ctx context.Context, slugOrId string, vote *models.Vote) (*models.Thread, error) {
+	t, err := begin(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	defer t.Rollback()
+
+	thrId, err := strconv.Atoi(slugOrId)
+
+	if err != nil {
+		_, err = traced(t).Exec(ctx, "INSERT INTO votes(nickname, voice, thread) VALUES ($1,$2, (SELECT id FROM threads WHERE slug=$3 AND NOT isdeleted)) "+
+			"ON CONFLICT (nickname, thread) DO "+
+			"UPDATE SET voice=$2",
+			vote.Nickname, vote.Voice, slugOrId)
+	} else {
+		_, err = traced(t).Exec(ctx, "INSERT INTO votes(nickname, voice, thread) VALUES ($1,$2, (SELECT id FROM threads WHERE id=$3 AND NOT isdeleted)) "+
+			"ON CONFLICT (nickname, thread) DO "+
+			"UPDATE SET voice=$2",
+			vote.Nickname, vote.Voice, thrId)
+	}
+
+	if err != nil {
+		switch errorName(err) {
+		case "foreign_key_violation", "not_null_violation":
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := t.Commit(); err != nil {
+		return nil, err
+	}
+
+	return (&pgThreads{db: s.db}).Get(ctx, slugOrId)
+}
+
+type pgService struct {
+	db *sql.DB
+}
+
+func (s *pgService) Status(ctx context.Context, mode string) (*models.Status, error) {
+	var row *sql.Row
+
+	switch mode {
+	case StatusCounters:
+		row = traced(s.db).QueryRow(ctx, "SELECT "+
+			"coalesce(sum(value) FILTER (WHERE name = 'users'), 0), "+
+			"coalesce(sum(value) FILTER (WHERE name = 'forums'), 0), "+
+			"coalesce(sum(value) FILTER (WHERE name = 'posts'), 0), "+
+			"coalesce(sum(value) FILTER (WHERE name = 'threads'), 0) "+
+			"FROM service_counters")
+	case StatusEstimate:
+		// reltuples обновляется VACUUM/ANALYZE, для пустой или не анализированной таблицы может быть -1
+		row = traced(s.db).QueryRow(ctx, "SELECT "+
+			"(SELECT GREATEST(reltuples, 0)::bigint FROM pg_class WHERE oid = 'users'::regclass), "+
+			"(SELECT GREATEST(reltuples, 0)::bigint FROM pg_class WHERE oid = 'forums'::regclass), "+
+			"(SELECT GREATEST(reltuples, 0)::bigint FROM pg_class WHERE oid = 'posts'::regclass), "+
+			"(SELECT GREATEST(reltuples, 0)::bigint FROM pg_class WHERE oid = 'threads'::regclass)")
+	default:
+		row = traced(s.db).QueryRow(ctx, "SELECT t1.cnt c1, t2.cnt c2, t3.cnt c3, t4.cnt c4 FROM (SELECT count(*) cnt FROM users) t1, (SELECT COUNT(*) cnt FROM forums) t2, (SELECT COUNT(*) cnt FROM posts p JOIN threads t ON t.id = p.thread WHERE NOT p.isdeleted AND NOT t.isdeleted) t3, (SELECT COUNT(*) cnt FROM threads WHERE NOT isdeleted) t4")
+	}
+
+	status := models.Status{}
+
+	err := row.Scan(&status.User, &status.Forum, &status.Post, &status.Thread)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+func (s *pgService) Clear(ctx context.Context) error {
+	_, err := traced(s.db).Exec(ctx, "TRUNCATE TABLE votes, sessions, forum_roles, post_revisions, thread_revisions, users, posts, threads, forums, categories, forum_users, service_counters")
+	return err
+}
+
+// reconcileLockKey is the pg_advisory_lock key held during Reconcile, so replicas don't
+// apply the same correction twice.
+const reconcileLockKey = 7235116131
+
+func (s *pgService) Reconcile(ctx context.Context) (map[string]int64, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	if _, err := traced(conn).Exec(ctx, "SELECT pg_advisory_lock($1)", reconcileLockKey); err != nil {
+		return nil, err
+	}
+
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", reconcileLockKey)
+
+	// Строки и счетчики читаются в одном снимке, поэтому разница - это именно дрейф,
+	// а не вставки, которые идут параллельно: их учтут их же триггеры. Снимок берется
+	// под блокировкой, то есть после поправок предыдущего Reconcile любой реплики.
+	t, err := conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	defer t.Rollback()
+
+	rows, err := traced(t).Query(ctx, "SELECT c.name, c.actual - coalesce((SELECT sum(value) FROM service_counters WHERE name = c.name), 0) FROM ("+
+		"SELECT 'users' AS name, count(*) AS actual FROM users "+
+		"UNION ALL SELECT 'forums', count(*) FROM forums "+
+		"UNION ALL SELECT 'threads', count(*) FROM threads WHERE NOT isdeleted "+
+		"UNION ALL SELECT 'posts', count(*) FROM posts p JOIN threads t ON t.id = p.thread WHERE NOT p.isdeleted AND NOT t.isdeleted) c")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	drift := make(map[string]int64)
+
+	for rows.Next() {
+		var name string
+		var delta int64
+
+		if err := rows.Scan(&name, &delta); err != nil {
+			return nil, err
+		}
+
+		if delta != 0 {
+			drift[name] = delta
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	t.Rollback()
+
+	for name, delta := range drift {
+		_, err := traced(conn).Exec(ctx, "INSERT INTO service_counters(name, shard, value) VALUES ($1, 0, $2) "+
+			"ON CONFLICT (name, shard) DO UPDATE SET value = service_counters.value + EXCLUDED.value", name, delta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return drift, nil
+}
+
+func (s *pgService) Health(ctx context.Context) (*Health, error) {
+	if err := s.db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	version, err := migrations.Current(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := s.db.Stats()
+
+	return &Health{
+		SchemaVersion: version,
+		LatestVersion: migrations.Latest(),
+		InUse:         stats.InUse,
+		MaxOpen:       stats.MaxOpenConnections,
+	}, nil
+}
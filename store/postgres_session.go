@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type pgSessions struct {
+	db *sql.DB
+}
+
+func (s *pgSessions) Create(ctx context.Context, session *Session) error {
+	t, err := begin(ctx, s.db)
+	if err != nil {
+		return err
+	}
+
+	defer t.Rollback()
+
+	// Заодно убираем истекшие сессии этого пользователя, чтобы таблица не росла
+	_, err = traced(t).Exec(ctx, "DELETE FROM sessions WHERE nickname=$1 AND expires < now()", session.Nickname)
+	if err != nil {
+		return err
+	}
+
+	err = traced(t).QueryRow(ctx, "INSERT INTO sessions(id, nickname, kind, expires) VALUES ($1, "+
+		"(SELECT nickname FROM users WHERE nickname=$2), $3, $4) RETURNING nickname, created",
+		session.Id, session.Nickname, session.Kind, session.Expires).Scan(&session.Nickname, &session.Created)
+	if err != nil {
+		if errorName(err) == "not_null_violation" {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return t.Commit()
+}
+
+func (s *pgSessions) Get(ctx context.Context, id string) (*Session, error) {
+	session := new(Session)
+
+	err := traced(s.db).QueryRow(ctx, "SELECT id, nickname, kind, created, expires FROM sessions WHERE id=$1 AND expires > now()", id).
+		Scan(&session.Id, &session.Nickname, &session.Kind, &session.Created, &session.Expires)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *pgSessions) Delete(ctx context.Context, id string) error {
+	_, err := traced(s.db).Exec(ctx, "DELETE FROM sessions WHERE id=$1", id)
+	return err
+}
+
+func (s *pgSessions) DeleteUser(ctx context.Context, nickname string) error {
+	_, err := traced(s.db).Exec(ctx, "DELETE FROM sessions WHERE nickname=$1", nickname)
+	return err
+}
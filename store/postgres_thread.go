@@ -0,0 +1,306 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type pgThreads struct {
+	db *sql.DB
+}
+
+func (s *pgThreads) Get(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	return s.get(ctx, slugOrId, false)
+}
+
+func (s *pgThreads) Deleted(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	return s.get(ctx, slugOrId, true)
+}
+
+func (s *pgThreads) get(ctx context.Context, slugOrId string, deleted bool) (*models.Thread, error) {
+	thrId, err := strconv.Atoi(slugOrId)
+	var row *sql.Row
+
+	if err != nil {
+		row = traced(s.db).QueryRow(ctx, "SELECT "+threadColumns+" FROM threads WHERE slug=$1 AND isdeleted=$2;", slugOrId, deleted)
+	} else {
+		row = traced(s.db).QueryRow(ctx, "SELECT "+threadColumns+" FROM threads WHERE id=$1 AND isdeleted=$2;", thrId, deleted)
+	}
+
+	thr := new(models.Thread)
+
+	err = scanThread(row, thr)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return thr, nil
+}
+
+func (s *pgThreads) Create(ctx context.Context, forum string, thr *models.Thread) error {
+	t, err := begin(ctx, s.db)
+	if err != nil {
+		return err
+	}
+
+	defer t.Rollback()
+
+	var row *sql.Row
+	if thr.Slug == "" {
+		row = traced(t).QueryRow(ctx, "INSERT INTO threads(author, created, forum, message, title) VALUES ($1, $2, "+
+			"(SELECT slug FROM forums WHERE slug=$3), $4, $5) RETURNING "+threadColumns, thr.Author, thr.Created, forum,
+			thr.Message, thr.Title)
+	} else {
+		row = traced(t).QueryRow(ctx, "INSERT INTO threads(author, created, forum, message, title, slug) VALUES ($1, $2, "+
+			"(SELECT slug FROM forums WHERE slug=$3), $4, $5, $6) RETURNING "+threadColumns, thr.Author, thr.Created, forum,
+			thr.Message, thr.Title, thr.Slug)
+	}
+
+	err = scanThread(row, thr)
+	if err != nil {
+		switch errorName(err) {
+		case "foreign_key_violation", "not_null_violation":
+			return ErrNotFound
+		case "unique_violation":
+			return ErrConflict
+		}
+		return err
+	}
+
+	_, err = traced(t).Exec(ctx, "INSERT INTO forum_users(forum,author) VALUES ($1,$2) ON CONFLICT DO NOTHING", thr.Forum, thr.Author)
+	if err != nil {
+		return err
+	}
+
+	return t.Commit()
+}
+
+func (s *pgThreads) Update(ctx context.Context, slugOrId string, update *models.Thread, editor string) (*models.Thread, error) {
+	if update.Message == "" && update.Title == "" {
+		return s.Get(ctx, slugOrId)
+	}
+
+	t, err := begin(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	defer t.Rollback()
+
+	// Блокировка строки ветки упорядочивает номера правок
+	q := newQuery("SELECT " + threadColumns + " FROM threads")
+	query, args, err := whereThread(q, "", slugOrId).where("NOT isdeleted").add(" FOR UPDATE").build()
+	if err != nil {
+		return nil, err
+	}
+
+	thr := new(models.Thread)
+
+	err = scanThread(traced(t).QueryRow(ctx, query, args...), thr)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	title, message := thr.Title, thr.Message
+	if update.Title != "" {
+		title = update.Title
+	}
+	if update.Message != "" {
+		message = update.Message
+	}
+
+	if title == thr.Title && message == thr.Message {
+		return thr, t.Commit()
+	}
+
+	_, err = traced(t).Exec(ctx, "INSERT INTO thread_revisions(thread, revision, editor, title, message) "+
+		"VALUES ($1, (SELECT COALESCE(max(revision), 0) + 1 FROM thread_revisions WHERE thread=$1), NULLIF($2, ''), $3, $4)",
+		thr.Id, editor, thr.Title, thr.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := new(models.Thread)
+
+	row := traced(t).QueryRow(ctx, "UPDATE threads SET title=$1, message=$2, isedited=true, edited=current_timestamp "+
+		"WHERE id=$3 RETURNING "+threadColumns, title, message, thr.Id)
+
+	if err := scanThread(row, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, t.Commit()
+}
+
+func (s *pgThreads) History(ctx context.Context, slugOrId string) ([]models.ThreadRevision, error) {
+	// Значения после правки - значения до следующей правки, для последней - текущие
+	q := newQuery("SELECT r.revision, r.editor, r.edited, r.title, r.message, " +
+		"COALESCE(lead(r.title) OVER w, t.title), COALESCE(lead(r.message) OVER w, t.message) " +
+		"FROM thread_revisions r JOIN threads t ON t.id=r.thread")
+
+	query, args, err := whereThread(q, "t.", slugOrId).where("NOT t.isdeleted").
+		add(" WINDOW w AS (ORDER BY r.revision) ORDER BY r.revision").build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(s.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := make([]models.ThreadRevision, 0)
+
+	for rows.Next() {
+		rev := models.ThreadRevision{}
+		var editor sql.NullString
+
+		err := rows.Scan(&rev.Revision, &editor, &rev.Edited, &rev.PreviousTitle, &rev.PreviousMessage, &rev.Title, &rev.Message)
+		if err != nil {
+			return nil, err
+		}
+
+		rev.Editor = editor.String
+		changedFields(&rev)
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Пустая история - проверяем, существует ли ветка
+	if len(revisions) == 0 {
+		if _, err := s.Get(ctx, slugOrId); err != nil {
+			return nil, err
+		}
+	}
+
+	return revisions, nil
+}
+
+func (s *pgThreads) Posts(ctx context.Context, thr *models.Thread, filter PostFilter) ([]models.Post, error) {
+	query, args, err := threadPostsQuery(thr.Id, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(s.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := make([]models.Post, 0)
+
+	for rows.Next() {
+		post := models.Post{}
+
+		if err := scanPost(rows, &post); err != nil {
+			return nil, err
+		}
+
+		tombstone(&post)
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
+// threadPostsQuery builds the statement of pgThreads.Posts.
+func threadPostsQuery(thread int32, filter PostFilter) (string, []interface{}, error) {
+	dir := sortDirection(filter.Desc)
+
+	// Для since в убывающем порядке нужны элементы "меньше" since
+	after := " > "
+	if filter.Desc {
+		after = " < "
+	}
+
+	q := newQuery("SELECT " + postColumns + " FROM posts")
+	q.where("thread = ?", thread)
+
+	switch filter.Sort {
+	case SortTree:
+		if filter.Since != 0 {
+			q.where("id_array"+after+"(SELECT id_array FROM posts WHERE id = ?)", filter.Since)
+		}
+
+		q.orderBy(dir, "id_array").limit(filter.Limit)
+
+	case SortParentTree:
+		// limit ограничивает число корневых постов, ветки выводятся целиком
+		roots := newQuery("SELECT id FROM posts")
+		roots.where("thread = ?", thread).where("parent = 0")
+
+		if filter.Since != 0 {
+			roots.where("id"+after+"(SELECT id_array[1] FROM posts WHERE id = ?)", filter.Since)
+		}
+
+		roots.orderBy(dir, "id").limit(filter.Limit)
+
+		q.where("id_array[1] IN (").sub(roots).add(")")
+		q.orderBy(dir, "id_array[1]").add(", id_array " + string(ascending))
+
+	default:
+		q.where("NOT isdeleted")
+
+		if filter.Since != 0 {
+			q.where("id"+after+"?", filter.Since)
+		}
+
+		q.orderBy(dir, "id").limit(filter.Limit)
+	}
+
+	return q.build()
+}
+
+func (s *pgThreads) Delete(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	return s.setDeleted(ctx, slugOrId, true)
+}
+
+func (s *pgThreads) Restore(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	return s.setDeleted(ctx, slugOrId, false)
+}
+
+// setDeleted changes the mark, trigger thread_delete adjusts the counters.
+func (s *pgThreads) setDeleted(ctx context.Context, slugOrId string, deleted bool) (*models.Thread, error) {
+	q := newQuery("UPDATE threads").set("isdeleted", deleted)
+
+	query, args, err := whereThread(q, "", slugOrId).where("isdeleted = ?", !deleted).add(" RETURNING " + threadColumns).build()
+	if err != nil {
+		return nil, err
+	}
+
+	thr := new(models.Thread)
+
+	err = scanThread(traced(s.db).QueryRow(ctx, query, args...), thr)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return thr, nil
+}
+
+// whereThread adds the condition on the id or slug of the thread, prefix is the table alias with a dot.
+func whereThread(q *query, prefix, slugOrId string) *query {
+	if thrId, err := strconv.Atoi(slugOrId); err == nil {
+		return q.where(prefix+"id = ?", thrId)
+	}
+	return q.where(prefix+"slug = ?", slugOrId)
+}
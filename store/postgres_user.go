@@ -0,0 +1,259 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+type pgUsers struct {
+	db *sql.DB
+}
+
+func (s *pgUsers) Get(ctx context.Context, nickname string) (*models.User, error) {
+	user := models.User{}
+
+	err := scanUser(traced(s.db).QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE nickname=$1", nickname), &user)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *pgUsers) Create(ctx context.Context, user *models.User) error {
+	t, err := begin(ctx, s.db)
+	if err != nil {
+		return err
+	}
+
+	defer t.Rollback()
+
+	query := "INSERT INTO users(about, email, fullname, nickname, password_hash) VALUES ($1,$2,$3,$4,NULLIF($5, '')) RETURNING " + userColumns
+
+	err = scanUser(traced(t).QueryRow(ctx, query, user.About, user.Email, user.FullName, user.NickName, user.PasswordHash), user)
+	if err != nil {
+		if errorName(err) == "unique_violation" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return t.Commit()
+}
+
+func (s *pgUsers) Conflicting(ctx context.Context, nickname, email string) ([]models.User, error) {
+	rows, err := traced(s.db).Query(ctx, "SELECT "+userColumns+" FROM users WHERE nickname=$1 OR email=$2", nickname, email)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+
+	for rows.Next() {
+		usr := models.User{}
+
+		if err := scanUser(rows, &usr); err != nil {
+			return nil, err
+		}
+
+		users = append(users, usr)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *pgUsers) Update(ctx context.Context, nickname string, userUpdate *models.User) (*models.User, error) {
+	q := newQuery("UPDATE users")
+
+	if userUpdate.About != "" {
+		q.set("about", userUpdate.About)
+	}
+	if userUpdate.FullName != "" {
+		q.set("fullname", userUpdate.FullName)
+	}
+	if userUpdate.Email != "" {
+		q.set("email", userUpdate.Email)
+	}
+	if userUpdate.PasswordHash != "" {
+		q.set("password_hash", userUpdate.PasswordHash)
+	}
+
+	if !q.hasSet {
+		return s.Get(ctx, nickname)
+	}
+
+	query, args, err := q.where("nickname = ?", nickname).add(" RETURNING " + userColumns).build()
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{}
+
+	err = scanUser(traced(s.db).QueryRow(ctx, query, args...), &user)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		if errorName(err) == "unique_violation" {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *pgUsers) PasswordHash(ctx context.Context, nickname string) (string, error) {
+	var hash sql.NullString
+
+	err := traced(s.db).QueryRow(ctx, "SELECT password_hash FROM users WHERE nickname=$1", nickname).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return hash.String, nil
+}
+
+func (s *pgUsers) Posts(ctx context.Context, nickname string, filter ThreadFilter) ([]models.Post, error) {
+	q := newQuery("SELECT " + prefixColumns("p", postColumns) + " FROM posts p JOIN threads t ON t.id=p.thread")
+	q.where("p.author = ?", nickname).where("NOT p.isdeleted").where("NOT t.isdeleted")
+	createdWindow(q, "p.", filter)
+
+	if filter.After != nil {
+		afterKey(q, "p.created", "p.id", filter.After.Time, filter)
+	}
+
+	query, args, err := q.orderBy(sortDirection(filter.Desc), "p.created", "p.id").limit(filter.Limit).build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(s.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := make([]models.Post, 0)
+
+	for rows.Next() {
+		post := models.Post{}
+
+		if err := scanPost(rows, &post); err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Пустой список - проверяем, существует ли пользователь
+	if len(posts) == 0 {
+		if _, err := s.Get(ctx, nickname); err != nil {
+			return nil, err
+		}
+	}
+
+	return posts, nil
+}
+
+func (s *pgUsers) Threads(ctx context.Context, nickname string, filter ThreadFilter) ([]models.Thread, error) {
+	q := newQuery("SELECT " + threadColumns + " FROM threads")
+	q.where("author = ?", nickname).where("NOT isdeleted")
+	createdWindow(q, "", filter)
+
+	if filter.After != nil {
+		afterKey(q, "created", "id", filter.After.Time, filter)
+	}
+
+	query, args, err := q.orderBy(sortDirection(filter.Desc), "created", "id").limit(filter.Limit).build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(s.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	thrs := make([]models.Thread, 0)
+
+	for rows.Next() {
+		thr := models.Thread{}
+
+		if err := scanThread(rows, &thr); err != nil {
+			return nil, err
+		}
+
+		thrs = append(thrs, thr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(thrs) == 0 {
+		if _, err := s.Get(ctx, nickname); err != nil {
+			return nil, err
+		}
+	}
+
+	return thrs, nil
+}
+
+func (s *pgUsers) Stats(ctx context.Context, nickname string) (*models.UserStats, error) {
+	query := "SELECT " +
+		"(SELECT count(*) FROM posts p JOIN threads t ON t.id=p.thread WHERE p.author=u.nickname AND NOT p.isdeleted AND NOT t.isdeleted), " +
+		"(SELECT count(*) FROM threads WHERE author=u.nickname AND NOT isdeleted), " +
+		"(SELECT COALESCE(sum(votes), 0) FROM threads WHERE author=u.nickname AND NOT isdeleted), " +
+		"(SELECT count(*) FROM forum_users WHERE author=u.nickname) " +
+		"FROM users u WHERE u.nickname=$1"
+
+	stats := new(models.UserStats)
+
+	err := traced(s.db).QueryRow(ctx, query, nickname).Scan(&stats.Posts, &stats.Threads, &stats.Votes, &stats.Forums)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// createdWindow adds the Since and Until conditions of ThreadFilter, prefix is the table alias with a dot.
+func createdWindow(q *query, prefix string, filter ThreadFilter) {
+	if !filter.Since.IsZero() {
+		q.where(prefix+"created >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		q.where(prefix+"created <= ?", filter.Until)
+	}
+}
+
+// afterKey adds the cursor condition of a list ordered by column and then by idColumn.
+func afterKey(q *query, column, idColumn string, value interface{}, filter ThreadFilter) {
+	after := " > "
+	if filter.Desc {
+		after = " < "
+	}
+
+	q.where("("+column+", "+idColumn+")"+after+"(?, ?)", value, filter.After.Id)
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// direction is the only way to put ASC/DESC into a query, so a sort order can't come from user input.
+type direction string
+
+const (
+	ascending  direction = "ASC"
+	descending direction = "DESC"
+)
+
+func sortDirection(desc bool) direction {
+	if desc {
+		return descending
+	}
+	return ascending
+}
+
+// query composes a statement from SQL fragments written in code and bound values.
+// Fragments use ? for parameters, they are numbered as $1, $2... only in build,
+// so subqueries can be appended in any order. Values never become a part of the SQL text.
+// The first mistake in the fragments is kept and returned by build.
+type query struct {
+	parts    []string
+	args     []interface{}
+	hasWhere bool
+	hasSet   bool
+	err      error
+}
+
+func newQuery(fragment string, args ...interface{}) *query {
+	return new(query).add(fragment, args...)
+}
+
+// add appends a fragment, the number of ? in it must match the number of args.
+func (q *query) add(fragment string, args ...interface{}) *query {
+	if n := strings.Count(fragment, "?"); n != len(args) && q.err == nil {
+		q.err = fmt.Errorf("query: fragment %q has %d placeholders, got %d args", fragment, n, len(args))
+	}
+
+	q.parts = append(q.parts, fragment)
+	q.args = append(q.args, args...)
+
+	return q
+}
+
+// sub appends another query, e.g. the body of an IN (...) condition.
+func (q *query) sub(s *query) *query {
+	q.parts = append(q.parts, s.parts...)
+	q.args = append(q.args, s.args...)
+
+	if q.err == nil {
+		q.err = s.err
+	}
+
+	return q
+}
+
+// where adds a condition joined with AND to the previous ones.
+func (q *query) where(cond string, args ...interface{}) *query {
+	if q.hasWhere {
+		q.add(" AND ")
+	} else {
+		q.add(" WHERE ")
+		q.hasWhere = true
+	}
+
+	return q.add(cond, args...)
+}
+
+// set adds "column = value" to an UPDATE statement.
+func (q *query) set(column string, value interface{}) *query {
+	if q.hasSet {
+		q.add(", ")
+	} else {
+		q.add(" SET ")
+		q.hasSet = true
+	}
+
+	return q.add(column+" = ?", value)
+}
+
+func (q *query) orderBy(dir direction, columns ...string) *query {
+	for i, column := range columns {
+		if i == 0 {
+			q.add(" ORDER BY ")
+		} else {
+			q.add(", ")
+		}
+		q.add(column + " " + string(dir))
+	}
+
+	return q
+}
+
+// limit adds LIMIT for positive n, otherwise all rows are returned.
+func (q *query) limit(n int) *query {
+	if n > 0 {
+		q.add(" LIMIT ?", n)
+	}
+
+	return q
+}
+
+// build numbers the placeholders, it fails if a fragment didn't match its args.
+func (q *query) build() (string, []interface{}, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+
+	text := strings.Join(q.parts, "")
+	res := make([]byte, 0, len(text)+len(q.args)*2)
+	n := 0
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '?' {
+			res = append(res, text[i])
+			continue
+		}
+
+		n++
+		res = append(res, '$')
+		res = strconv.AppendInt(res, int64(n), 10)
+	}
+
+	return string(res), q.args, nil
+}
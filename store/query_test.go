@@ -0,0 +1,216 @@
+package store
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildNumbersPlaceholders(t *testing.T) {
+	sub := newQuery("SELECT id FROM threads").where("forum = ?", "f").where("votes > ?", 3)
+
+	q := newQuery("SELECT id FROM posts")
+	q.where("thread = ?", 1).where("thread IN (").sub(sub).add(")")
+	q.orderBy(descending, "created", "id").limit(10)
+
+	query, args, err := q.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "SELECT id FROM posts WHERE thread = $1 AND thread IN (SELECT id FROM threads WHERE forum = $2 AND votes > $3)" +
+		" ORDER BY created DESC, id DESC LIMIT $4"
+	if query != want {
+		t.Errorf("query:\n got %s\nwant %s", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{1, "f", 3, 10}) {
+		t.Errorf("args: got %v", args)
+	}
+}
+
+func TestBuildSet(t *testing.T) {
+	q := newQuery("UPDATE categories").set("title", "t").set("position", 2)
+	query, args, err := q.where("slug = ?", "s").build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "UPDATE categories SET title = $1, position = $2 WHERE slug = $3"; query != want {
+		t.Errorf("query:\n got %s\nwant %s", query, want)
+	}
+	if len(args) != 3 {
+		t.Errorf("args: got %v", args)
+	}
+}
+
+func TestLimitSkipsNonPositive(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		query, args, err := newQuery("SELECT 1").limit(n).build()
+		if err != nil || query != "SELECT 1" || len(args) != 0 {
+			t.Errorf("limit(%d): got %q %v", n, query, args)
+		}
+	}
+}
+
+func TestBuildFailsOnArgumentMismatch(t *testing.T) {
+	cases := []struct {
+		fragment string
+		args     []interface{}
+	}{
+		{"a = ?", nil},
+		{"a = ? AND b = ?", []interface{}{1}},
+		{"a = b", []interface{}{1}},
+		{"a = ?", []interface{}{1, 2}},
+	}
+
+	for _, c := range cases {
+		q := newQuery("SELECT 1").add(c.fragment, c.args...).where("c = ?", 3)
+		if query, args, err := q.build(); err == nil {
+			t.Errorf("add(%q, %v): got %q %v", c.fragment, c.args, query, args)
+		}
+
+		// Ошибка подзапроса - ошибка всего запроса
+		outer := newQuery("SELECT 1 WHERE a IN (").sub(newQuery("SELECT 1").where(c.fragment, c.args...)).add(")")
+		if _, _, err := outer.build(); err == nil {
+			t.Errorf("sub with %q, %v: no error", c.fragment, c.args)
+		}
+	}
+}
+
+func TestSortDirection(t *testing.T) {
+	if sortDirection(false) != ascending || string(ascending) != "ASC" {
+		t.Errorf("ascending: got %q", sortDirection(false))
+	}
+	if sortDirection(true) != descending || string(descending) != "DESC" {
+		t.Errorf("descending: got %q", sortDirection(true))
+	}
+}
+
+// Значения от клиента, которые не должны попасть в текст запроса
+var hostile = []string{
+	"x'; DROP TABLE users; --",
+	"title DESC; DELETE FROM forums",
+	"? OR 1=1",
+	"$0 OR true",
+	"%_\\",
+}
+
+// checkBound fails if a hostile value is in the query text or the placeholders don't match the args.
+func checkBound(t *testing.T, name, query string, args []interface{}) {
+	t.Helper()
+
+	for _, h := range hostile {
+		if strings.Contains(query, h) {
+			t.Errorf("%s: %q is in the query %s", name, h, query)
+		}
+	}
+
+	if strings.Contains(query, "?") {
+		t.Errorf("%s: unnumbered placeholder in %s", name, query)
+	}
+	for i := range args {
+		if !strings.Contains(query, "$"+strconv.Itoa(i+1)) {
+			t.Errorf("%s: no $%d for %v in %s", name, i+1, args[i], query)
+		}
+	}
+	if strings.Contains(query, "$"+strconv.Itoa(len(args)+1)) {
+		t.Errorf("%s: more placeholders than %d args in %s", name, len(args), query)
+	}
+}
+
+func hasArg(args []interface{}, value interface{}) bool {
+	for _, a := range args {
+		if reflect.DeepEqual(a, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestForumThreadsQueryBindsInput(t *testing.T) {
+	since := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, h := range hostile {
+		for _, desc := range []bool{false, true} {
+			filter := ThreadFilter{
+				Limit:  5,
+				Since:  since,
+				Until:  since.Add(time.Hour),
+				Author: h,
+				Sort:   h,
+				Desc:   desc,
+				After:  &ThreadKey{Time: since, Id: 7},
+			}
+
+			query, args, err := forumThreadsQuery(h, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkBound(t, "threads", query, args)
+
+			if !hasArg(args, h) || !hasArg(args, since) {
+				t.Errorf("threads: slug, author and since must be args, got %v", args)
+			}
+			// Неизвестная сортировка - сортировка по времени создания
+			if !strings.Contains(query, " ORDER BY t.created "+string(sortDirection(desc))+", t.id ") {
+				t.Errorf("threads: unknown sort must fall back to created, got %s", query)
+			}
+		}
+	}
+}
+
+func TestForumListQueryBindsInput(t *testing.T) {
+	for _, h := range hostile {
+		filter := ForumFilter{
+			Limit: 3,
+			Sort:  h,
+			Owner: h,
+			Query: h,
+			After: &ForumKey{Title: h, Slug: h},
+		}
+
+		query, args, err := forumListQuery(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkBound(t, "forums", query, args)
+
+		if !hasArg(args, h) || !hasArg(args, "%"+likeEscaper.Replace(h)+"%") {
+			t.Errorf("forums: owner, title and cursor must be args, got %v", args)
+		}
+		if !strings.Contains(query, " ORDER BY title ASC, slug ASC") {
+			t.Errorf("forums: unknown sort must fall back to title, got %s", query)
+		}
+	}
+}
+
+func TestLikeEscaper(t *testing.T) {
+	if got := likeEscaper.Replace(`50%_a\b`); got != `50\%\_a\\b` {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestThreadPostsQueryBindsInput(t *testing.T) {
+	for _, h := range hostile {
+		for _, sort := range []string{SortFlat, SortTree, SortParentTree, h} {
+			query, args, err := threadPostsQuery(1, PostFilter{Limit: 2, Since: 9, Sort: sort, Desc: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkBound(t, "posts "+sort, query, args)
+
+			if !hasArg(args, int64(9)) {
+				t.Errorf("posts %s: since must be an arg, got %v", sort, args)
+			}
+		}
+	}
+}
+
+func TestHTMLEscaped(t *testing.T) {
+	want := `replace(replace(replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+	if got := htmlEscaped("body"); got != want {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,309 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Grisha23/ForumsApi/models"
+)
+
+var (
+	ErrNotFound       = errors.New("store: not found")
+	ErrConflict       = errors.New("store: conflict")
+	ErrParentConflict = errors.New("store: parent post was created in another thread")
+)
+
+const (
+	SortFlat       = "flat"
+	SortTree       = "tree"
+	SortParentTree = "parent_tree"
+)
+
+// Store groups the storage of every entity, handlers only talk to these interfaces.
+type Store struct {
+	Users      UserStore
+	Forums     ForumStore
+	Categories CategoryStore
+	Threads    ThreadStore
+	Posts      PostStore
+	Votes      VoteStore
+	Service    ServiceStore
+	Sessions   SessionStore
+	Search     SearchStore
+}
+
+type UserStore interface {
+	Get(ctx context.Context, nickname string) (*models.User, error)
+	// Create returns ErrConflict if the nickname or email is already taken.
+	Create(ctx context.Context, user *models.User) error
+	// Conflicting returns users having the given nickname or email.
+	Conflicting(ctx context.Context, nickname, email string) ([]models.User, error)
+	// Update changes only non-empty fields of update, including PasswordHash.
+	Update(ctx context.Context, nickname string, update *models.User) (*models.User, error)
+	// PasswordHash returns "" if the user has no password.
+	PasswordHash(ctx context.Context, nickname string) (string, error)
+
+	// Posts and Threads list what the user wrote, ordered like ForumStore.Threads, skipping
+	// deleted ones. They return ErrNotFound if there is no such user.
+	Posts(ctx context.Context, nickname string, filter ThreadFilter) ([]models.Post, error)
+	Threads(ctx context.Context, nickname string, filter ThreadFilter) ([]models.Thread, error)
+	Stats(ctx context.Context, nickname string) (*models.UserStats, error)
+}
+
+type ForumStore interface {
+	Get(ctx context.Context, slug string) (*models.Forum, error)
+	// Create returns ErrNotFound if there is no such user, category or parent forum and
+	// ErrConflict if the slug is taken.
+	Create(ctx context.Context, forum *models.Forum) error
+	Users(ctx context.Context, slug string, filter UserFilter) ([]models.User, error)
+	Threads(ctx context.Context, slug string, filter ThreadFilter) ([]models.Thread, error)
+	// Family returns the ancestors of the forum from the root down and all its sub-forums
+	// at any depth, ErrNotFound if there is no such forum.
+	Family(ctx context.Context, slug string) (ancestors, descendants []models.Forum, err error)
+	// All lists every forum ordered by slug, used to build the forum tree.
+	All(ctx context.Context) ([]models.Forum, error)
+	List(ctx context.Context, filter ForumFilter) ([]models.Forum, error)
+
+	// Role returns RoleModerator, RoleBanned or "" for a user without a role in the forum.
+	Role(ctx context.Context, slug, nickname string) (string, error)
+	// Banned returns the first of nicknames banned in the forum or "".
+	Banned(ctx context.Context, slug string, nicknames []string) (string, error)
+	// Roles lists users having role in the forum, ErrNotFound if there is no such forum.
+	Roles(ctx context.Context, slug, role string) ([]models.ForumRole, error)
+	// SetRole replaces the role of the user, ErrNotFound if there is no such forum or user.
+	SetRole(ctx context.Context, slug string, role *models.ForumRole) error
+	// DeleteRole returns ErrNotFound if the user doesn't have role in the forum.
+	DeleteRole(ctx context.Context, slug, nickname, role string) error
+}
+
+type CategoryStore interface {
+	Get(ctx context.Context, slug string) (*models.Category, error)
+	// Create returns ErrConflict if the slug is taken.
+	Create(ctx context.Context, category *models.Category) error
+	Update(ctx context.Context, slug string, update *models.CategoryUpdate) (*models.Category, error)
+	// Delete returns ErrConflict while the category still has forums.
+	Delete(ctx context.Context, slug string) error
+	// List returns all categories ordered by position and slug.
+	List(ctx context.Context) ([]models.Category, error)
+}
+
+// Roles stored per forum. Owners are forums.author and admins come from the config.
+const (
+	RoleModerator = "moderator"
+	RoleBanned    = "banned"
+)
+
+// Deleted threads are invisible to ThreadStore, ForumStore and VoteStore except for
+// Restore. Deleted posts stay in their thread's tree, see tombstone.
+type ThreadStore interface {
+	Get(ctx context.Context, slugOrId string) (*models.Thread, error)
+	// Create returns ErrNotFound if there is no such user or forum and ErrConflict if the slug is taken.
+	Create(ctx context.Context, forum string, thr *models.Thread) error
+	// Update changes only non-empty title and message of update and saves the replaced
+	// values as a revision made by editor, "" for an anonymous edit.
+	Update(ctx context.Context, slugOrId string, update *models.Thread, editor string) (*models.Thread, error)
+	// History lists the revisions of the thread from the first one.
+	History(ctx context.Context, slugOrId string) ([]models.ThreadRevision, error)
+	// Posts skips deleted posts in SortFlat and shows them as tombstones in the tree sorts.
+	Posts(ctx context.Context, thr *models.Thread, filter PostFilter) ([]models.Post, error)
+	// Deleted is Get for deleted threads only.
+	Deleted(ctx context.Context, slugOrId string) (*models.Thread, error)
+	// Delete marks the thread deleted, ErrNotFound if it is already deleted.
+	Delete(ctx context.Context, slugOrId string) (*models.Thread, error)
+	// Restore clears the mark, ErrNotFound if the thread is not deleted.
+	Restore(ctx context.Context, slugOrId string) (*models.Thread, error)
+}
+
+type PostStore interface {
+	// Get returns deleted posts too, with IsDeleted set.
+	Get(ctx context.Context, id int64) (*models.Post, error)
+	Details(ctx context.Context, id int64, related Related) (*models.PostDetail, error)
+	// Create inserts the whole batch or nothing. It returns ErrNotFound if an author
+	// doesn't exist and ErrParentConflict if a parent is not a post of thr.
+	Create(ctx context.Context, thr *models.Thread, posts []models.Post) ([]models.Post, error)
+	// Update saves the replaced text as a revision made by editor, "" for an anonymous
+	// edit. It returns ErrNotFound for a deleted post.
+	Update(ctx context.Context, id int64, message, editor string) (*models.Post, error)
+	// History lists the revisions of the post from the first one.
+	History(ctx context.Context, id int64) ([]models.PostRevision, error)
+	// Delete marks the post deleted, ErrNotFound if it is already deleted.
+	Delete(ctx context.Context, id int64) (*models.Post, error)
+	// Restore clears the mark, ErrNotFound if the post is not deleted.
+	Restore(ctx context.Context, id int64) (*models.Post, error)
+}
+
+// changedFields fills ThreadRevision.Changed from the values before and after the edit.
+func changedFields(rev *models.ThreadRevision) {
+	rev.Changed = make([]string, 0, 2)
+
+	if rev.Title != rev.PreviousTitle {
+		rev.Changed = append(rev.Changed, "title")
+	}
+	if rev.Message != rev.PreviousMessage {
+		rev.Changed = append(rev.Changed, "message")
+	}
+}
+
+// tombstone hides the content of a deleted post kept in a tree for its replies.
+func tombstone(post *models.Post) {
+	if post.IsDeleted {
+		post.Author, post.Message = "", ""
+	}
+}
+
+type VoteStore interface {
+	// Vote creates or changes the vote and returns the updated thread.
+	Vote(ctx context.Context, slugOrId string, vote *models.Vote) (*models.Thread, error)
+}
+
+// Status modes: StatusCounters reads the counters maintained by triggers, StatusExact
+// counts rows, StatusEstimate reads the planner statistics, which lag behind until the next ANALYZE.
+const (
+	StatusCounters = "counters"
+	StatusExact    = "exact"
+	StatusEstimate = "estimate"
+)
+
+type ServiceStore interface {
+	Status(ctx context.Context, mode string) (*models.Status, error)
+	Clear(ctx context.Context) error
+	// Health checks the connection and returns what the readiness probe reports.
+	Health(ctx context.Context) (*Health, error)
+	// Reconcile recounts rows and corrects the counters used by StatusCounters.
+	// It returns the corrections made by counter name, empty if nothing drifted.
+	Reconcile(ctx context.Context) (map[string]int64, error)
+}
+
+type Health struct {
+	SchemaVersion int // Примененная версия миграций
+	LatestVersion int // Последняя версия миграций, известная этой сборке
+	InUse         int // Занятые соединения пула
+	MaxOpen       int // Размер пула, 0 - без ограничения
+}
+
+// Session kinds: a login session and a long-lived API token.
+const (
+	SessionLogin = "session"
+	SessionToken = "token"
+)
+
+type Session struct {
+	Id       string // sha256 от токена
+	Nickname string
+	Kind     string
+	Created  time.Time
+	Expires  time.Time
+}
+
+type SessionStore interface {
+	// Create returns ErrNotFound if there is no such user.
+	Create(ctx context.Context, session *Session) error
+	// Get returns ErrNotFound for unknown and expired sessions.
+	Get(ctx context.Context, id string) (*Session, error)
+	Delete(ctx context.Context, id string) error
+	// DeleteUser ends all sessions and API tokens of the user.
+	DeleteUser(ctx context.Context, nickname string) error
+}
+
+// Kinds of search hits.
+const (
+	SearchPost   = "post"
+	SearchThread = "thread"
+)
+
+type SearchStore interface {
+	// Search finds posts and threads containing all words of filter.Query, skipping deleted
+	// ones. Hits are ordered by rank, kind and id, all descending.
+	Search(ctx context.Context, filter SearchFilter) ([]models.SearchHit, error)
+}
+
+type SearchFilter struct {
+	Query  string
+	Forum  string    // "" - все форумы
+	Author string    // "" - все авторы
+	Since  time.Time // Нулевое значение - без ограничения
+	Kind   string    // SearchPost, SearchThread или "" - все
+	Limit  int
+	After  *SearchKey // Последний результат предыдущей страницы
+}
+
+// SearchKey is the position of a hit in the search order.
+type SearchKey struct {
+	Rank float32
+	Kind string
+	Id   int64
+}
+
+// Sorts of ForumStore.List.
+const (
+	ForumsByTitle   = "title"
+	ForumsByCreated = "created"
+	ForumsByPosts   = "posts"
+	ForumsByThreads = "threads"
+)
+
+// ForumFilter selects a page of forums ordered by Sort and slug.
+type ForumFilter struct {
+	Limit int       // 0 - без ограничения
+	Sort  string    // ForumsBy*, "" - ForumsByTitle
+	Owner string    // "" - все владельцы
+	Query string    // Подстрока названия без учета регистра, "" - все
+	After *ForumKey // Позиция курсора, выдача строго после нее
+	Desc  bool
+}
+
+// ForumKey is the position of a forum in ForumStore.List: the value it is sorted by and its slug.
+type ForumKey struct {
+	Title   string    // Для ForumsByTitle
+	Created time.Time // Для ForumsByCreated
+	Number  int64     // Для ForumsByPosts и ForumsByThreads
+	Slug    string
+}
+
+type UserFilter struct {
+	Limit int    // 0 - без ограничения
+	Since string // Никнейм, после которого начинать выдачу
+	Desc  bool
+}
+
+// Sorts of ForumStore.Threads, UserStore lists are always sorted by ThreadsByCreated.
+const (
+	ThreadsByCreated  = "created"
+	ThreadsByVotes    = "votes"
+	ThreadsByActivity = "activity" // Время последнего поста, для ветки без постов - время создания
+	ThreadsByPosts    = "posts"
+)
+
+// ThreadFilter selects a page of threads ordered by Sort and id, Since <= created <= Until.
+// The window doesn't depend on Desc, which is only the direction to read in.
+type ThreadFilter struct {
+	Limit  int
+	Since  time.Time  // Нулевое значение - без ограничения
+	Until  time.Time  // Нулевое значение - без ограничения
+	Author string     // "" - все авторы, только для ForumStore.Threads
+	Sort   string     // ThreadsBy*, "" - ThreadsByCreated
+	After  *ThreadKey // Позиция курсора, выдача строго после нее
+	Desc   bool
+}
+
+// ThreadKey is the position of an item in a list: the value it is sorted by and its id.
+type ThreadKey struct {
+	Time   time.Time // Для ThreadsByCreated и ThreadsByActivity
+	Number int64     // Для ThreadsByVotes и ThreadsByPosts
+	Id     int64
+}
+
+type PostFilter struct {
+	Limit int
+	Since int64 // Идентификатор поста, 0 - без ограничения
+	Desc  bool
+	Sort  string // SortFlat, SortTree или SortParentTree
+}
+
+// Related lists the objects PostStore.Details loads together with the post.
+type Related struct {
+	User   bool
+	Thread bool
+	Forum  bool
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/Grisha23/ForumsApi/tracing"
+)
+
+// runner is the part of *sql.DB and *sql.Tx the postgres stores use.
+type runner interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// tracedRunner runs every statement in its own span, a child of the request span in ctx.
+type tracedRunner struct {
+	r runner
+}
+
+func traced(r runner) tracedRunner {
+	return tracedRunner{r: r}
+}
+
+func (t tracedRunner) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startStatement(ctx, query)
+
+	res, err := t.r.ExecContext(ctx, query, args...)
+	if err == nil {
+		if n, err := res.RowsAffected(); err == nil {
+			span.SetAttr("db.rows", n)
+		}
+	}
+
+	endStatement(span, err)
+
+	return res, err
+}
+
+// QueryRow ends the span when the statement has been executed, Scan only reads the result.
+func (t tracedRunner) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startStatement(ctx, query)
+
+	row := t.r.QueryRowContext(ctx, query, args...)
+
+	span.End()
+
+	return row
+}
+
+func (t tracedRunner) Query(ctx context.Context, query string, args ...interface{}) (*tracedRows, error) {
+	ctx, span := startStatement(ctx, query)
+
+	rows, err := t.r.QueryContext(ctx, query, args...)
+	if err != nil {
+		endStatement(span, err)
+		return nil, err
+	}
+
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+// tracedRows counts read rows and ends the span on Close.
+type tracedRows struct {
+	*sql.Rows
+	span *tracing.Span
+	n    int
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		r.n++
+		return true
+	}
+	return false
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+
+	r.span.SetAttr("db.rows", r.n)
+	endStatement(r.span, r.Rows.Err())
+
+	return err
+}
+
+func startStatement(ctx context.Context, query string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, "sql")
+	if span != nil {
+		statement, fingerprint := fingerprint(query)
+		span.SetAttr("db.statement", statement)
+		span.SetAttr("db.fingerprint", fingerprint)
+	}
+	return ctx, span
+}
+
+func endStatement(span *tracing.Span, err error) {
+	if err != nil {
+		span.SetError(err)
+		if code := ErrorCode(err); code != "" {
+			span.SetAttr("db.code", code)
+		}
+	}
+	span.End()
+}
+
+// fingerprint normalizes a statement: parameters, numbers and string literals become ?,
+// whitespace is collapsed. Statements differing only in values get the same fingerprint.
+func fingerprint(query string) (string, string) {
+	res := make([]byte, 0, len(query))
+
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		case c == '\'':
+			for i++; i < len(query) && query[i] != '\''; i++ {
+			}
+			c = '?'
+		case c == '$' || isDigit(c) && (space || !continuesIdent(res)):
+			for i+1 < len(query) && isDigit(query[i+1]) {
+				i++
+			}
+			c = '?'
+		}
+
+		if space && len(res) > 0 {
+			res = append(res, ' ')
+		}
+		space = false
+		res = append(res, c)
+	}
+
+	h := fnv.New64a()
+	h.Write(res)
+
+	return string(res), fmt.Sprintf("%016x", h.Sum64())
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// continuesIdent reports whether a digit after res would be a part of an identifier like id_array1.
+func continuesIdent(res []byte) bool {
+	if len(res) == 0 {
+		return false
+	}
+	c := res[len(res)-1]
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c)
+}
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WriterExporter writes every span as a JSON line, e.g. to stdout.
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+func (e *WriterExporter) Export(span *SpanData) {
+	line, err := json.Marshal(span)
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	e.w.Write(append(line, '\n'))
+	e.mu.Unlock()
+}
+
+func (e *WriterExporter) Close() error {
+	return nil
+}
+
+// HTTPExporter sends spans in batches as {"spans": [...]} POST requests to a collector.
+// Export never blocks a request: when the queue is full the span is dropped.
+type HTTPExporter struct {
+	url    string
+	client *http.Client
+	queue  chan *SpanData
+	done   chan struct{}
+	errors func(error)
+}
+
+const (
+	httpQueueSize     = 4096
+	httpBatchSize     = 256
+	httpFlushInterval = time.Second
+)
+
+// NewHTTPExporter starts the sending goroutine, onError is called for failed batches.
+func NewHTTPExporter(url string, onError func(error)) *HTTPExporter {
+	e := &HTTPExporter{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+		queue:  make(chan *SpanData, httpQueueSize),
+		done:   make(chan struct{}),
+		errors: onError,
+	}
+
+	go e.loop()
+
+	return e
+}
+
+func (e *HTTPExporter) Export(span *SpanData) {
+	select {
+	case e.queue <- span:
+	default:
+	}
+}
+
+// Close sends what is left in the queue and stops the exporter.
+func (e *HTTPExporter) Close() error {
+	close(e.queue)
+	<-e.done
+	return nil
+}
+
+func (e *HTTPExporter) loop() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(httpFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SpanData, 0, httpBatchSize)
+
+	for {
+		select {
+		case span, ok := <-e.queue:
+			if !ok {
+				e.send(batch)
+				return
+			}
+
+			batch = append(batch, span)
+			if len(batch) == httpBatchSize {
+				e.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			e.send(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (e *HTTPExporter) send(batch []*SpanData) {
+	if len(batch) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(struct {
+		Spans []*SpanData `json:"spans"`
+	}{batch})
+	if err != nil {
+		e.report(err)
+		return
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		e.report(err)
+		return
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		e.report(fmt.Errorf("collector %s: %s", e.url, resp.Status))
+	}
+}
+
+func (e *HTTPExporter) report(err error) {
+	if e.errors != nil {
+		e.errors(err)
+	}
+}
@@ -0,0 +1,212 @@
+// Package tracing records spans of a request (the handler and every SQL statement)
+// and hands finished spans to an exporter. It follows the OpenTelemetry data model
+// closely enough that the collector output can be fed to the usual tools.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanData is a finished span as it is exported.
+type SpanData struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Start      time.Time              `json:"start"`
+	DurationMs float64                `json:"duration_ms"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+type Exporter interface {
+	Export(span *SpanData)
+	// Close flushes buffered spans.
+	Close() error
+}
+
+type Tracer struct {
+	exporter Exporter
+	sample   float64
+}
+
+// New creates a tracer that records the given fraction of traces (1 - all of them).
+func New(exporter Exporter, sample float64) *Tracer {
+	return &Tracer{exporter: exporter, sample: sample}
+}
+
+func (t *Tracer) Close() error {
+	return t.exporter.Close()
+}
+
+// Span is an operation in progress. A nil *Span is valid and records nothing,
+// that's what Start returns when tracing is off or the trace is not sampled.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+type contextKey int
+
+const (
+	tracerKey contextKey = iota
+	spanKey
+)
+
+func NewContext(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey, t)
+}
+
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey).(*Span)
+	return s
+}
+
+// StartRemote begins the root span of a request. traceparent is the W3C header
+// of the caller, if it's valid the span continues the caller's trace.
+func StartRemote(ctx context.Context, name, traceparent string) (context.Context, *Span) {
+	t, _ := ctx.Value(tracerKey).(*Tracer)
+	if t == nil {
+		return ctx, nil
+	}
+
+	traceID, parentID, sampled, ok := parseTraceparent(traceparent)
+	if !ok {
+		if t.sample < 1 && mathrand.Float64() >= t.sample {
+			return ctx, nil
+		}
+		traceID, parentID, sampled = newID(16), "", true
+	}
+	if !sampled {
+		return ctx, nil
+	}
+
+	s := t.start(name, traceID, parentID)
+	return context.WithValue(ctx, spanKey, s), s
+}
+
+// Start begins a child of the span in ctx. Without a parent nothing is recorded,
+// so statements run outside of requests (migrations, background jobs) don't start traces.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	s := parent.tracer.start(name, parent.data.TraceID, parent.data.SpanID)
+	return context.WithValue(ctx, spanKey, s), s
+}
+
+func (t *Tracer) start(name, traceID, parentID string) *Span {
+	return &Span{
+		tracer: t,
+		data: SpanData{
+			TraceID:  traceID,
+			SpanID:   newID(8),
+			ParentID: parentID,
+			Name:     name,
+			Start:    time.Now(),
+		},
+	}
+}
+
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID
+}
+
+// Traceparent returns the W3C header value to pass this span to a downstream service.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.data.TraceID + "-" + s.data.SpanID + "-01"
+}
+
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.data.Error = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and exports it, calls after the first one are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.DurationMs = float64(time.Since(s.data.Start)) / float64(time.Millisecond)
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.exporter.Export(&data)
+}
+
+func parseTraceparent(h string) (traceID, parentID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) != 4 || parts[0] != "00" || !isHex(parts[1], 32) || !isHex(parts[2], 16) || !isHex(parts[3], 2) {
+		return "", "", false, false
+	}
+
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return "", "", false, false
+	}
+
+	flags, _ := hex.DecodeString(parts[3])
+
+	return parts[1], parts[2], flags[0]&1 == 1, true
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newID(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}